@@ -15,6 +15,7 @@ const (
 	PeripheralBase2835    int64 = 0x20000000
 	PeripheralBase2836    int64 = 0x3f000000
 	PeripheralBase2837    int64 = 0x3f000000
+	PeripheralBase2711    int64 = 0xfe000000
 )
 
 // ModelT :  Raspberry Pi Revision :: Model
@@ -33,6 +34,13 @@ const (
 	ModelZero    ModelT = 9  //   "Pi Zero",	// 09
 	ModelCM3     ModelT = 10 //   "CM3",	// 10
 	ModelZeroW   ModelT = 12 //   "Pi Zero-W",	// 12
+	Model3BPlus  ModelT = 13 //   "Pi 3+",	// 13
+	Model3APlus  ModelT = 14 //   "Pi 3A+",	// 14
+	ModelCM3Plus ModelT = 16 //   "CM3+",	// 16
+	Model4B      ModelT = 17 //   "Pi 4",	// 17
+	ModelZero2W  ModelT = 18 //   "Pi Zero2W",	// 18
+	Model400     ModelT = 19 //   "Pi 400",	// 19
+	ModelCM4     ModelT = 20 //   "CM4",	// 20
 )
 
 // MemoryT :  Raspberry Pi Revision :: memeory type
@@ -44,6 +52,9 @@ const (
 	Rpi256MB     MemoryT = 0
 	Rpi512MB     MemoryT = 1
 	Rpi1024MB    MemoryT = 2
+	Rpi2048MB    MemoryT = 3
+	Rpi4096MB    MemoryT = 4
+	Rpi8192MB    MemoryT = 5
 )
 
 type ProcessorT int
@@ -53,6 +64,7 @@ const (
 	Broadcom2835    ProcessorT = 0
 	Broadcom2836    ProcessorT = 1
 	Broadcom2837    ProcessorT = 2
+	Broadcom2711    ProcessorT = 3
 )
 
 type I2CDeviceT int
@@ -100,6 +112,17 @@ func (info *RpiInfoT) I2CDeviceName() (modelname string) {
 	return
 }
 
+// HasBluetooth tells whether the board has Bluetooth. Its PL011 UART
+// (/dev/ttyAMA0) then drives the Bluetooth chip, and the mini UART
+// (/dev/ttyS0) is on the GPIO header, unless an overlay swaps them.
+func (info *RpiInfoT) HasBluetooth() bool {
+	switch info.model {
+	case Model3B, ModelZeroW, Model3BPlus, Model3APlus, Model4B, ModelZero2W, Model400, ModelCM4:
+		return true
+	}
+	return false
+}
+
 var ModelName = map[ModelT]string{
 	ModelA:       "Model A",   //  0
 	ModelB:       "Model B",   //  1
@@ -113,6 +136,13 @@ var ModelName = map[ModelT]string{
 	ModelZero:    "Pi Zero",   // 09
 	ModelCM3:     "CM3",       // 10
 	ModelZeroW:   "Pi Zero-W", // 12
+	Model3BPlus:  "Pi 3+",     // 13
+	Model3APlus:  "Pi 3A+",    // 14
+	ModelCM3Plus: "CM3+",      // 16
+	Model4B:      "Pi 4",      // 17
+	ModelZero2W:  "Pi Zero2W", // 18
+	Model400:     "Pi 400",    // 19
+	ModelCM4:     "CM4",       // 20
 }
 
 var MakerName = map[MakerT]string{
@@ -406,7 +436,8 @@ func getPreRPI2FromRevision(revision string) (info RpiInfoT, err error) {
 // |   |       |              | unknown, B Pi3, Zero                       |
 // | C | 12-15 | Processor    | BCM2835, BCM2836, BCM2837                  |
 // | D | 16-19 | Manufacturer | Sony, Egoman, Embest, unknown, Embest      |
-// | E | 20-22 | Memory size  | 256 MB, 512 MB, 1024 MB                    |
+// | E | 20-22 | Memory size  | 256 MB, 512 MB, 1024 MB, 2048 MB, 4096 MB, |
+// |   |       |              | 8192 MB                                    |
 // | F | 23-23 | encoded flag | (if set, revision is a bit field)          |
 // | G | 24-24 | waranty bit  | (if set, warranty void - Pre Pi2)          |
 // | H | 25-25 | waranty bit  | (if set, warranty void - Post Pi2)         |
@@ -437,7 +468,7 @@ func getPostRPI2FromRevision(revision string) (info RpiInfoT, err error) {
 	mem := (vision & (7 << 20)) >> 20
 	memindex := MemoryT(mem)
 	switch memindex {
-	case Rpi256MB, Rpi512MB, Rpi1024MB, RpiUnknownMB:
+	case Rpi256MB, Rpi512MB, Rpi1024MB, Rpi2048MB, Rpi4096MB, Rpi8192MB, RpiUnknownMB:
 	default:
 		memindex = RpiUnknownMB
 	}
@@ -456,7 +487,7 @@ func getPostRPI2FromRevision(revision string) (info RpiInfoT, err error) {
 	process := (vision & (0xf << 12)) >> 12
 	processindex := ProcessorT(process)
 	switch processindex {
-	case BroadcomUnknown, Broadcom2835, Broadcom2836, Broadcom2837:
+	case BroadcomUnknown, Broadcom2835, Broadcom2836, Broadcom2837, Broadcom2711:
 	default:
 		processindex = BroadcomUnknown
 	}
@@ -465,7 +496,8 @@ func getPostRPI2FromRevision(revision string) (info RpiInfoT, err error) {
 	model := (vision & (0xff << 4)) >> 4
 	modelindex := ModelT(model)
 	switch modelindex {
-	case ModelA, ModelB, ModelAPlus, ModelBPlus, ModelAlpha, ModelCM, Model2B, ModelUnknown, Model3B, ModelZero, ModelCM3, ModelZeroW:
+	case ModelA, ModelB, ModelAPlus, ModelBPlus, ModelAlpha, ModelCM, Model2B, ModelUnknown, Model3B, ModelZero, ModelCM3, ModelZeroW,
+		Model3BPlus, Model3APlus, ModelCM3Plus, Model4B, ModelZero2W, Model400, ModelCM4:
 	default:
 		modelindex = ModelUnknown
 	}
@@ -505,6 +537,8 @@ func GetBoardInfo() (info RpiInfoT, periphereBase int64, err error) {
 		periphereBase = PeripheralBase2836
 	case Broadcom2837:
 		periphereBase = PeripheralBase2837
+	case Broadcom2711:
+		periphereBase = PeripheralBase2711
 	default:
 		err = errors.New("unknown processor")
 	}
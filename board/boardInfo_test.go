@@ -17,6 +17,14 @@ func Test_getPostRPI2FromRevision(t *testing.T) {
 			manufacturer: MakerEmbest, pcbRev: PcbRev1_2, overVolted: false, i2c: I2C_1, revision: 0xa22082}, wantErr: false},
 		{name: "pi B+", revision: "900032", wantInfo: RpiInfoT{model: ModelBPlus, mem: Rpi512MB, processor: Broadcom2835,
 			manufacturer: MakerSony, pcbRev: PcbRev1_2, overVolted: false, i2c: I2C_1, revision: 0x900032}, wantErr: false},
+		{name: "pi 4", revision: "c03111", wantInfo: RpiInfoT{model: Model4B, mem: Rpi4096MB, processor: Broadcom2711,
+			manufacturer: MakerSony, pcbRev: PcbRev1_1, overVolted: false, i2c: I2C_1, revision: 0xc03111}, wantErr: false},
+		{name: "pi 4 2GB", revision: "b03112", wantInfo: RpiInfoT{model: Model4B, mem: Rpi2048MB, processor: Broadcom2711,
+			manufacturer: MakerSony, pcbRev: PcbRev1_2, overVolted: false, i2c: I2C_1, revision: 0xb03112}, wantErr: false},
+		{name: "pi 4 8GB", revision: "d03114", wantInfo: RpiInfoT{model: Model4B, mem: Rpi8192MB, processor: Broadcom2711,
+			manufacturer: MakerSony, pcbRev: PcbRevUnknown, overVolted: false, i2c: I2C_1, revision: 0xd03114}, wantErr: false},
+		{name: "pi 400", revision: "c03130", wantInfo: RpiInfoT{model: Model400, mem: Rpi4096MB, processor: Broadcom2711,
+			manufacturer: MakerSony, pcbRev: PcbRev1, overVolted: false, i2c: I2C_1, revision: 0xc03130}, wantErr: false},
 		{name: "pi B", revision: "0002", wantErr: true},
 	}
 	for _, tt := range tests {
@@ -86,3 +94,26 @@ func TestGetBoardInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestRpiInfoT_HasBluetooth(t *testing.T) {
+	tests := []struct {
+		name  string
+		model ModelT
+		want  bool
+	}{
+		{"pi 2", Model2B, false},
+		{"pi 3", Model3B, true},
+		{"pi zero", ModelZero, false},
+		{"pi zero w", ModelZeroW, true},
+		{"pi 4", Model4B, true},
+		{"cm3", ModelCM3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &RpiInfoT{model: tt.model}
+			if got := info.HasBluetooth(); got != tt.want {
+				t.Errorf("HasBluetooth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package gpio
+
+// FakeSysfs lets the tests of the software buses open pins.
+var FakeSysfs = fakeSysfs
+
+// PulledUp makes p a line with nothing but its pull-up.
+func PulledUp(p *Pin) { fs[p.bcmNumber] = pulledUp{} }
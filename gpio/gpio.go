@@ -1,6 +1,9 @@
 package gpio
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 type Pull uint8
 
@@ -18,40 +21,81 @@ const (
 	outDirection
 )
 
+// Line is a GPIO line as the software buses drive it: the bit-banged
+// I2C and SPI masters, and the driver enable of an RS-485 transceiver.
+// *Pin implements it.
+type Line interface {
+	// Input releases the line.
+	Input()
+	// Output drives the line with the level set last by High or Low.
+	Output()
+	// High and Low set the level of the line, or while it is an input
+	// the level it will drive once an output.
+	High() error
+	Low() error
+	// Read returns the level of an input line, 0 or 1.
+	Read() (uint, error)
+}
+
+var _ Line = (*Pin)(nil)
+
 // Pin represents a single pin, which can be used either for reading or writing
 type Pin struct {
 	bcmNumber uint8
 	direction Direction
+	latch     uint // the level driven as an output
+}
+
+// NewPin exports the BCM_GPIO pin bcm if needed, and opens it. The pin
+// keeps its direction, with its latch low.
+func NewPin(bcm int) (*Pin, error) {
+	if bcm < 0 || bcm > 63 {
+		return nil, fmt.Errorf("gpio: invalid pin %d", bcm)
+	}
+	p := &Pin{bcmNumber: uint8(bcm)}
+	dir, err := gpioExport(p.bcmNumber)
+	if err != nil {
+		return nil, err
+	}
+	p.direction = dir
+	return p, nil
 }
 
 // Set pin as Input
-func (pin Pin) Input() {
+func (pin *Pin) Input() {
 	gpiopinMode(pin.bcmNumber, inDirection)
+	pin.direction = inDirection
 }
 
-// Set pin as Output
-func (pin Pin) Output() {
-	gpiopinMode(pin.bcmNumber, outDirection)
+// Set pin as Output. The latch is set before the pin drives, so it goes
+// straight to the level set last by High or Low.
+func (pin *Pin) Output() {
+	gpioOutput(pin.bcmNumber, pin.latch)
+	pin.direction = outDirection
 }
 
-// High sets the value of an output pin to logic high
-func (p Pin) High() error {
-	if p.direction != outDirection {
-		return errors.New("pin is not configured for output")
-	}
-	return gpioWritePin(p.bcmNumber, 1)
+// High sets the value of an output pin to logic high, or the latch of an
+// input pin
+func (p *Pin) High() error {
+	return p.set(1)
+}
+
+// Low sets the value of an output pin to logic low, or the latch of an
+// input pin
+func (p *Pin) Low() error {
+	return p.set(0)
 }
 
-// Low sets the value of an output pin to logic low
-func (p Pin) Low() error {
+func (p *Pin) set(level uint) error {
+	p.latch = level
 	if p.direction != outDirection {
-		return errors.New("pin is not configured for output")
+		return nil
 	}
-	return gpioWritePin(p.bcmNumber, 0)
+	return gpioWritePin(p.bcmNumber, int(level))
 }
 
 // Toggle a pin state (high -> low -> high)
-func (pin Pin) TogglePin() {
+func (pin *Pin) TogglePin() {
 	value, _ := gpioReadPin(pin.bcmNumber)
 	switch value {
 	case 0:
@@ -61,7 +105,7 @@ func (pin Pin) TogglePin() {
 	}
 }
 
-func (pin Pin) Read() (value uint, err error) {
+func (pin *Pin) Read() (value uint, err error) {
 
 	if pin.direction != inDirection {
 		return 0, errors.New("pin is not configured for input")
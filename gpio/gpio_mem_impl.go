@@ -169,6 +169,12 @@ func gpiopinMode(bcmNumber uint8, direction Direction) {
 	//#define OUT_GPIO(g)   *(gpio.addr + ((g)/10)) |=  (1<<(((g)%10)*3))
 }
 
+// gpioOutput sets the latch of a pin to level, then makes it an output.
+func gpioOutput(bcmNumber uint8, level uint) {
+	gpioWritePin(bcmNumber, level)
+	gpiopinMode(bcmNumber, outDirection)
+}
+
 // gpioWritePin sets a given pin High(1) or Low(0)
 // by setting the clear or set registers respectively
 func gpioWritePin(bcmNumber uint8, state uint) error {
@@ -2,11 +2,23 @@ package gpio
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 )
 
-var fs = [64]*os.File{nil}
+// valueFile is the value file of an exported pin.
+type valueFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+}
+
+var fs [64]valueFile
+
+// sysfs is the root of the gpio sysfs interface, a variable for the tests.
+var sysfs = "/sys/class/gpio"
 
 //ref https://github.com/brian-armstrong/gpio/blob/master/sysfs.go
 
@@ -43,7 +55,7 @@ func gpioWritePin(bcmNumber uint8, state int) error {
 		return fmt.Errorf("invalid output value %d", state)
 	}
 	file := fs[bcmNumber]
-
+	file.Seek(0, 0)
 	_, err := file.Write(buf)
 	return err
 }
@@ -53,7 +65,7 @@ func openPin(bcmNumber uint8, write bool) error {
 	if write {
 		flags = os.O_RDWR
 	}
-	f, err := os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/value", bcmNumber), flags, 0600)
+	f, err := os.OpenFile(fmt.Sprintf("%s/gpio%d/value", sysfs, bcmNumber), flags, 0600)
 	if err != nil {
 		return fmt.Errorf("failed to open gpio %d value file for reading\n", bcmNumber)
 	}
@@ -99,7 +111,7 @@ func gpiopinMode(bcmNumber uint8, direction Direction) {
 
 	*/
 
-	dir, err := os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", bcmNumber), os.O_WRONLY, 0600)
+	dir, err := os.OpenFile(fmt.Sprintf("%s/gpio%d/direction", sysfs, bcmNumber), os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
 		fmt.Printf("failed to open gpio %d direction file for writing\n", bcmNumber)
 		os.Exit(1)
@@ -113,24 +125,63 @@ func gpiopinMode(bcmNumber uint8, direction Direction) {
 	}
 }
 
-func exportGPIO(p Pin) {
-	export, err := os.OpenFile("/sys/class/gpio/export", os.O_WRONLY, 0600)
+// gpioOutput sets the pin as output at level. Writing "high" or "low" to
+// the direction sets the value first, the pin drives no other level.
+func gpioOutput(bcmNumber uint8, level uint) {
+	dir, err := os.OpenFile(fmt.Sprintf("%s/gpio%d/direction", sysfs, bcmNumber), os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
-		fmt.Printf("failed to open gpio export file for writing\n")
+		fmt.Printf("failed to open gpio %d direction file for writing\n", bcmNumber)
 		os.Exit(1)
 	}
+	defer dir.Close()
+
+	if level == 0 {
+		dir.Write([]byte("low"))
+	} else {
+		dir.Write([]byte("high"))
+	}
+}
+
+// gpioExport exports the pin unless it is, opens its value file and
+// returns its direction.
+func gpioExport(bcmNumber uint8) (Direction, error) {
+	name := fmt.Sprintf("%s/gpio%d", sysfs, bcmNumber)
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		if err := exportGPIO(bcmNumber); err != nil {
+			return inDirection, err
+		}
+	}
+	b, err := ioutil.ReadFile(name + "/direction")
+	if err != nil {
+		return inDirection, err
+	}
+	if err := openPin(bcmNumber, true); err != nil {
+		return inDirection, err
+	}
+	if strings.TrimSpace(string(b)) == "out" {
+		return outDirection, nil
+	}
+	return inDirection, nil
+}
+
+func exportGPIO(bcmNumber uint8) error {
+	export, err := os.OpenFile(sysfs+"/export", os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
 	defer export.Close()
-	export.Write([]byte(strconv.Itoa(int(p.Number))))
+	_, err = export.Write([]byte(strconv.Itoa(int(bcmNumber))))
+	return err
 }
 
-func unexportGPIO(p Pin) {
-	export, err := os.OpenFile("/sys/class/gpio/unexport", os.O_WRONLY, 0600)
+func unexportGPIO(bcmNumber uint8) error {
+	export, err := os.OpenFile(sysfs+"/unexport", os.O_WRONLY, 0600)
 	if err != nil {
-		fmt.Printf("failed to open gpio unexport file for writing\n")
-		os.Exit(1)
+		return err
 	}
 	defer export.Close()
-	export.Write([]byte(strconv.Itoa(int(p.Number))))
+	_, err = export.Write([]byte(strconv.Itoa(int(bcmNumber))))
+	return err
 }
 
 func gpioPullMode(bcmNumber uint8, pull Pull) {
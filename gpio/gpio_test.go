@@ -0,0 +1,97 @@
+package gpio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// fakeSysfs points the package to a gpio sysfs tree in a temporary
+// directory, with the pins exported as inputs reading high. It returns
+// the function restoring the package.
+func fakeSysfs(t *testing.T, pins ...int) (root string, restore func()) {
+	root, err := ioutil.TempDir("", "gpio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := sysfs
+	sysfs = root
+	for _, p := range pins {
+		dir := filepath.Join(root, "gpio"+strconv.Itoa(p))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, v := range map[string]string{"direction": "in\n", "value": "1\n"} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(v), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root, func() {
+		for i, f := range fs {
+			if f != nil {
+				f.Close()
+				fs[i] = nil
+			}
+		}
+		sysfs = saved
+		os.RemoveAll(root)
+	}
+}
+
+func TestPin_latch(t *testing.T) {
+	root, restore := fakeSysfs(t, 17)
+	defer restore()
+	read := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(root, "gpio17", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	p, err := NewPin(17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the latch is set while the pin is an input, and driven by Output
+	if err := p.Low(); err != nil {
+		t.Fatalf("Low() on an input = %v", err)
+	}
+	p.Output()
+	if got := read("direction"); got != "low" {
+		t.Errorf("direction = %q, want %q", got, "low")
+	}
+	if err := p.High(); err != nil {
+		t.Fatalf("High() on an output = %v", err)
+	}
+	if got := read("value"); got[0] != '1' {
+		t.Errorf("value = %q, want 1", got)
+	}
+	if _, err := p.Read(); err == nil {
+		t.Error("Read() on an output succeeded")
+	}
+	p.Input()
+	if got := read("direction"); got != "in" {
+		t.Errorf("direction = %q, want %q", got, "in")
+	}
+	p.Output()
+	if got := read("direction"); got != "high" {
+		t.Errorf("direction = %q, want %q", got, "high")
+	}
+
+	if _, err := NewPin(64); err == nil {
+		t.Error("NewPin(64) succeeded")
+	}
+}
+
+// pulledUp is the value file of a line with a pull-up and nothing else
+// driving it once the pin is an input: it reads high whatever was written.
+type pulledUp struct{}
+
+func (pulledUp) Read(b []byte) (int, error)     { return copy(b, "1\n"), nil }
+func (pulledUp) Write(b []byte) (int, error)    { return len(b), nil }
+func (pulledUp) Seek(int64, int) (int64, error) { return 0, nil }
+func (pulledUp) Close() error                   { return nil }
@@ -0,0 +1,47 @@
+package gpio_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/gpio"
+	"github.com/flyingyizi/go-wiringPi/i2c"
+)
+
+// The bit-banged I2C master drives *gpio.Pin lines. Nothing but the
+// pull-ups is on the bus: the address is not acknowledged, with no pin
+// error on the way.
+func TestPin_i2cBitBang(t *testing.T) {
+	root, restore := gpio.FakeSysfs(t, 2, 3)
+	defer restore()
+
+	sda, err := gpio.NewPin(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scl, err := gpio.NewPin(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gpio.PulledUp(sda)
+	gpio.PulledUp(scl)
+	b, err := i2c.NewBitBang(scl, sda, i2c.BitBangConfig{Speed: 1000000, StretchTimeout: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewBitBang() error = %v", err)
+	}
+	d := i2c.NewDevice(b, "bitbang")
+	defer d.Close()
+
+	if err := d.Tx(0x50, []byte{0}, nil); !errors.Is(err, i2c.ErrNACK) {
+		t.Errorf("Tx() error = %v, want %v", err, i2c.ErrNACK)
+	}
+	// the stop released both lines
+	for _, pin := range []string{"gpio2", "gpio3"} {
+		if b, err := ioutil.ReadFile(filepath.Join(root, pin, "direction")); err != nil || string(b) != "in" {
+			t.Errorf("%s direction = %q, %v, want %q", pin, b, err, "in")
+		}
+	}
+}
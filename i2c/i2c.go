@@ -1,16 +1,20 @@
 package i2c
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
 const (
 	/* this is for i2c-dev.c	*/
-	i2cSLAVE = 0x0703 /* Change slave address			*/
+	i2cRETRIES = 0x0701 /* number of times a device address should be polled when not acknowledging */
+	i2cTIMEOUT = 0x0702 /* set timeout in units of 10 ms */
+	i2cSLAVE   = 0x0703 /* Change slave address			*/
 	/* Attn.: Slave address is 7 or 10 bits */
 	i2cSLAVEForce = 0x0706 /* Change slave address			*/
 	/* Attn.: Slave address is 7 or 10 bits */
@@ -19,21 +23,49 @@ const (
 	i2cTENBIT = 0x0704 /* I2C_TENBIT:0 for 7 bit addrs, != 0 for 10 bit	*/
 )
 
+// Bus is an I2C transport. A Device opened with Open drives a kernel
+// adapter and is itself a Bus; NewBitBang returns a software one.
+type Bus interface {
+	// Tx addresses the slave at addr, writes w (if not empty) and then,
+	// after a repeated start, reads len(r) bytes into r (if not empty).
+	// addr can be marked as a 10-bit address with TenBit.
+	Tx(addr int, w, r []byte) error
+	Close() error
+}
+
 // Device represents an active connection to an I2C device.
+//
+// The Context variants of its calls only check the context between tries:
+// before each one and while backing off (see SetRetryPolicy). A try in
+// flight is not interrupted, the adapter timeout bounds it (see
+// SetTimeout), so a call may return up to that timeout past the deadline.
 type Device struct {
 	sync.Mutex
 
 	// File used to represent the bus once it's opened
 	f *os.File
+	// bus is the transport when the device was made by NewDevice, nil for
+	// a kernel adapter. SMBus commands are then emulated with Tx.
+	bus Bus
 	// example:"/dev/i2c-2"
 	name string
 
 	masterIsBigEndian bool // if BigEndian it is true, else false
+
+	// addr is the slave address set by SetAddr, -1 until then
+	addr int
+
+	// retry is the user-space retry policy, nil means a single try
+	retry *RetryPolicy
+
+	// rec logs the transfers, nil unless recorded
+	rec *recorder
 }
 
 // Open opens a connection to an I2C slave device.
 // All devices must be closed once they are no longer in use.
-// TODO(jbd): Support I2C_RETRIES and I2C_TIMEOUT at the driver and implementation level.
+// Use SetRetries and SetTimeout to tune the adapter, and SetRetryPolicy
+// for retries done in user space.
 func Open(device string) (d *Device, err error) {
 	f, err := os.OpenFile(device, os.O_RDWR, os.ModeDevice)
 	if err != nil {
@@ -42,16 +74,44 @@ func Open(device string) (d *Device, err error) {
 
 	m := getEndian()
 
-	return &(Device{f: f, name: device, masterIsBigEndian: m}), err
+	return &(Device{f: f, name: device, addr: -1, masterIsBigEndian: m}), err
+}
+
+// NewDevice returns a Device issuing its transfers on b, name is the bus
+// name reported in errors. Closing the device closes b.
+func NewDevice(b Bus, name string) *Device {
+	return &Device{bus: b, name: name, addr: -1, masterIsBigEndian: getEndian()}
 }
 
+// Close closes the device. When it was recorded, it also reports the
+// first error met while writing the log.
 func (d *Device) Close() (err error) {
 	if d != nil {
-		err = d.f.Close()
+		if d.bus != nil {
+			err = d.bus.Close()
+		} else {
+			err = d.f.Close()
+		}
+		if d.rec != nil && err == nil {
+			err = d.rec.err
+		}
 	}
 	return
 }
 
+// Tx addresses the slave at addr, writes w and then reads len(r) bytes
+// into r in a single transfer with a repeated start in between. It does
+// not change the address set by SetAddr.
+func (d *Device) Tx(addr int, w, r []byte) error {
+	return d.do(context.Background(), "transfer", func(t *txn) error {
+		t.addr, t.w, t.r = addr, w, r
+		if d.bus != nil {
+			return d.bus.Tx(addr, w, r)
+		}
+		return i2cRdwr(d.f, addr, w, r)
+	})
+}
+
 const tenbitMask = 1 << 12
 
 // TenBit marks an I2C address as a 10-bit address.
@@ -60,6 +120,7 @@ func TenBit(addr int) int {
 }
 
 // SetAddr set the I2C slave address for all subsequent I2C device transfers
+// A failure leaves the device open and the previous address in place.
 // For devices that use 10-bit I2C addresses, addr can be marked
 // as a 10-bit address with TenBit.
 // set a 10-bit address example: err = d.SetAddr( i2c.TenBit(0x78))
@@ -70,16 +131,25 @@ func (d *Device) SetAddr(addr int) (err error) {
 	d.Lock()
 	defer d.Unlock()
 
+	if d.bus != nil {
+		// the address goes along with every transfer
+		d.addr = addr
+		return
+	}
 	if tenbit {
 		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.f.Fd(), i2cTENBIT, uintptr(1)); errno != 0 {
-			d.f.Close()
-			return fmt.Errorf("cannot enable the 10-bit address mode : %v", errno)
+			return d.wrap("enable 10-bit addressing", syscall.Errno(errno))
 		}
 	}
 	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.f.Fd(), i2cSLAVE, uintptr(unmasked)); errno != 0 {
-		d.f.Close()
-		return fmt.Errorf("error opening the address (%v) on bus (%s) : %v", addr, d.name, errno)
+		err = syscall.Errno(errno)
+		if err == syscall.EBUSY {
+			// a kernel driver has claimed the address
+			return &Error{Op: "set address", Bus: d.name, Addr: addr, Kind: ErrAddressInUse, Err: err}
+		}
+		return &Error{Op: "set address", Bus: d.name, Addr: addr, Kind: classify(err), Err: err}
 	}
+	d.addr = addr
 	return
 }
 
@@ -87,118 +157,339 @@ func (d *Device) GetName() string {
 	return d.name
 }
 
-//SmbusWriteQuick 	Sends a single bit to the device (in place of the Rd/Wr bit shown in Listing 8.1).
-func (d *Device) SmbusWriteQuick(value uint8) error {
+// SetRetries sets the number of times the adapter retries a transfer
+// when the slave does not acknowledge. The kernel default is 1.
+func (d *Device) SetRetries(n int) error {
 	d.Lock()
 	defer d.Unlock()
 
-	return i2c_smbus_write_quick(d.f, value)
+	if n < 0 {
+		return &Error{Op: "set retries", Bus: d.name, Addr: d.addr, Kind: ErrInvalid, Err: fmt.Errorf("invalid retry count %d", n)}
+	}
+	if d.bus != nil {
+		return d.wrap("set retries", ErrNotSupported)
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.f.Fd(), i2cRETRIES, uintptr(n)); errno != 0 {
+		return d.wrap("set retries", syscall.Errno(errno))
+	}
+	return nil
+}
+
+// SetTimeout sets how long the adapter waits for a transfer to complete.
+// The kernel counts in units of 10ms, so timeout is rounded up to the
+// next 10ms.
+func (d *Device) SetTimeout(timeout time.Duration) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if timeout <= 0 {
+		return &Error{Op: "set timeout", Bus: d.name, Addr: d.addr, Kind: ErrInvalid, Err: fmt.Errorf("invalid timeout %v", timeout)}
+	}
+	ticks := (timeout + 10*time.Millisecond - 1) / (10 * time.Millisecond)
+
+	if d.bus != nil {
+		return d.wrap("set timeout", ErrNotSupported)
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.f.Fd(), i2cTIMEOUT, uintptr(ticks)); errno != 0 {
+		return d.wrap("set timeout", syscall.Errno(errno))
+	}
+	return nil
+}
+
+//SmbusWriteQuick 	Sends a single bit to the device (in place of the Rd/Wr bit shown in Listing 8.1).
+func (d *Device) SmbusWriteQuick(value uint8) error {
+	return d.SmbusWriteQuickContext(context.Background(), value)
+}
+
+// SmbusWriteQuickContext is like SmbusWriteQuick but gives up once ctx
+// is done.
+func (d *Device) SmbusWriteQuickContext(ctx context.Context, value uint8) error {
+	return d.do(ctx, "smbus write quick", func(t *txn) error {
+		if d.bus != nil {
+			return bus_smbus_write_quick(d.bus, d.addr, value)
+		}
+		return i2c_smbus_write_quick(d.f, value)
+	})
 }
 
 //SmbusReadByte   Reads a single byte from the device without specifying a location offset.
 //Uses the same offset as the previously issued command.
 func (d *Device) SmbusReadByte() (data uint8, err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusReadByteContext(context.Background())
+}
 
-	data, err = i2c_smbus_read_byte(d.f)
+// SmbusReadByteContext is like SmbusReadByte but gives up once ctx is
+// done.
+func (d *Device) SmbusReadByteContext(ctx context.Context) (data uint8, err error) {
+	err = d.do(ctx, "smbus read byte", func(t *txn) (err error) {
+		defer func() { t.r = []byte{data} }()
+		if d.bus != nil {
+			data, err = bus_smbus_read_byte(d.bus, d.addr)
+			return
+		}
+		data, err = i2c_smbus_read_byte(d.f)
+		return
+	})
 	return
 }
 
 //SmbusWriteByte  	Sends a single byte to the device at the same memory offset as the previously issued command.
 func (d *Device) SmbusWriteByte(value uint8) error {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusWriteByteContext(context.Background(), value)
+}
 
-	return i2c_smbus_write_byte(d.f, value)
+// SmbusWriteByteContext is like SmbusWriteByte but gives up once ctx is
+// done.
+func (d *Device) SmbusWriteByteContext(ctx context.Context, value uint8) error {
+	return d.do(ctx, "smbus write byte", func(t *txn) error {
+		t.w = []byte{value}
+		if d.bus != nil {
+			return bus_smbus_write_byte(d.bus, d.addr, value)
+		}
+		return i2c_smbus_write_byte(d.f, value)
+	})
 }
 
 //SmbusReadByteData   	Reads a single byte from the device at a specified offset.
 func (d *Device) SmbusReadByteData(command uint8) (data uint8, err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusReadByteDataContext(context.Background(), command)
+}
 
-	data, err = i2c_smbus_read_byte_data(d.f, command)
+// SmbusReadByteDataContext is like SmbusReadByteData but gives up once
+// ctx is done.
+func (d *Device) SmbusReadByteDataContext(ctx context.Context, command uint8) (data uint8, err error) {
+	err = d.do(ctx, "smbus read byte data", func(t *txn) (err error) {
+		defer func() { t.w, t.r = []byte{command}, []byte{data} }()
+		if d.bus != nil {
+			data, err = bus_smbus_read_byte_data(d.bus, d.addr, command)
+			return
+		}
+		data, err = i2c_smbus_read_byte_data(d.f, command)
+		return
+	})
 	return
 }
 
 //SmbusWriteByteData    Sends a single byte to the device at a specified offset.
 func (d *Device) SmbusWriteByteData(command uint8, value uint8) (err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusWriteByteDataContext(context.Background(), command, value)
+}
 
-	return i2c_smbus_write_byte_data(d.f, command, value)
+// SmbusWriteByteDataContext is like SmbusWriteByteData but gives up once
+// ctx is done.
+func (d *Device) SmbusWriteByteDataContext(ctx context.Context, command uint8, value uint8) (err error) {
+	return d.do(ctx, "smbus write byte data", func(t *txn) error {
+		t.w = []byte{command, value}
+		if d.bus != nil {
+			return bus_smbus_write_byte_data(d.bus, d.addr, command, value)
+		}
+		return i2c_smbus_write_byte_data(d.f, command, value)
+	})
 }
 
 //SmbusReadWordData   	Reads 2 bytes from the specified offset.
 func (d *Device) SmbusReadWordData(command uint8) (data uint16, err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusReadWordDataContext(context.Background(), command)
+}
 
-	return i2c_smbus_read_word_data(d.f, command)
+// SmbusReadWordDataContext is like SmbusReadWordData but gives up once
+// ctx is done.
+func (d *Device) SmbusReadWordDataContext(ctx context.Context, command uint8) (data uint16, err error) {
+	err = d.do(ctx, "smbus read word data", func(t *txn) (err error) {
+		defer func() { t.w, t.r = []byte{command}, []byte{byte(data), byte(data >> 8)} }()
+		if d.bus != nil {
+			data, err = bus_smbus_read_word_data(d.bus, d.addr, command)
+			return
+		}
+		data, err = i2c_smbus_read_word_data(d.f, command)
+		return
+	})
+	return
 }
 
 //SmbusWriteWordData    	Sends 2 bytes to the specified offset.
 func (d *Device) SmbusWriteWordData(command uint8, value uint16) (err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusWriteWordDataContext(context.Background(), command, value)
+}
 
-	return i2c_smbus_write_word_data(d.f, command, value)
+// SmbusWriteWordDataContext is like SmbusWriteWordData but gives up once
+// ctx is done.
+func (d *Device) SmbusWriteWordDataContext(ctx context.Context, command uint8, value uint16) (err error) {
+	return d.do(ctx, "smbus write word data", func(t *txn) error {
+		t.w = []byte{command, byte(value), byte(value >> 8)}
+		if d.bus != nil {
+			return bus_smbus_write_word_data(d.bus, d.addr, command, value)
+		}
+		return i2c_smbus_write_word_data(d.f, command, value)
+	})
 }
 
 func (d *Device) SmbusProcessCall(command uint8, value uint16) (data uint16, err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusProcessCallContext(context.Background(), command, value)
+}
 
-	data, err = i2c_smbus_process_call(d.f, command, value)
+// SmbusProcessCallContext is like SmbusProcessCall but gives up once ctx
+// is done.
+func (d *Device) SmbusProcessCallContext(ctx context.Context, command uint8, value uint16) (data uint16, err error) {
+	err = d.do(ctx, "smbus process call", func(t *txn) (err error) {
+		t.w = []byte{command, byte(value), byte(value >> 8)}
+		defer func() { t.r = []byte{byte(data), byte(data >> 8)} }()
+		if d.bus != nil {
+			data, err = bus_smbus_process_call(d.bus, d.addr, command, value)
+			return
+		}
+		data, err = i2c_smbus_process_call(d.f, command, value)
+		return
+	})
 	return
 }
 
 //SmbusReadBlockData   	Reads a block of data from the specified offset.
 func (d *Device) SmbusReadBlockData(command uint8) (block []byte, err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusReadBlockDataContext(context.Background(), command)
+}
 
-	block, err = i2c_smbus_read_block_data(d.f, command)
+// SmbusReadBlockDataContext is like SmbusReadBlockData but gives up once
+// ctx is done.
+func (d *Device) SmbusReadBlockDataContext(ctx context.Context, command uint8) (block []byte, err error) {
+	err = d.do(ctx, "smbus read block data", func(t *txn) (err error) {
+		defer func() { t.w, t.r = []byte{command}, append([]byte{byte(len(block))}, block...) }()
+		if d.bus != nil {
+			block, err = bus_smbus_read_block_data(d.bus, d.addr, command)
+			return
+		}
+		block, err = i2c_smbus_read_block_data(d.f, command)
+		return
+	})
 	return
 }
 
 //SmbusWriteBlockData   	Sends a block of data (<= 32 bytes) to the specified offset.
 func (d *Device) SmbusWriteBlockData(command uint8, length uint8, value []byte) (err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusWriteBlockDataContext(context.Background(), command, length, value)
+}
 
-	return i2c_smbus_write_block_data(d.f, command, length, value)
+// SmbusWriteBlockDataContext is like SmbusWriteBlockData but gives up
+// once ctx is done.
+func (d *Device) SmbusWriteBlockDataContext(ctx context.Context, command uint8, length uint8, value []byte) (err error) {
+	return d.do(ctx, "smbus write block data", func(t *txn) error {
+		t.w = block_data_w(command, length, value)
+		if d.bus != nil {
+			return bus_smbus_write_block_data(d.bus, d.addr, command, length, value)
+		}
+		return i2c_smbus_write_block_data(d.f, command, length, value)
+	})
+}
+
+//SmbusReadI2cBlockData   	Reads length bytes (<= 32) from the specified offset.
+func (d *Device) SmbusReadI2cBlockData(command uint8, length uint8) (block []byte, err error) {
+	return d.SmbusReadI2cBlockDataContext(context.Background(), command, length)
+}
+
+// SmbusReadI2cBlockDataContext is like SmbusReadI2cBlockData but gives up
+// once ctx is done.
+func (d *Device) SmbusReadI2cBlockDataContext(ctx context.Context, command uint8, length uint8) (block []byte, err error) {
+	err = d.do(ctx, "smbus read i2c block data", func(t *txn) (err error) {
+		defer func() {
+			t.w, t.r = []byte{command}, block
+			if err != nil {
+				t.r = make([]byte, i2c_block_len(length))
+			}
+		}()
+		if d.bus != nil {
+			block, err = bus_smbus_read_i2c_block_data(d.bus, d.addr, command, length)
+			return
+		}
+		block, err = i2c_smbus_read_i2c_block_data(d.f, command, length)
+		return
+	})
+	return
 }
 
 func (d *Device) SmbusWriteI2cBlockData(command uint8, length uint8, value []byte) (err error) {
-	d.Lock()
-	defer d.Unlock()
+	return d.SmbusWriteI2cBlockDataContext(context.Background(), command, length, value)
+}
 
-	return i2c_smbus_write_i2c_block_data(d.f, command, length, value)
+// SmbusWriteI2cBlockDataContext is like SmbusWriteI2cBlockData but gives
+// up once ctx is done.
+func (d *Device) SmbusWriteI2cBlockDataContext(ctx context.Context, command uint8, length uint8, value []byte) (err error) {
+	return d.do(ctx, "smbus write i2c block data", func(t *txn) error {
+		t.w = i2c_block_data_w(command, length, value)
+		if d.bus != nil {
+			return bus_smbus_write_i2c_block_data(d.bus, d.addr, command, length, value)
+		}
+		return i2c_smbus_write_i2c_block_data(d.f, command, length, value)
+	})
 }
 
 // SysfsRead reads len(buf) bytes from the device.
 func (d *Device) SysfsRead(buf []byte) error {
-	return i2cTx(d.f, nil, buf)
+	return d.SysfsReadContext(context.Background(), buf)
+}
+
+// SysfsReadContext is like SysfsRead but gives up once ctx is done.
+func (d *Device) SysfsReadContext(ctx context.Context, buf []byte) error {
+	return d.do(ctx, "read", func(t *txn) error {
+		t.r = buf
+		if d.bus != nil {
+			return d.bus.Tx(d.addr, nil, buf)
+		}
+		return i2cTx(d.f, nil, buf)
+	})
 }
 
 // SysfsReadReg is similar to Read but it reads from a register.
 func (d *Device) SysfsReadReg(reg byte, buf []byte) error {
-	return i2cTx(d.f, []byte{reg}, buf)
+	return d.SysfsReadRegContext(context.Background(), reg, buf)
+}
+
+// SysfsReadRegContext is like SysfsReadReg but gives up once ctx is
+// done.
+func (d *Device) SysfsReadRegContext(ctx context.Context, reg byte, buf []byte) error {
+	return d.do(ctx, "read register", func(t *txn) error {
+		t.w, t.r = []byte{reg}, buf
+		if d.bus != nil {
+			return d.bus.Tx(d.addr, []byte{reg}, buf)
+		}
+		return i2cTx(d.f, []byte{reg}, buf)
+	})
 }
 
 // SysfsWrite writes the buffer to the device. If it is required to write to a
 // specific register, the register should be passed as the first byte in the
 // given buffer.
 func (d *Device) SysfsWrite(buf []byte) (err error) {
-	return i2cTx(d.f, buf, nil)
+	return d.SysfsWriteContext(context.Background(), buf)
+}
+
+// SysfsWriteContext is like SysfsWrite but gives up once ctx is done.
+func (d *Device) SysfsWriteContext(ctx context.Context, buf []byte) (err error) {
+	return d.do(ctx, "write", func(t *txn) error {
+		t.w = buf
+		if d.bus != nil {
+			return d.bus.Tx(d.addr, buf, nil)
+		}
+		return i2cTx(d.f, buf, nil)
+	})
 }
 
 // SysfsWriteReg is similar to Write but writes to a register.
 func (d *Device) SysfsWriteReg(reg byte, buf []byte) (err error) {
+	return d.SysfsWriteRegContext(context.Background(), reg, buf)
+}
+
+// SysfsWriteRegContext is like SysfsWriteReg but gives up once ctx is
+// done.
+func (d *Device) SysfsWriteRegContext(ctx context.Context, reg byte, buf []byte) (err error) {
 	// TODO(jbd): Do not allocate, not optimal.
-	return i2cTx(d.f, append([]byte{reg}, buf...), nil)
+	w := append([]byte{reg}, buf...)
+	return d.do(ctx, "write register", func(t *txn) error {
+		t.w = w
+		if d.bus != nil {
+			return d.bus.Tx(d.addr, w, nil)
+		}
+		return i2cTx(d.f, w, nil)
+	})
 }
 
 // ref https://github.com/virtao/GoEndian/blob/master/endian.go
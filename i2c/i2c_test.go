@@ -1,6 +1,18 @@
 package i2c
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
 
 func Test_getEndian(t *testing.T) {
 	tests := []struct {
@@ -18,3 +30,246 @@ func Test_getEndian(t *testing.T) {
 		})
 	}
 }
+
+func Test_smbus_block(t *testing.T) {
+	long := make([]byte, 40)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	tests := []struct {
+		name   string
+		length uint8
+		value  []byte
+		want   []byte
+	}{
+		{name: "exact", length: 2, value: []byte{7, 8}, want: []byte{2, 7, 8}},
+		{name: "short value", length: 4, value: []byte{7, 8}, want: []byte{2, 7, 8}},
+		{name: "too long", length: 40, value: long, want: append([]byte{32}, long[:32]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := smbus_block(tt.length, tt.value)
+			if len(got) != I2cSmBusBlockMax+2 {
+				t.Fatalf("smbus_block() has %d bytes, want a whole i2c_smbus_data", len(got))
+			}
+			if !bytes.Equal(got[:len(tt.want)], tt.want) {
+				t.Errorf("smbus_block() = % x, want % x", got[:len(tt.want)], tt.want)
+			}
+		})
+	}
+}
+
+func Test_do(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		policy    *RetryPolicy
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "no policy", ctx: context.Background(), errs: []error{syscall.ENXIO}, wantCalls: 1, wantErr: syscall.ENXIO},
+		{name: "retry nak", ctx: context.Background(), policy: &RetryPolicy{Attempts: 3, Backoff: time.Microsecond},
+			errs: []error{syscall.ENXIO, syscall.EREMOTEIO, nil}, wantCalls: 3, wantErr: nil},
+		{name: "retry wrapped nak", ctx: context.Background(), policy: &RetryPolicy{Attempts: 2, Backoff: time.Microsecond},
+			errs: []error{&os.PathError{Op: "ioctl", Path: "/dev/i2c-1", Err: syscall.ENXIO}, nil}, wantCalls: 2, wantErr: nil},
+		{name: "give up", ctx: context.Background(), policy: &RetryPolicy{Attempts: 2, Backoff: time.Microsecond},
+			errs: []error{syscall.EAGAIN, syscall.EAGAIN}, wantCalls: 2, wantErr: syscall.EAGAIN},
+		{name: "permanent", ctx: context.Background(), policy: &RetryPolicy{Attempts: 3},
+			errs: []error{syscall.EINVAL}, wantCalls: 1, wantErr: syscall.EINVAL},
+		{name: "capped first backoff", ctx: context.Background(), policy: &RetryPolicy{Attempts: 2, Backoff: time.Hour, MaxBackoff: time.Microsecond},
+			errs: []error{syscall.ENXIO, nil}, wantCalls: 2, wantErr: nil},
+		{name: "expired", ctx: expired, policy: &RetryPolicy{Attempts: 3}, wantCalls: 0, wantErr: ErrTimeout},
+		{name: "cancelled", ctx: cancelled, policy: &RetryPolicy{Attempts: 3}, wantCalls: 0, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Device{retry: tt.policy}
+			calls := 0
+			err := d.do(tt.ctx, "test", func(*txn) error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("do() calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("do() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.ctx == cancelled && errors.Is(err, ErrTimeout) {
+				t.Errorf("do() error = %v, a cancellation is no timeout", err)
+			}
+		})
+	}
+}
+
+func TestDevice_invalid(t *testing.T) {
+	d := NewDevice(funcBus(func(addr int, w, r []byte) error { return nil }), "test")
+	if err := d.SetRetries(-1); !errors.Is(err, ErrInvalid) {
+		t.Errorf("SetRetries(-1) error = %v, want %v", err, ErrInvalid)
+	}
+	if err := d.SetTimeout(0); !errors.Is(err, ErrInvalid) {
+		t.Errorf("SetTimeout(0) error = %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	tests := []struct {
+		name  string
+		errno syscall.Errno
+		want  error
+	}{
+		{name: "missing chip", errno: syscall.ENXIO, want: ErrNACK},
+		{name: "bcm2835 nak", errno: syscall.EREMOTEIO, want: ErrNACK},
+		{name: "arbitration", errno: syscall.EAGAIN, want: ErrArbitrationLost},
+		{name: "busy", errno: syscall.EBUSY, want: ErrBusBusy},
+		{name: "timeout", errno: syscall.ETIMEDOUT, want: ErrTimeout},
+		{name: "not supported", errno: syscall.EOPNOTSUPP, want: ErrNotSupported},
+		{name: "invalid", errno: syscall.EINVAL, want: ErrInvalid},
+	}
+	d := &Device{name: "/dev/i2c-1", addr: 0x40}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.wrap("read", tt.errno)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("wrap(%v) = %v, want kind %v", tt.errno, err, tt.want)
+			}
+			if !errors.Is(err, tt.errno) {
+				t.Errorf("wrap(%v) = %v, does not unwrap to the errno", tt.errno, err)
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Bus != "/dev/i2c-1" || e.Addr != 0x40 || e.Op != "read" {
+				t.Errorf("wrap(%v) = %#v", tt.errno, err)
+			}
+			if err := d.wrap("read", &os.PathError{Op: "read", Path: "/dev/i2c-1", Err: tt.errno}); !errors.Is(err, tt.want) {
+				t.Errorf("wrap of a wrapped %v = %v, want kind %v", tt.errno, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTarget(t *testing.T) {
+	root, err := ioutil.TempDir("", "i2c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer func(old string) { sysfsI2CDevices = old }(sysfsI2CDevices)
+	sysfsI2CDevices = root
+
+	// what the kernel would provide for a slave-24c02 at 0x64 on bus 1
+	os.MkdirAll(filepath.Join(root, "i2c-1"), 0755)
+	os.MkdirAll(filepath.Join(root, "1-1064"), 0755)
+	for _, name := range []string{"i2c-1/new_device", "i2c-1/delete_device"} {
+		ioutil.WriteFile(filepath.Join(root, name), nil, 0644)
+	}
+	mem := filepath.Join(root, "1-1064", "slave-eeprom")
+	ioutil.WriteFile(mem, make([]byte, 256), 0644)
+
+	tg, err := NewTarget(1, 0x64, Slave24c02)
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(root, "i2c-1/new_device")); string(b) != "slave-24c02 0x1064" {
+		t.Errorf("new_device = %q", b)
+	}
+
+	if _, err := tg.WriteAt([]byte{1, 2, 3}, 0x10); err != nil {
+		t.Fatalf("WriteAt() error = %v", err)
+	}
+	// the host writes two registers, and one with the value it has
+	f, _ := os.OpenFile(mem, os.O_RDWR, 0)
+	f.WriteAt([]byte{0xaa, 0xbb}, 0x40)
+	f.WriteAt([]byte{2}, 0x11)
+	f.Close()
+
+	var got []string
+	if err := tg.Changes(func(off int, data []byte) {
+		got = append(got, fmt.Sprintf("%#x:% x", off, data))
+	}); err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	if want := []string{"0x40:aa bb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes() = %v, want %v", got, want)
+	}
+
+	if err := tg.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(root, "i2c-1/delete_device")); string(b) != "0x1064" {
+		t.Errorf("delete_device = %q", b)
+	}
+}
+
+// funcBus is a Bus calling tx for every transfer.
+type funcBus func(addr int, w, r []byte) error
+
+func (f funcBus) Tx(addr int, w, r []byte) error { return f(addr, w, r) }
+func (f funcBus) Close() error                   { return nil }
+
+func TestRecorderReplay(t *testing.T) {
+	var log bytes.Buffer
+	var sent []Record
+	d := NewDevice(funcBus(func(addr int, w, r []byte) error {
+		sent = append(sent, Record{Addr: addr, Write: w, Read: r})
+		if addr != 0x40 {
+			return ErrNACK
+		}
+		for i := range r {
+			r[i] = w[0] + byte(i)
+		}
+		return nil
+	}), "recorded")
+	d.Record(&log)
+	d.SetAddr(0x40)
+	d.SmbusWriteByteData(0x01, 0x02)
+	d.SmbusReadWordData(0x10)
+	d.SetAddr(0x41)
+	d.SetRetryPolicy(&RetryPolicy{Attempts: 2})
+	d.SmbusReadByteData(0x20)
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	recs, err := ReadRecords(bytes.NewReader(log.Bytes()))
+	if err != nil || len(recs) != 4 {
+		t.Fatalf("ReadRecords() = %v, %v", recs, err)
+	}
+	for i, rec := range recs {
+		if s := sent[i]; rec.Addr != s.Addr || !bytes.Equal(rec.Write, s.Write) || len(rec.Read) != len(s.Read) {
+			t.Errorf("ReadRecords()[%d] = %+v, the bus saw %+v", i, rec, s)
+		}
+	}
+	if recs[1].Op != "smbus read word data" || recs[1].Direction() != "write-read" || !bytes.Equal(recs[1].Read, []byte{0x10, 0x11}) {
+		t.Errorf("ReadRecords()[1] = %+v", recs[1])
+	}
+
+	b, err := NewReplay(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplay() error = %v", err)
+	}
+	d = NewDevice(b, "replay")
+	d.SetAddr(0x40)
+	if err := d.SmbusWriteByteData(0x01, 0x02); err != nil {
+		t.Errorf("SmbusWriteByteData() error = %v", err)
+	}
+	if got, err := d.SmbusReadWordData(0x10); err != nil || got != 0x1110 {
+		t.Errorf("SmbusReadWordData() = %#x, %v, want 0x1110", got, err)
+	}
+	d.SetAddr(0x41)
+	if _, err := d.SmbusReadByteData(0x21); err == nil {
+		t.Errorf("SmbusReadByteData(0x21) replayed a transfer that was not recorded")
+	}
+	d.SetRetryPolicy(&RetryPolicy{Attempts: 2})
+	if _, err := d.SmbusReadByteData(0x20); !errors.Is(err, ErrNACK) {
+		t.Errorf("SmbusReadByteData() error = %v, want %v", err, ErrNACK)
+	}
+	if err := d.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package i2c
+
+//5. BIT-BANGED MASTER
+//A software master on two GPIO lines, for boards where the hardware I2C
+//pins are taken. Both lines need a pull-up resistor: the master never
+//drives a line high, it releases it by switching the pin to input
+//(open-drain emulation), so a slave can stretch the clock by holding SCL low.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/gpio"
+)
+
+// BitBangConfig holds the settings of a bit-banged master.
+type BitBangConfig struct {
+	// Speed is the SCL frequency in Hz, 0 means 100kHz (standard mode).
+	Speed int
+	// StretchTimeout is how long a slave may hold SCL low, 0 means 25ms
+	// (the SMBus clock low timeout).
+	StretchTimeout time.Duration
+}
+
+const (
+	defaultBitBangSpeed   = 100000
+	defaultStretchTimeout = 25 * time.Millisecond
+)
+
+type bitBang struct {
+	mu sync.Mutex
+
+	scl, sda gpio.Line
+	half     time.Duration // half an SCL period
+	stretch  time.Duration
+}
+
+// NewBitBang returns a Bus driving scl and sda in software, *gpio.Pin
+// lines for instance. Wrap it with NewDevice to get the same API as a
+// kernel adapter.
+func NewBitBang(scl, sda gpio.Line, cfg BitBangConfig) (Bus, error) {
+	if cfg.Speed <= 0 {
+		cfg.Speed = defaultBitBangSpeed
+	}
+	if cfg.StretchTimeout <= 0 {
+		cfg.StretchTimeout = defaultStretchTimeout
+	}
+	b := &bitBang{
+		scl:     scl,
+		sda:     sda,
+		half:    time.Second / time.Duration(2*cfg.Speed),
+		stretch: cfg.StretchTimeout,
+	}
+	scl.Input()
+	sda.Input()
+	if err := b.waitSCL(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Tx implements Bus.
+func (b *bitBang) Tx(addr int, w, r []byte) (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err = b.start(); err != nil {
+		return
+	}
+	defer func() {
+		if e := b.stop(); err == nil {
+			err = e
+		}
+	}()
+
+	// a 10-bit read is addressed with a write first, the read header
+	// after the repeated start only carries A9 and A8
+	tenbit := addr&tenbitMask == tenbitMask
+	if len(w) > 0 || len(r) == 0 || tenbit {
+		if err = b.address(addr, false); err != nil {
+			return
+		}
+		for _, c := range w {
+			if err = b.writeByte(c); err != nil {
+				return
+			}
+		}
+		if len(r) == 0 {
+			return
+		}
+		if err = b.restart(); err != nil {
+			return
+		}
+	}
+
+	if err = b.address(addr, true); err != nil {
+		return
+	}
+	for i := range r {
+		// the master does not acknowledge the last byte
+		if r[i], err = b.readByte(i < len(r)-1); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Close implements Bus, it releases both lines.
+func (b *bitBang) Close() error {
+	b.scl.Input()
+	b.sda.Input()
+	return nil
+}
+
+// address sends the address byte(s) of addr with the R/W bit. A 10-bit
+// read header is only valid after a repeated start following the write
+// header and A7..A0.
+func (b *bitBang) address(addr int, read bool) error {
+	var rw byte
+	if read {
+		rw = 1
+	}
+	if addr&tenbitMask != tenbitMask {
+		return b.writeByte(byte(addr<<1) | rw)
+	}
+	// 10-bit: 11110 A9 A8 R/W, then A7..A0 for the write
+	addr &= tenbitMask - 1
+	if err := b.writeByte(0xf0 | byte(addr>>7)&0x06 | rw); err != nil {
+		return err
+	}
+	if read {
+		return nil
+	}
+	return b.writeByte(byte(addr))
+}
+
+func (b *bitBang) delay() {
+	// time.Sleep is far too coarse for microsecond delays
+	for start := time.Now(); time.Since(start) < b.half; {
+	}
+}
+
+// low pulls the line low. The latch is set first: switching to output
+// with the latch high would drive the line high, a glitch a slave can
+// take for a START or a STOP.
+func (b *bitBang) low(p gpio.Line) error {
+	if err := p.Low(); err != nil {
+		return err
+	}
+	p.Output()
+	return nil
+}
+
+func (b *bitBang) level(p gpio.Line) bool {
+	v, _ := p.Read()
+	return v != 0
+}
+
+// releaseSCL lets SCL go high and waits for slaves stretching the clock.
+func (b *bitBang) releaseSCL() error {
+	b.scl.Input()
+	return b.waitSCL()
+}
+
+func (b *bitBang) waitSCL() error {
+	if b.level(b.scl) {
+		return nil
+	}
+	for deadline := time.Now().Add(b.stretch); time.Now().Before(deadline); {
+		if b.level(b.scl) {
+			return nil
+		}
+	}
+	return ErrTimeout
+}
+
+func (b *bitBang) start() error {
+	b.sda.Input()
+	if err := b.releaseSCL(); err != nil {
+		return ErrBusBusy
+	}
+	if !b.level(b.sda) {
+		return ErrBusBusy
+	}
+	if err := b.low(b.sda); err != nil {
+		return err
+	}
+	b.delay()
+	return b.low(b.scl)
+}
+
+func (b *bitBang) restart() error {
+	b.sda.Input()
+	b.delay()
+	if err := b.releaseSCL(); err != nil {
+		return err
+	}
+	b.delay()
+	if err := b.low(b.sda); err != nil {
+		return err
+	}
+	b.delay()
+	return b.low(b.scl)
+}
+
+func (b *bitBang) stop() error {
+	if err := b.low(b.sda); err != nil {
+		return err
+	}
+	b.delay()
+	if err := b.releaseSCL(); err != nil {
+		return err
+	}
+	b.delay()
+	b.sda.Input()
+	b.delay()
+	if !b.level(b.sda) {
+		return ErrArbitrationLost
+	}
+	return nil
+}
+
+func (b *bitBang) writeBit(bit bool) error {
+	if bit {
+		b.sda.Input()
+	} else if err := b.low(b.sda); err != nil {
+		return err
+	}
+	b.delay()
+	if err := b.releaseSCL(); err != nil {
+		return err
+	}
+	// somebody else pulls SDA low while we send a 1
+	lost := bit && !b.level(b.sda)
+	b.delay()
+	if err := b.low(b.scl); err != nil {
+		return err
+	}
+	if lost {
+		return ErrArbitrationLost
+	}
+	return nil
+}
+
+func (b *bitBang) readBit() (bool, error) {
+	b.sda.Input()
+	b.delay()
+	if err := b.releaseSCL(); err != nil {
+		return false, err
+	}
+	bit := b.level(b.sda)
+	b.delay()
+	return bit, b.low(b.scl)
+}
+
+func (b *bitBang) writeByte(c byte) error {
+	for i := 7; i >= 0; i-- {
+		if err := b.writeBit(c&(1<<uint(i)) != 0); err != nil {
+			return err
+		}
+	}
+	nack, err := b.readBit()
+	if err != nil {
+		return err
+	}
+	if nack {
+		return ErrNACK
+	}
+	return nil
+}
+
+func (b *bitBang) readByte(ack bool) (c byte, err error) {
+	for i := 0; i < 8; i++ {
+		bit, err := b.readBit()
+		if err != nil {
+			return 0, err
+		}
+		c <<= 1
+		if bit {
+			c |= 1
+		}
+	}
+	return c, b.writeBit(!ack)
+}
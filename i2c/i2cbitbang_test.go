@@ -0,0 +1,316 @@
+package i2c
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	lineSCL = iota
+	lineSDA
+)
+
+// simWire is an open-drain SCL/SDA pair with pull-ups, shared by the
+// simulated master pins and a simulated slave.
+type simWire struct {
+	masterLow [2]bool
+	output    [2]bool
+	latchLow  [2]bool
+	// drivenHigh counts the times the master drove a line high, which
+	// open-drain lines must never be
+	drivenHigh int
+	slave      *simSlave
+	scl, sda   bool
+}
+
+func newSimWire(s *simSlave) *simWire {
+	return &simWire{slave: s, scl: true, sda: true}
+}
+
+func (w *simWire) level(line int) bool {
+	if line == lineSCL {
+		return !w.masterLow[lineSCL] && w.slave.stretching == 0
+	}
+	return !w.masterLow[lineSDA] && !w.slave.sda
+}
+
+// update reports the bus conditions seen since the last change to the slave.
+func (w *simWire) update() {
+	scl, sda := w.level(lineSCL), w.level(lineSDA)
+	switch {
+	case w.scl && scl && w.sda && !sda:
+		w.slave.start()
+	case w.scl && scl && !w.sda && sda:
+		w.slave.stop()
+	case !w.scl && scl:
+		w.slave.rise(sda)
+	case w.scl && !scl:
+		w.slave.fall()
+	}
+	w.scl, w.sda = w.level(lineSCL), w.level(lineSDA)
+}
+
+type simPin struct {
+	w    *simWire
+	line int
+}
+
+func (p simPin) Input() {
+	p.w.output[p.line], p.w.masterLow[p.line] = false, false
+	p.w.update()
+}
+
+func (p simPin) Output() {
+	p.w.output[p.line] = true
+	if !p.w.latchLow[p.line] {
+		p.w.drivenHigh++
+	}
+	p.w.masterLow[p.line] = p.w.latchLow[p.line]
+	p.w.update()
+}
+
+func (p simPin) Low() error {
+	p.w.latchLow[p.line] = true
+	if p.w.output[p.line] {
+		p.w.masterLow[p.line] = true
+		p.w.update()
+	}
+	return nil
+}
+
+func (p simPin) High() error {
+	p.w.latchLow[p.line] = false
+	if p.w.output[p.line] {
+		p.w.drivenHigh++
+		p.w.masterLow[p.line] = false
+		p.w.update()
+	}
+	return nil
+}
+
+func (p simPin) Read() (uint, error) {
+	if p.line == lineSCL && p.w.slave.stretching > 0 {
+		if p.w.slave.stretching--; p.w.slave.stretching == 0 {
+			p.w.update()
+		}
+		return 0, nil
+	}
+	if p.w.level(p.line) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+const (
+	slIdle = iota
+	slAddr
+	slAddr2 // A7..A0 of a 10-bit address
+	slWrite
+	slRead
+)
+
+// simSlave is a register file answering at addr: the first byte written
+// selects the register, further bytes are written from there on and reads
+// go on from the selected register.
+type simSlave struct {
+	addr    int
+	tenbit  bool
+	regs    [256]byte
+	reg     byte
+	stretch int // SCL reads the slave holds the clock after each ack
+
+	// addressed is set by the write header of a 10-bit address, the
+	// read header only matches after it
+	addressed  bool
+	state      int
+	bit        int
+	shift      byte
+	first      bool
+	read       bool
+	sda        bool
+	stretching int
+}
+
+// start skips the SCL falling edge that ends the start condition.
+func (s *simSlave) start() { s.state, s.bit, s.shift, s.sda = slAddr, -1, 0, false }
+func (s *simSlave) stop()  { s.state, s.sda, s.addressed = slIdle, false, false }
+
+// match tells whether the address byte received is the slave's.
+func (s *simSlave) match() bool {
+	if !s.tenbit {
+		return int(s.shift>>1) == s.addr
+	}
+	if s.shift&0xf8 != 0xf0 || int(s.shift>>1&3) != s.addr>>8&3 {
+		return false
+	}
+	return s.shift&1 == 0 || s.addressed
+}
+
+func (s *simSlave) rise(sda bool) {
+	switch s.state {
+	case slAddr, slAddr2, slWrite:
+		if s.bit < 8 {
+			s.shift <<= 1
+			if sda {
+				s.shift |= 1
+			}
+		}
+	case slRead:
+		if s.bit == 8 && sda {
+			// master NACK, wait for the stop
+			s.state = slIdle
+		}
+	}
+}
+
+func (s *simSlave) fall() {
+	switch s.state {
+	case slAddr, slAddr2, slWrite:
+		if s.bit < 8 {
+			if s.bit++; s.bit < 8 {
+				return
+			}
+			if s.state == slAddr {
+				if !s.match() {
+					s.state = slIdle
+					return
+				}
+				s.read, s.first = s.shift&1 == 1, true
+			} else if s.state == slAddr2 {
+				if s.shift != byte(s.addr) {
+					s.state = slIdle
+					return
+				}
+				s.addressed = true
+			} else if s.first {
+				s.reg, s.first = s.shift, false
+			} else {
+				s.regs[s.reg] = s.shift
+				s.reg++
+			}
+			s.sda, s.stretching = true, s.stretch
+			return
+		}
+		// end of the ack slot
+		s.sda, s.bit, s.shift = false, 0, 0
+		if s.state == slAddr && s.tenbit && !s.read {
+			s.state = slAddr2
+		} else if s.state == slAddr || s.state == slAddr2 {
+			s.state = slWrite
+			if s.read {
+				s.state = slRead
+				s.sda = s.regs[s.reg]&0x80 == 0
+			}
+		}
+	case slRead:
+		if s.bit < 8 {
+			if s.bit++; s.bit < 8 {
+				s.sda = s.regs[s.reg]&(0x80>>uint(s.bit)) == 0
+			} else {
+				s.sda = false
+				s.reg++
+			}
+			return
+		}
+		s.bit = 0
+		s.sda = s.regs[s.reg]&0x80 == 0
+	}
+}
+
+func newSimBitBang(t *testing.T, s *simSlave, cfg BitBangConfig) (*Device, *simWire) {
+	w := newSimWire(s)
+	b, err := NewBitBang(simPin{w, lineSCL}, simPin{w, lineSDA}, cfg)
+	if err != nil {
+		t.Fatalf("NewBitBang() error = %v", err)
+	}
+	return NewDevice(b, "bitbang"), w
+}
+
+func TestBitBang(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    int
+		tenbit  bool
+		stretch int
+	}{
+		{name: "plain", addr: 0x42},
+		{name: "clock stretching", addr: 0x42, stretch: 5},
+		{name: "10-bit", addr: 0x2a5, tenbit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &simSlave{addr: tt.addr, tenbit: tt.tenbit, stretch: tt.stretch}
+			d, w := newSimBitBang(t, s, BitBangConfig{Speed: 10000000})
+			defer d.Close()
+
+			addr := tt.addr
+			if tt.tenbit {
+				addr = TenBit(addr)
+			}
+			if err := d.SetAddr(addr); err != nil {
+				t.Fatalf("SetAddr() error = %v", err)
+			}
+			if err := d.SmbusWriteByteData(0x10, 0xab); err != nil {
+				t.Fatalf("SmbusWriteByteData() error = %v", err)
+			}
+			if s.regs[0x10] != 0xab {
+				t.Errorf("register 0x10 = %#x, want 0xab", s.regs[0x10])
+			}
+			if got, err := d.SmbusReadByteData(0x10); err != nil || got != 0xab {
+				t.Errorf("SmbusReadByteData() = %#x, %v, want 0xab", got, err)
+			}
+			if err := d.SmbusWriteWordData(0x20, 0x1234); err != nil {
+				t.Fatalf("SmbusWriteWordData() error = %v", err)
+			}
+			if s.regs[0x20] != 0x34 || s.regs[0x21] != 0x12 {
+				t.Errorf("registers 0x20-0x21 = % x, want 34 12", s.regs[0x20:0x22])
+			}
+			buf := make([]byte, 3)
+			if err := d.SysfsReadReg(0x1f, buf); err != nil {
+				t.Fatalf("SysfsReadReg() error = %v", err)
+			}
+			if buf[0] != 0 || buf[1] != 0x34 || buf[2] != 0x12 {
+				t.Errorf("SysfsReadReg() = % x, want 00 34 12", buf)
+			}
+			// a read without a write phase goes on after the last register read
+			s.regs[0x22] = 0x5c
+			if got, err := d.SmbusReadByte(); err != nil || got != 0x5c {
+				t.Errorf("SmbusReadByte() = %#x, %v, want 0x5c", got, err)
+			}
+			if w.drivenHigh != 0 {
+				t.Errorf("master drove a line high %d times", w.drivenHigh)
+			}
+		})
+	}
+}
+
+func TestBitBang_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    int
+		stretch int
+		cfg     BitBangConfig
+		want    error
+	}{
+		{name: "missing chip", addr: 0x43, cfg: BitBangConfig{Speed: 10000000}, want: ErrNACK},
+		{name: "stuck clock", addr: 0x42, stretch: 1 << 30,
+			cfg: BitBangConfig{Speed: 10000000, StretchTimeout: time.Millisecond}, want: ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := newSimBitBang(t, &simSlave{addr: 0x42, stretch: tt.stretch}, tt.cfg)
+			defer d.Close()
+
+			d.SetAddr(tt.addr)
+			err := d.SmbusWriteByteData(0x10, 0xab)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("SmbusWriteByteData() error = %v, want %v", err, tt.want)
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Bus != "bitbang" || e.Addr != tt.addr {
+				t.Errorf("SmbusWriteByteData() error = %#v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package i2c
+
+//7. BSC SLAVE
+//The BCM2835, BCM2836, BCM2837 and BCM2711 have an I2C/SPI slave
+//peripheral, the BSC slave, that no kernel driver claims. ServeBSC maps its
+//registers through /dev/mem and answers the host from Go: the host writes
+//land in a receive FIFO, and its reads are taken from a transmit FIFO of 16
+//bytes that is filled ahead. The peripheral can't stretch the clock, so a
+//read is answered with what was queued before it: a host reading right
+//after a write (e.g. with a repeated start) must leave the target time to
+//queue the answer to the write, about a millisecond.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/flyingyizi/go-wiringPi/board"
+)
+
+// Handler answers the requests of the host to a target served by
+// ServeBSC. Its methods are called from the serving goroutine only.
+type Handler interface {
+	// Write is called with the bytes of every write of the host.
+	Write(p []byte)
+	// Read returns the bytes of the next read of the host, n is how many
+	// of the ones returned last the host did read. It is called first
+	// with 0, then after every write of the host and every read. Only the
+	// first 16 bytes are queued.
+	Read(n int) []byte
+}
+
+// BSC slave registers, as uint32 indexes.
+const (
+	bscDR  = 0x00 / 4 // data
+	bscRSR = 0x04 / 4 // operation status and error clear
+	bscSLV = 0x08 / 4 // slave address
+	bscCR  = 0x0c / 4 // control
+	bscFR  = 0x10 / 4 // flags
+)
+
+// bscCR bits
+const (
+	bscEN  = 1 << 0
+	bscI2C = 1 << 2
+	bscBRK = 1 << 7 // stop the operation and clear the FIFOs
+	bscTXE = 1 << 8
+	bscRXE = 1 << 9
+)
+
+// bscFR bits
+const (
+	bscTXBUSY = 1 << 0
+	bscRXFE   = 1 << 1
+	bscTXFF   = 1 << 2
+	bscRXBUSY = 1 << 5
+)
+
+func bscTXLevel(fr uint32) int { return int(fr >> 6 & 0x1f) }
+
+const (
+	bscOffset  = 0x214000 // from the peripheral base
+	gpioOffset = 0x200000
+	bscPoll    = 100 * time.Microsecond
+	fselALT3   = 7
+)
+
+// bscRegs are the registers of the BSC slave.
+type bscRegs interface {
+	get(reg int) uint32
+	set(reg int, v uint32)
+}
+
+// regBlock is a mapped register block.
+type regBlock []uint32
+
+func (m regBlock) get(reg int) uint32    { return atomic.LoadUint32(&m[reg]) }
+func (m regBlock) set(reg int, v uint32) { atomic.StoreUint32(&m[reg], v) }
+
+// ServeBSC answers the host addressing addr (7-bit) on the BSC slave pins
+// with h, until ctx is done. The pins are GPIO 18 (SDA) and 19 (SCL), or
+// 10 and 11 on a BCM2711; they are switched to the slave and back to
+// their previous function on return. It needs root, for /dev/mem.
+func ServeBSC(ctx context.Context, addr int, h Handler) error {
+	if addr < 0 || addr > 0x7f {
+		return &Error{Op: "serve", Bus: "bsc", Addr: addr, Kind: ErrInvalid, Err: fmt.Errorf("invalid address %#x", addr)}
+	}
+	_, base, err := board.GetBoardInfo()
+	if err != nil {
+		return err
+	}
+	sda, scl := 18, 19
+	if base == board.PeripheralBase2711 {
+		sda, scl = 10, 11
+	}
+
+	f, err := os.OpenFile("/dev/mem", os.O_RDWR|os.O_SYNC, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bsc, unmapBSC, err := mmapRegs(f, base+bscOffset)
+	if err != nil {
+		return err
+	}
+	defer unmapBSC()
+	gpio, unmapGPIO, err := mmapRegs(f, base+gpioOffset)
+	if err != nil {
+		return err
+	}
+	defer unmapGPIO()
+
+	for _, pin := range []int{sda, scl} {
+		old := setFsel(gpio, pin, fselALT3)
+		defer setFsel(gpio, pin, old)
+	}
+	return serveBSC(ctx, bsc, addr, h)
+}
+
+func mmapRegs(f *os.File, addr int64) (regBlock, func(), error) {
+	b, err := syscall.Mmap(int(f.Fd()), addr, 4096, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return regBlock((*[1024]uint32)(unsafe.Pointer(&b[0]))[:]), func() { syscall.Munmap(b) }, nil
+}
+
+// setFsel sets the function of a GPIO pin, and returns the one it had.
+func setFsel(gpio regBlock, pin int, fsel uint32) uint32 {
+	reg, shift := pin/10, uint(pin%10)*3
+	v := gpio.get(reg)
+	gpio.set(reg, v&^(7<<shift)|fsel<<shift)
+	return v >> shift & 7
+}
+
+// serveBSC is ServeBSC on the registers r.
+func serveBSC(ctx context.Context, r bscRegs, addr int, h Handler) error {
+	r.set(bscCR, bscBRK)
+	r.set(bscCR, 0)
+	r.set(bscRSR, 0)
+	r.set(bscSLV, uint32(addr))
+	r.set(bscCR, bscEN|bscI2C|bscTXE|bscRXE)
+	defer func() {
+		r.set(bscCR, bscBRK)
+		r.set(bscCR, 0)
+	}()
+
+	queued := queueBSC(r, h.Read(0))
+	var msg []byte
+	for {
+		fr := r.get(bscFR)
+		for fr&bscRXFE == 0 {
+			msg = append(msg, byte(r.get(bscDR)))
+			fr = r.get(bscFR)
+		}
+		switch {
+		case fr&bscRXBUSY != 0:
+			// a write in progress
+		case len(msg) > 0:
+			h.Write(msg)
+			msg = nil
+			queued = queueBSC(r, h.Read(0))
+		case fr&bscTXBUSY == 0 && bscTXLevel(fr) < queued:
+			queued = queueBSC(r, h.Read(queued-bscTXLevel(fr)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		time.Sleep(bscPoll)
+	}
+}
+
+// queueBSC replaces what the transmit FIFO holds with p, and returns how
+// many bytes it queued.
+func queueBSC(r bscRegs, p []byte) int {
+	// the break clears both FIFOs, the receive one is empty and idle here
+	cr := r.get(bscCR)
+	r.set(bscCR, cr|bscBRK)
+	r.set(bscCR, cr)
+	n := 0
+	for ; n < len(p) && r.get(bscFR)&bscTXFF == 0; n++ {
+		r.set(bscDR, uint32(p[n]))
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package i2c
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// The BSC loopback test has a master adapter of this Pi address the BSC
+// slave of the same Pi, its pins wired to the master's ones (e.g. GPIO 2
+// and 3 to 18 and 19, or to 10 and 11 on a Pi 4). It needs root and the
+// master adapter device in I2C_BSC_LOOPBACK, and is skipped otherwise:
+//
+//	sudo I2C_BSC_LOOPBACK=/dev/i2c-1 go test -run BSC_loopback ./i2c
+
+func TestServeBSC_loopback(t *testing.T) {
+	dev := os.Getenv("I2C_BSC_LOOPBACK")
+	if dev == "" {
+		t.Skip("I2C_BSC_LOOPBACK names no master adapter wired to the BSC slave")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("serving the BSC slave needs root")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- ServeBSC(ctx, 0x42, &regFile{}) }()
+	defer func() {
+		cancel()
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("ServeBSC() = %v", err)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	d, err := Open(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.SetAddr(0x42); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SysfsWrite([]byte{0x10, 1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := d.SysfsWrite([]byte{0x10}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	got := make([]byte, 4)
+	if err := d.SysfsRead(got); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 2, 3, 4}; !bytes.Equal(got, want) {
+		t.Errorf("read % x, want % x", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package i2c
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simBSC is the BSC slave peripheral seen by serveBSC, with a host writing
+// and reading its FIFOs.
+type simBSC struct {
+	sync.Mutex
+	rx, tx []byte
+	cr     uint32
+	slv    uint32
+	breaks int // the times the FIFOs were cleared
+}
+
+func (s *simBSC) get(reg int) uint32 {
+	s.Lock()
+	defer s.Unlock()
+
+	switch reg {
+	case bscDR:
+		c := s.rx[0]
+		s.rx = s.rx[1:]
+		return uint32(c)
+	case bscCR:
+		return s.cr
+	case bscFR:
+		fr := uint32(len(s.tx)) << 6
+		if len(s.rx) == 0 {
+			fr |= bscRXFE
+		}
+		if len(s.tx) == 16 {
+			fr |= bscTXFF
+		}
+		return fr
+	}
+	return 0
+}
+
+func (s *simBSC) set(reg int, v uint32) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch reg {
+	case bscDR:
+		s.tx = append(s.tx, byte(v))
+	case bscCR:
+		if v&bscBRK != 0 {
+			s.rx, s.tx = nil, nil
+			s.breaks++
+		}
+		s.cr = v
+	case bscSLV:
+		s.slv = v
+	}
+}
+
+// write is a write of the host, it returns once the target queued the
+// answer.
+func (s *simBSC) write(t *testing.T, p ...byte) {
+	s.Lock()
+	s.rx = append(s.rx, p...)
+	n := s.breaks
+	s.Unlock()
+	s.wait(t, n)
+}
+
+// read is a read of n bytes of the host, it returns once the target
+// queued the next ones.
+func (s *simBSC) read(t *testing.T, n int) []byte {
+	s.Lock()
+	p := append([]byte(nil), s.tx[:n]...)
+	s.tx = s.tx[n:]
+	b := s.breaks
+	s.Unlock()
+	s.wait(t, b)
+	return p
+}
+
+func (s *simBSC) wait(t *testing.T, breaks int) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.Lock()
+		done := s.breaks > breaks
+		s.Unlock()
+		if done {
+			return
+		}
+	}
+	t.Fatal("the target did not answer")
+}
+
+// regFile is a Handler answering as a register file: the first byte
+// written selects the register, the next ones are written from there on
+// and reads go on from the selected register.
+type regFile struct {
+	regs [256]byte
+	ptr  byte
+}
+
+func (h *regFile) Write(p []byte) {
+	h.ptr = p[0]
+	for _, c := range p[1:] {
+		h.regs[h.ptr] = c
+		h.ptr++
+	}
+}
+
+func (h *regFile) Read(n int) []byte {
+	h.ptr += byte(n)
+	p := make([]byte, 16)
+	for i := range p {
+		p[i] = h.regs[h.ptr+byte(i)]
+	}
+	return p
+}
+
+func TestServeBSC(t *testing.T) {
+	s := &simBSC{}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	b := s.breaks
+	go func() { errc <- serveBSC(ctx, s, 0x42, &regFile{}) }()
+	s.wait(t, b)
+
+	s.Lock()
+	if s.slv != 0x42 || s.cr != bscEN|bscI2C|bscTXE|bscRXE {
+		t.Errorf("slave address %#x, control %#x", s.slv, s.cr)
+	}
+	s.Unlock()
+
+	s.write(t, 0x10, 1, 2, 3, 4)
+	s.write(t, 0x10)
+	if got := s.read(t, 3); !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("read % x, want 01 02 03", got)
+	}
+	if got := s.read(t, 2); !bytes.Equal(got, []byte{4, 0}) {
+		t.Errorf("read % x, want 04 00", got)
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("serveBSC() = %v, want %v", err, context.Canceled)
+	}
+	if s.cr != 0 {
+		t.Errorf("control %#x after serving", s.cr)
+	}
+}
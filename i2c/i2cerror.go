@@ -0,0 +1,100 @@
+package i2c
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// Error kinds. Every error returned by a Device operation is an *Error
+// whose Kind is one of these (or nil when the failure fits none of them),
+// so callers can test for them with errors.Is.
+// see https://www.kernel.org/doc/Documentation/i2c/fault-codes
+var (
+	// ErrNACK : the slave did not acknowledge its address or a data byte,
+	// usually because no chip answers at that address.
+	ErrNACK = errors.New("no acknowledge")
+	// ErrBusBusy : the bus stayed busy, e.g. SDA or SCL held low.
+	ErrBusBusy = errors.New("bus busy")
+	// ErrArbitrationLost : another master won the bus during the transfer.
+	ErrArbitrationLost = errors.New("arbitration lost")
+	// ErrAddressInUse : a kernel driver already claims the slave address.
+	ErrAddressInUse = errors.New("address in use")
+	// ErrTimeout : the transfer did not complete in time, or the deadline
+	// of its context passed before it could succeed. A cancelled context
+	// has no kind, the error wraps context.Canceled.
+	ErrTimeout = errors.New("timeout")
+	// ErrNotSupported : the adapter does not implement the operation.
+	ErrNotSupported = errors.New("operation not supported")
+	// ErrInvalid : a parameter was rejected before any transfer started.
+	ErrInvalid = errors.New("invalid argument")
+)
+
+// Error records a failed operation together with the bus and the slave
+// address it was issued to.
+type Error struct {
+	Op   string // operation, e.g. "smbus read byte data"
+	Bus  string // bus name, e.g. "/dev/i2c-1"
+	Addr int    // slave address, -1 if none was set
+	Kind error  // one of the Err* kinds, or nil
+	Err  error  // underlying error, usually a syscall.Errno
+}
+
+func (e *Error) Error() string {
+	s := "i2c " + e.Op + " on " + e.Bus
+	if e.Addr >= 0 {
+		s += fmt.Sprintf(" at %#02x", e.Addr)
+	}
+	if e.Kind == nil || e.Kind == e.Err {
+		return s + ": " + e.Err.Error()
+	}
+	return s + ": " + e.Kind.Error() + " (" + e.Err.Error() + ")"
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether target is the kind of e.
+func (e *Error) Is(target error) bool { return target != nil && target == e.Kind }
+
+// faults are the kernel fault codes of the error kinds.
+var faults = []struct {
+	kind  error
+	codes []syscall.Errno
+}{
+	{ErrNACK, []syscall.Errno{syscall.ENXIO, syscall.EREMOTEIO}},
+	{ErrArbitrationLost, []syscall.Errno{syscall.EAGAIN}},
+	{ErrBusBusy, []syscall.Errno{syscall.EBUSY}},
+	{ErrTimeout, []syscall.Errno{syscall.ETIMEDOUT}},
+	{ErrNotSupported, []syscall.Errno{syscall.EOPNOTSUPP, syscall.ENOTTY}},
+	{ErrInvalid, []syscall.Errno{syscall.EINVAL}},
+}
+
+// classify maps err to an error kind: a kind, a kernel fault code or an
+// error wrapping one of them, as *os.PathError does.
+func classify(err error) error {
+	for _, k := range kinds {
+		if errors.Is(err, k) {
+			return k
+		}
+	}
+	for _, f := range faults {
+		for _, c := range f.codes {
+			if errors.Is(err, c) {
+				return f.kind
+			}
+		}
+	}
+	return nil
+}
+
+// wrap turns err into an *Error for op on d, err may be nil.
+func (d *Device) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	return &Error{Op: op, Bus: d.name, Addr: d.addr, Kind: classify(err), Err: err}
+}
@@ -2,6 +2,7 @@ package i2c
 
 import (
 	"os"
+	"runtime"
 	"syscall"
 	"unsafe"
 )
@@ -15,6 +16,59 @@ func i2c_funcs_ioctl(f *os.File, data uintptr) error {
 	return nil
 }
 
+const (
+	i2cMTen = 0x0010 /* this is a ten bit chip address */
+	i2cMRd  = 0x0001 /* read data, from slave to master */
+)
+
+// i2cMsg is struct i2c_msg from <linux/i2c.h>
+type i2cMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   uintptr
+}
+
+// i2cRdwrIoctlData is struct i2c_rdwr_ioctl_data from <linux/i2c-dev.h>
+type i2cRdwrIoctlData struct {
+	msgs  uintptr
+	nmsgs uint32
+}
+
+// i2cRdwr writes w (if not empty) then reads len(r) bytes into r (if not
+// empty) from the slave at addr, as one combined transfer with a single STOP.
+func i2cRdwr(f *os.File, addr int, w []byte, r []byte) error {
+	var flags uint16
+	if addr&tenbitMask == tenbitMask {
+		flags = i2cMTen
+	}
+	a := uint16(addr & (tenbitMask - 1))
+
+	var msgs [2]i2cMsg
+	n := 0
+	if len(w) > 0 || len(r) == 0 {
+		msgs[n] = i2cMsg{addr: a, flags: flags, len: uint16(len(w))}
+		if len(w) > 0 {
+			msgs[n].buf = uintptr(unsafe.Pointer(&w[0]))
+		}
+		n++
+	}
+	if len(r) > 0 {
+		msgs[n] = i2cMsg{addr: a, flags: flags | i2cMRd, len: uint16(len(r)), buf: uintptr(unsafe.Pointer(&r[0]))}
+		n++
+	}
+	data := i2cRdwrIoctlData{msgs: uintptr(unsafe.Pointer(&msgs[0])), nmsgs: uint32(n)}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), I2cRDWR, uintptr(unsafe.Pointer(&data)))
+	runtime.KeepAlive(w)
+	runtime.KeepAlive(r)
+	runtime.KeepAlive(&msgs)
+	if errno != 0 {
+		return syscall.Errno(errno)
+	}
+	return nil
+}
+
 func supportI2cRDWR(f *os.File) (b bool, err error) {
 	var data uint64
 	err = i2c_funcs_ioctl(f, uintptr(unsafe.Pointer(&data)))
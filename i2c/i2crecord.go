@@ -0,0 +1,195 @@
+package i2c
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Record is one transfer logged by a recorded Device (see Device.Record).
+type Record struct {
+	Time     time.Time
+	Op       string // the Device method, e.g. "smbus read byte data"
+	Addr     int
+	Write    []byte // bytes written, empty for a read
+	Read     []byte // bytes read, empty for a write
+	Err      error
+	Duration time.Duration
+}
+
+// Direction returns "write", "read" or "write-read".
+func (r *Record) Direction() string {
+	switch {
+	case len(r.Read) == 0:
+		return "write"
+	case len(r.Write) == 0:
+		return "read"
+	}
+	return "write-read"
+}
+
+// jsonRecord is the JSON form of a Record, one per line.
+type jsonRecord struct {
+	Time     time.Time `json:"time"`
+	Op       string    `json:"op,omitempty"`
+	Addr     int       `json:"addr"`
+	Dir      string    `json:"dir"`
+	Write    string    `json:"write,omitempty"`
+	Read     string    `json:"read,omitempty"`
+	ReadLen  int       `json:"read_len,omitempty"`
+	Err      string    `json:"err,omitempty"`
+	Kind     string    `json:"kind,omitempty"` // one of the Err* kinds
+	Duration int64     `json:"duration_ns"`
+}
+
+var kinds = []error{ErrNACK, ErrBusBusy, ErrArbitrationLost, ErrAddressInUse, ErrTimeout, ErrNotSupported, ErrInvalid}
+
+// recorder writes the log of a recorded Device, under its lock.
+type recorder struct {
+	enc *json.Encoder
+	err error // first error writing the log
+}
+
+// Record logs every transfer issued on d from now on to w as a line of
+// JSON (see NewReplay), a nil w stops recording. It returns the first
+// error met while writing the previous log, if any.
+//
+// Transfers are logged by d itself, so they go out exactly as they would
+// unrecorded: a kernel adapter still runs SMBus commands with its own
+// ioctl. They are logged as the I2C transfer they stand for, which is what
+// the SMBus emulation sends on a Bus, and every retry is logged.
+func (d *Device) Record(w io.Writer) error {
+	d.Lock()
+	defer d.Unlock()
+
+	var err error
+	if d.rec != nil {
+		err = d.rec.err
+	}
+	d.rec = nil
+	if w != nil {
+		d.rec = &recorder{enc: json.NewEncoder(w)}
+	}
+	return err
+}
+
+// log logs try t of op, started at start.
+func (rc *recorder) log(op string, start time.Time, t *txn, err error) {
+	if rc.err != nil {
+		return
+	}
+	rec := jsonRecord{
+		Time:     start,
+		Op:       op,
+		Addr:     t.addr,
+		Write:    hex.EncodeToString(t.w),
+		ReadLen:  len(t.r),
+		Duration: int64(time.Since(start)),
+	}
+	rec.Dir = (&Record{Write: t.w, Read: t.r}).Direction()
+	if err == nil {
+		rec.Read = hex.EncodeToString(t.r)
+	} else {
+		rec.Err = err.Error()
+		for _, k := range kinds {
+			if errors.Is(err, k) {
+				rec.Kind = k.Error()
+				break
+			}
+		}
+	}
+	rc.err = rc.enc.Encode(&rec)
+}
+
+// ReadRecords reads a log written by Device.Record.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var recs []Record
+	dec := json.NewDecoder(r)
+	for {
+		var jr jsonRecord
+		if err := dec.Decode(&jr); err == io.EOF {
+			return recs, nil
+		} else if err != nil {
+			return recs, err
+		}
+		rec := Record{Time: jr.Time, Op: jr.Op, Addr: jr.Addr, Duration: time.Duration(jr.Duration)}
+		var err error
+		if jr.Dir != "read" {
+			if rec.Write, err = hex.DecodeString(jr.Write); err != nil {
+				return recs, err
+			}
+		}
+		if jr.Dir != "write" {
+			if rec.Read, err = hex.DecodeString(jr.Read); err != nil {
+				return recs, err
+			}
+			// a failed read logs no data
+			if len(rec.Read) < jr.ReadLen {
+				rec.Read = append(rec.Read, make([]byte, jr.ReadLen-len(rec.Read))...)
+			}
+		}
+		if jr.Err != "" {
+			rec.Err = errors.New(jr.Err)
+			for _, k := range kinds {
+				if jr.Kind == k.Error() {
+					rec.Err = k
+				}
+			}
+		}
+		recs = append(recs, rec)
+	}
+}
+
+type replay struct {
+	mu   sync.Mutex
+	recs []Record
+	next int
+}
+
+// NewReplay returns a Bus answering transfers from a log written by
+// Device.Record, so a driver can be run against a recorded session without the
+// hardware. Transfers must come in the recorded order with the same
+// address, written bytes and read length; recorded errors are returned
+// again, with their kind. SMBus block reads and read quick commands
+// can not be replayed, as the emulation does not support them.
+func NewReplay(r io.Reader) (Bus, error) {
+	recs, err := ReadRecords(r)
+	if err != nil {
+		return nil, err
+	}
+	return &replay{recs: recs}, nil
+}
+
+// Tx implements Bus.
+func (rp *replay) Tx(addr int, w, r []byte) error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.next == len(rp.recs) {
+		return fmt.Errorf("replay: transfer %d to %#02x beyond the end of the log", rp.next, addr)
+	}
+	rec := &rp.recs[rp.next]
+	if rec.Addr != addr || string(rec.Write) != string(w) || len(rec.Read) != len(r) {
+		return fmt.Errorf("replay: transfer %d is %s %#02x w=[% x] r=%d, log has %s %#02x w=[% x] r=%d",
+			rp.next, (&Record{Write: w, Read: r}).Direction(), addr, w, len(r),
+			rec.Direction(), rec.Addr, rec.Write, len(rec.Read))
+	}
+	rp.next++
+	copy(r, rec.Read)
+	return rec.Err
+}
+
+// Close implements Bus, it fails if part of the log was not replayed.
+func (rp *replay) Close() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if left := len(rp.recs) - rp.next; left > 0 {
+		return fmt.Errorf("replay: %d transfers not replayed", left)
+	}
+	return nil
+}
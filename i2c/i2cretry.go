@@ -0,0 +1,105 @@
+package i2c
+
+import (
+	"context"
+	"time"
+)
+
+// RetryPolicy describes how a failed transfer is retried in user space, on
+// top of the retries done by the adapter itself (see SetRetries).
+// Only transient failures are retried: the slave did not acknowledge
+// (ENXIO, EREMOTEIO) or the bus was busy (EAGAIN).
+type RetryPolicy struct {
+	// Attempts is the total number of tries, the first one included.
+	Attempts int
+	// Backoff is the delay before the first retry, it doubles after every
+	// further failure.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between two tries, 0 means no cap.
+	MaxBackoff time.Duration
+}
+
+// SetRetryPolicy sets the user-space retry policy used by every transfer
+// on d. A nil policy disables user-space retries.
+func (d *Device) SetRetryPolicy(p *RetryPolicy) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.retry = p
+}
+
+// temporary reports whether err is worth another try.
+func temporary(err error) bool {
+	switch classify(err) {
+	case ErrNACK, ErrArbitrationLost:
+		return true
+	}
+	return false
+}
+
+// txn is the transfer an op amounts to, filled in by the op for the
+// recorder (see Record). SMBus commands fill in the plain I2C transfer
+// they stand for, which is what the emulation sends on a Bus.
+type txn struct {
+	addr int
+	w, r []byte
+}
+
+// do runs op with d locked, retrying it according to the retry policy,
+// and returns the last failure as an *Error. Every try is logged when d
+// is recorded.
+// The ioctl in flight can not be interrupted, so ctx is checked before
+// every try and while backing off; the adapter timeout (see SetTimeout)
+// bounds a single try. It is not derived from the deadline of ctx: the
+// kernel keeps one timeout per adapter, for all its users, and it can't
+// be read back to be restored.
+func (d *Device) do(ctx context.Context, op string, fn func(t *txn) error) (err error) {
+	d.Lock()
+	defer d.Unlock()
+
+	attempts, backoff := 1, time.Duration(0)
+	if p := d.retry; p != nil && p.Attempts > 1 {
+		attempts, backoff = p.Attempts, p.Backoff
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return d.ctxErr(op, ctx)
+			case <-t.C:
+			}
+			backoff *= 2
+			if max := d.retry.MaxBackoff; max > 0 && backoff > max {
+				backoff = max
+			}
+		}
+		if ctx.Err() != nil {
+			return d.ctxErr(op, ctx)
+		}
+		t, start := txn{addr: d.addr}, time.Now()
+		err = fn(&t)
+		if d.rec != nil {
+			d.rec.log(op, start, &t, err)
+		}
+		if err == nil || !temporary(err) {
+			break
+		}
+	}
+	return d.wrap(op, err)
+}
+
+// ctxErr returns the error of op given up on because ctx is done: of kind
+// ErrTimeout past its deadline, of no kind when it was cancelled.
+func (d *Device) ctxErr(op string, ctx context.Context) error {
+	e := &Error{Op: op, Bus: d.name, Addr: d.addr, Err: ctx.Err()}
+	if e.Err == context.DeadlineExceeded {
+		e.Kind = ErrTimeout
+	}
+	return e
+}
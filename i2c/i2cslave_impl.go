@@ -0,0 +1,182 @@
+package i2c
+
+//6. SLAVE (TARGET) MODE
+//Linux can make an adapter answer as a slave through a slave backend
+//(Documentation/i2c/slave-interface). The backend is bound at an address by
+//writing "<backend> <0x1000|addr>" to the adapter's new_device file. The
+//i2c-slave-eeprom backend behaves like a 24cXX eeprom and exposes its
+//memory as the slave-eeprom file of the new client: the host reads what we
+//put there and its writes show up there.
+//Requests are answered by the kernel, not here: a Target can only set the
+//memory the host reads and observe the changes the host makes to it, by
+//comparing the memory with what it was. A host write of the value already
+//there goes unseen, and host reads are not seen at all. To answer the
+//requests from Go, serve the Pi's BSC slave with ServeBSC instead.
+//Needs CONFIG_I2C_SLAVE and an adapter driver with slave support, e.g.
+//i2c-gpio; the Pi's own i2c-bcm2835 has none.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	i2cSlaveAddrOffset  = 0x1000 /* I2C_TARGET_ADDR_OFFSET */
+	i2cTenBitAddrOffset = 0xa000 /* I2C_ADDR_OFFSET_TEN_BIT */
+)
+
+// sysfsI2CDevices is where the kernel lists adapters and clients.
+var sysfsI2CDevices = "/sys/bus/i2c/devices"
+
+// Slave backends shipped with the kernel, with the size of their memory.
+const (
+	Slave24c02  = "slave-24c02"
+	Slave24c32  = "slave-24c32"
+	Slave24c64  = "slave-24c64"
+	Slave24c512 = "slave-24c512"
+)
+
+var slaveMemSize = map[string]int{
+	Slave24c02:  256,
+	Slave24c32:  4096,
+	Slave24c64:  8192,
+	Slave24c512: 65536,
+}
+
+// Target is the Pi registered as an I2C slave on one of its adapters,
+// with an eeprom backend answering the host.
+type Target struct {
+	bus     int
+	addr    int
+	backend string
+	f       *os.File // the backend memory
+	last    []byte   // memory as seen by the last Changes
+}
+
+// slaveClient returns the name of the client the kernel creates for a slave
+// backend at addr on bus, e.g. "1-1064".
+func slaveClient(bus int, addr int) string {
+	return fmt.Sprintf("%d-%04x", bus, slaveAddr(addr))
+}
+
+// slaveAddr returns addr as written to new_device and delete_device.
+func slaveAddr(addr int) int {
+	if addr&tenbitMask == tenbitMask {
+		return addr&(tenbitMask-1) | i2cTenBitAddrOffset | i2cSlaveAddrOffset
+	}
+	return addr | i2cSlaveAddrOffset
+}
+
+// NewTarget registers the slave backend at addr on /dev/i2c-<bus> and
+// opens its memory. addr can be marked as a 10-bit address with TenBit.
+// The Target must be closed to unregister the backend.
+func NewTarget(bus int, addr int, backend string) (*Target, error) {
+	size, ok := slaveMemSize[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown slave backend %q", backend)
+	}
+	adapter := filepath.Join(sysfsI2CDevices, fmt.Sprintf("i2c-%d", bus))
+	line := fmt.Sprintf("%s %#04x", backend, slaveAddr(addr))
+	if err := writeSysfs(filepath.Join(adapter, "new_device"), line); err != nil {
+		return nil, err
+	}
+
+	mem := filepath.Join(sysfsI2CDevices, slaveClient(bus, addr), "slave-eeprom")
+	f, err := os.OpenFile(mem, os.O_RDWR, 0)
+	if err != nil {
+		writeSysfs(filepath.Join(adapter, "delete_device"), fmt.Sprintf("%#04x", slaveAddr(addr)))
+		return nil, err
+	}
+	t := &Target{bus: bus, addr: addr, backend: backend, f: f, last: make([]byte, size)}
+	if _, err = f.ReadAt(t.last, 0); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func writeSysfs(name string, value string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write([]byte(value))
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Size returns the size of the backend memory.
+func (t *Target) Size() int {
+	return len(t.last)
+}
+
+// ReadAt reads the backend memory, it implements io.ReaderAt.
+func (t *Target) ReadAt(p []byte, off int64) (int, error) {
+	return t.f.ReadAt(p, off)
+}
+
+// WriteAt writes the backend memory, it implements io.WriterAt. What is
+// written here is what the host reads. It does not count as a change
+// for Changes.
+func (t *Target) WriteAt(p []byte, off int64) (int, error) {
+	n, err := t.f.WriteAt(p, off)
+	if off < int64(len(t.last)) {
+		copy(t.last[off:], p[:n])
+	}
+	return n, err
+}
+
+// Changes reads the backend memory and calls fn for every range the host
+// has changed since the previous call. Writes leaving a byte as it was do
+// not show, nor do several writes to a byte between two calls.
+func (t *Target) Changes(fn func(off int, data []byte)) error {
+	cur := make([]byte, len(t.last))
+	if _, err := t.f.ReadAt(cur, 0); err != nil {
+		return err
+	}
+	for off := 0; off < len(cur); {
+		if cur[off] == t.last[off] {
+			off++
+			continue
+		}
+		end := off + 1
+		for end < len(cur) && cur[end] != t.last[end] {
+			end++
+		}
+		fn(off, cur[off:end])
+		off = end
+	}
+	t.last = cur
+	return nil
+}
+
+// Watch calls Changes every interval until ctx is done.
+func (t *Target) Watch(ctx context.Context, interval time.Duration, fn func(off int, data []byte)) error {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if err := t.Changes(fn); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+// Close closes the backend memory and unregisters the backend.
+func (t *Target) Close() error {
+	err := t.f.Close()
+	adapter := filepath.Join(sysfsI2CDevices, fmt.Sprintf("i2c-%d", t.bus))
+	if e := writeSysfs(filepath.Join(adapter, "delete_device"), fmt.Sprintf("%#04x", slaveAddr(t.addr))); err == nil {
+		err = e
+	}
+	return err
+}
@@ -55,7 +55,10 @@ func i2c_smbus_access(f *os.File, read_write uint8, command uint8, size uint32,
 			}
 		case []byte:
 			x, ok := data.([]byte)
-			if ok {
+			//the kernel copies a whole union i2c_smbus_data for blocks
+			if ok && len(x) < I2cSmBusBlockMax+2 {
+				err = fmt.Errorf("i2c_smbus_access: block buffer of %d bytes, need %d", len(x), I2cSmBusBlockMax+2)
+			} else if ok {
 				args.Data = (*[34]byte)(unsafe.Pointer(&x[0]))
 				err = i2c_smbus_ioctl(f, uintptr(unsafe.Pointer(&args)))
 			}
@@ -195,13 +198,17 @@ func i2c_smbus_read_block_data(f *os.File, command uint8) ([]byte, error) {
 	  			return data.block[0];
 	  	}
 	  }*/
-	block := make([]byte, I2cSmBusBlockMax+2, I2cSmBusBlockMax+2)
+	block := make([]byte, I2cSmBusBlockMax+2)
 	err := i2c_smbus_access(f, I2cSMBusRead /*read_write*/, command /*command*/, I2cSMBusBlockData /*size*/, block /*data*/)
-	len := len(block)
-	if (len > 0) && err == nil {
-		return block[1 : 1+len], nil
+	if err != nil {
+		return nil, err
+	}
+	//block[0] is the count sent by the slave
+	n := int(block[0])
+	if n > I2cSmBusBlockMax {
+		return nil, fmt.Errorf("i2c_smbus_read_block_data: invalid block length %d", n)
 	}
-	return block, fmt.Errorf("i2c_smbus_read_block_data: can not read ")
+	return block[1 : 1+n], nil
 }
 
 //i2c_smbus_write_block_data()	Sends a block of data (<= 32 bytes) to the specified offset.
@@ -219,13 +226,8 @@ func i2c_smbus_write_block_data(f *os.File, command uint8, length uint8, value [
 	  	return i2c_smbus_access(file,I2C_SMBUS_WRITE,command,
 	  	                        I2C_SMBUS_BLOCK_DATA, &data);
 	  }*/
-	if length > 32 {
-		length = 32
-	}
-	value = value[:length]
-	value = append([]byte{length}, value[0:]...)
-
-	err = i2c_smbus_access(f, I2cSMBusWrite /*read_write*/, command /*command*/, I2cSMBusBlockData /*size*/, value /*data*/)
+	block := smbus_block(length, value)
+	err = i2c_smbus_access(f, I2cSMBusWrite /*read_write*/, command /*command*/, I2cSMBusBlockData /*size*/, block /*data*/)
 	return
 }
 
@@ -243,14 +245,61 @@ func i2c_smbus_write_i2c_block_data(f *os.File, command uint8, length uint8, val
 	  	return i2c_smbus_access(file,I2C_SMBUS_WRITE,command,
 	  	                        I2C_SMBUS_I2C_BLOCK_DATA, &data);
 	  }*/
-	if length > 32 {
-		length = 32
+	block := smbus_block(length, value)
+	err = i2c_smbus_access(f, I2cSMBusWrite /*read_write*/, command /*command*/, I2cSMBusI2cBlockData /*size*/, block /*data*/)
+	return
+}
+
+//i2c_smbus_read_i2c_block_data()	Reads length bytes (<= 32) from the specified offset,
+//the length is chosen by the master.
+func i2c_smbus_read_i2c_block_data(f *os.File, command uint8, length uint8) ([]byte, error) {
+	/*static inline __s32 i2c_smbus_read_i2c_block_data(int file, __u8 command,
+	                                                    __u8 length, __u8 *values)
+	  {
+	  	union i2c_smbus_data data;
+	  	int i;
+	  	if (length > 32)
+	  		length = 32;
+	  	data.block[0] = length;
+	  	if (i2c_smbus_access(file,I2C_SMBUS_READ,command,
+	  	                     length == 32 ? I2C_SMBUS_I2C_BLOCK_BROKEN :
+	  	                      I2C_SMBUS_I2C_BLOCK_DATA,&data))
+	  		return -1;
+	  	else {
+	  		for (i = 1; i <= data.block[0]; i++)
+	  			values[i-1] = data.block[i];
+	  		return data.block[0];
+	  	}
+	  }*/
+	if length > I2cSmBusI2cBlockMax {
+		length = I2cSmBusI2cBlockMax
 	}
-	value = value[:length]
-	value = append([]byte{length}, value[0:]...)
+	block := make([]byte, I2cSmBusBlockMax+2)
+	block[0] = length
+	err := i2c_smbus_access(f, I2cSMBusRead /*read_write*/, command /*command*/, I2cSMBusI2cBlockData /*size*/, block /*data*/)
+	if err != nil {
+		return nil, err
+	}
+	n := int(block[0])
+	if n > I2cSmBusI2cBlockMax {
+		return nil, fmt.Errorf("i2c_smbus_read_i2c_block_data: invalid block length %d", n)
+	}
+	return block[1 : 1+n], nil
+}
 
-	err = i2c_smbus_access(f, I2cSMBusWrite /*read_write*/, command /*command*/, I2cSMBusI2cBlockData /*size*/, value /*data*/)
-	return
+//smbus_block returns the union i2c_smbus_data for a block write: the length
+//(at most 32 bytes and no more than value holds) followed by the bytes.
+func smbus_block(length uint8, value []byte) []byte {
+	if length > I2cSmBusBlockMax {
+		length = I2cSmBusBlockMax
+	}
+	if int(length) > len(value) {
+		length = uint8(len(value))
+	}
+	block := make([]byte, I2cSmBusBlockMax+2)
+	block[0] = length
+	copy(block[1:], value[:length])
+	return block
 }
 
 /*
@@ -0,0 +1,104 @@
+package i2c
+
+//4. SMBUS EMULATION
+//SMBus commands on a Bus that is not a kernel adapter (see NewDevice) are
+//emulated with plain I2C transfers, the same way the kernel does for
+//adapters that only support I2C_FUNC_I2C. SMBus words are sent LSB first.
+
+import "errors"
+
+var errNoAddr = errors.New("no slave address set")
+
+func bus_smbus_tx(b Bus, addr int, w []byte, r []byte) error {
+	if addr < 0 {
+		return errNoAddr
+	}
+	return b.Tx(addr, w, r)
+}
+
+// bus_smbus_write_quick() only supports the write flavour, a zero-length
+// read can not be expressed with Tx.
+func bus_smbus_write_quick(b Bus, addr int, value uint8) error {
+	if value != I2cSMBusWrite {
+		return ErrNotSupported
+	}
+	return bus_smbus_tx(b, addr, nil, nil)
+}
+
+func bus_smbus_read_byte(b Bus, addr int) (data uint8, err error) {
+	var r [1]byte
+	err = bus_smbus_tx(b, addr, nil, r[:])
+	return r[0], err
+}
+
+func bus_smbus_write_byte(b Bus, addr int, value uint8) error {
+	return bus_smbus_tx(b, addr, []byte{value}, nil)
+}
+
+func bus_smbus_read_byte_data(b Bus, addr int, command uint8) (data uint8, err error) {
+	var r [1]byte
+	err = bus_smbus_tx(b, addr, []byte{command}, r[:])
+	return r[0], err
+}
+
+func bus_smbus_write_byte_data(b Bus, addr int, command uint8, value uint8) error {
+	return bus_smbus_tx(b, addr, []byte{command, value}, nil)
+}
+
+func bus_smbus_read_word_data(b Bus, addr int, command uint8) (data uint16, err error) {
+	var r [2]byte
+	err = bus_smbus_tx(b, addr, []byte{command}, r[:])
+	return uint16(r[0]) | uint16(r[1])<<8, err
+}
+
+func bus_smbus_write_word_data(b Bus, addr int, command uint8, value uint16) error {
+	return bus_smbus_tx(b, addr, []byte{command, byte(value), byte(value >> 8)}, nil)
+}
+
+func bus_smbus_process_call(b Bus, addr int, command uint8, value uint16) (data uint16, err error) {
+	var r [2]byte
+	err = bus_smbus_tx(b, addr, []byte{command, byte(value), byte(value >> 8)}, r[:])
+	return uint16(r[0]) | uint16(r[1])<<8, err
+}
+
+// bus_smbus_read_block_data() is not supported: the slave sends the length
+// first, and Tx needs it up front.
+func bus_smbus_read_block_data(b Bus, addr int, command uint8) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+// block_data_w returns the bytes written by a block write, the count
+// first, and i2c_block_data_w those of an I2C block write, without it.
+func block_data_w(command uint8, length uint8, value []byte) []byte {
+	block := smbus_block(length, value)
+	return append([]byte{command}, block[:1+block[0]]...)
+}
+
+func i2c_block_data_w(command uint8, length uint8, value []byte) []byte {
+	block := smbus_block(length, value)
+	return append([]byte{command}, block[1:1+block[0]]...)
+}
+
+// i2c_block_len returns the number of bytes an I2C block read asks for.
+func i2c_block_len(length uint8) int {
+	if length > I2cSmBusI2cBlockMax {
+		length = I2cSmBusI2cBlockMax
+	}
+	return int(length)
+}
+
+func bus_smbus_write_block_data(b Bus, addr int, command uint8, length uint8, value []byte) error {
+	return bus_smbus_tx(b, addr, block_data_w(command, length, value), nil)
+}
+
+func bus_smbus_write_i2c_block_data(b Bus, addr int, command uint8, length uint8, value []byte) error {
+	return bus_smbus_tx(b, addr, i2c_block_data_w(command, length, value), nil)
+}
+
+func bus_smbus_read_i2c_block_data(b Bus, addr int, command uint8, length uint8) ([]byte, error) {
+	r := make([]byte, i2c_block_len(length))
+	if err := bus_smbus_tx(b, addr, []byte{command}, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,201 @@
+package i2c
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The stub tests run the kernel paths (I2C_SMBUS, I2C_RDWR) against the
+// i2c-stub driver, which emulates SMBus chips in memory. They need root
+// to load the module and are skipped otherwise:
+//
+//	sudo go test -run Stub ./i2c
+
+// stubAddrs are the chips i2c-stub is loaded with.
+var stubAddrs = []int{0x1c, 0x50}
+
+const stubAdapterName = "SMBus stub driver"
+
+// loadStub loads i2c-stub (with SMBus block data enabled) and i2c-dev, and
+// returns the name of the stub adapter device and a function unloading
+// what was loaded. It skips t when the stub can not be used.
+func loadStub(t *testing.T) (string, func()) {
+	if testing.Short() {
+		t.Skip("skipping i2c-stub test in short mode")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("loading i2c-stub needs root")
+	}
+
+	unload := func() {}
+	if _, err := os.Stat("/sys/module/i2c_stub"); err == nil {
+		// loaded by someone else, it must have our chips
+		b, err := ioutil.ReadFile("/sys/module/i2c_stub/parameters/chip_addr")
+		if err != nil {
+			t.Skipf("i2c-stub already loaded: %v", err)
+		}
+		have := map[int]bool{}
+		for _, a := range strings.Split(strings.TrimSpace(string(b)), ",") {
+			n, _ := strconv.ParseInt(a, 0, 0)
+			have[int(n)] = true
+		}
+		for _, a := range stubAddrs {
+			if !have[a] {
+				t.Skipf("i2c-stub already loaded with chip_addr=%s", strings.TrimSpace(string(b)))
+			}
+		}
+	} else {
+		var addrs []string
+		for _, a := range stubAddrs {
+			addrs = append(addrs, fmt.Sprintf("%#02x", a))
+		}
+		out, err := exec.Command("modprobe", "i2c-stub", "chip_addr="+strings.Join(addrs, ","), "functionality=0xffffffff").CombinedOutput()
+		if err != nil {
+			t.Skipf("can not load i2c-stub: %v: %s", err, bytes.TrimSpace(out))
+		}
+		unload = func() {
+			if out, err := exec.Command("modprobe", "-r", "i2c-stub").CombinedOutput(); err != nil {
+				t.Logf("unloading i2c-stub: %v: %s", err, bytes.TrimSpace(out))
+			}
+		}
+	}
+	// i2c-dev may be built in
+	exec.Command("modprobe", "i2c-dev").Run()
+
+	adapter, err := findAdapter(stubAdapterName)
+	if err != nil {
+		unload()
+		t.Skip(err)
+	}
+	// give udev time to create the node
+	dev := "/dev/" + adapter
+	for i := 0; ; i++ {
+		if _, err = os.Stat(dev); err == nil {
+			break
+		}
+		if i == 20 {
+			unload()
+			t.Skip(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return dev, unload
+}
+
+// findAdapter returns the adapter (e.g. "i2c-3") whose name is name.
+func findAdapter(name string) (string, error) {
+	files, _ := filepath.Glob("/sys/class/i2c-adapter/*/name")
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err == nil && strings.TrimSpace(string(b)) == name {
+			return filepath.Base(filepath.Dir(f)), nil
+		}
+	}
+	return "", fmt.Errorf("no i2c adapter named %q", name)
+}
+
+func TestStub(t *testing.T) {
+	dev, unload := loadStub(t)
+	defer unload()
+
+	d, err := Open(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err = d.SetAddr(stubAddrs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("quick", func(t *testing.T) {
+		if err := d.SmbusWriteQuick(I2cSMBusWrite); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("byte data", func(t *testing.T) {
+		if err := d.SmbusWriteByteData(0x10, 0xa5); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := d.SmbusReadByteData(0x10); err != nil || v != 0xa5 {
+			t.Errorf("SmbusReadByteData() = %#x, %v, want 0xa5", v, err)
+		}
+	})
+	t.Run("byte", func(t *testing.T) {
+		// the stub reads on from the register pointer set by a byte write
+		if err := d.SmbusWriteByte(0x10); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := d.SmbusReadByte(); err != nil || v != 0xa5 {
+			t.Errorf("SmbusReadByte() = %#x, %v, want 0xa5", v, err)
+		}
+	})
+	t.Run("word data", func(t *testing.T) {
+		if err := d.SmbusWriteWordData(0x20, 0xbeef); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := d.SmbusReadWordData(0x20); err != nil || v != 0xbeef {
+			t.Errorf("SmbusReadWordData() = %#x, %v, want 0xbeef", v, err)
+		}
+		if v, err := d.SmbusReadByteData(0x20); err != nil || v != 0xef {
+			t.Errorf("SmbusReadByteData() = %#x, %v, want the low byte 0xef", v, err)
+		}
+	})
+	t.Run("i2c block data", func(t *testing.T) {
+		want := []byte{1, 2, 3, 4}
+		if err := d.SmbusWriteI2cBlockData(0x40, uint8(len(want)), want); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := d.SmbusReadByteData(0x41); err != nil || v != 2 {
+			t.Errorf("SmbusReadByteData() = %#x, %v, want 2", v, err)
+		}
+		if got, err := d.SmbusReadI2cBlockData(0x40, uint8(len(want))); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("SmbusReadI2cBlockData() = % x, %v, want % x", got, err, want)
+		}
+	})
+	t.Run("block data", func(t *testing.T) {
+		want := []byte{9, 8, 7}
+		err := d.SmbusWriteBlockData(0x60, uint8(len(want)), want)
+		if errors.Is(err, ErrNotSupported) {
+			t.Skip("i2c-stub loaded without SMBus block support")
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := d.SmbusReadBlockData(0x60); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("SmbusReadBlockData() = % x, %v, want % x", got, err, want)
+		}
+	})
+	t.Run("chips are separate", func(t *testing.T) {
+		d2, err := Open(dev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d2.Close()
+		if err = d2.SetAddr(stubAddrs[1]); err != nil {
+			t.Fatal(err)
+		}
+		if err = d2.SmbusWriteByteData(0x10, 0x5a); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := d.SmbusReadByteData(0x10); err != nil || v != 0xa5 {
+			t.Errorf("SmbusReadByteData() = %#x, %v, want 0xa5", v, err)
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		// the stub is SMBus only: no process call and no plain I2C
+		if _, err := d.SmbusProcessCall(0x20, 1); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("SmbusProcessCall() err = %v, want ErrNotSupported", err)
+		}
+		if err := d.Tx(stubAddrs[0], []byte{0x10}, make([]byte, 1)); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("Tx() err = %v, want ErrNotSupported", err)
+		}
+	})
+}
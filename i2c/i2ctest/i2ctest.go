@@ -0,0 +1,130 @@
+// Package i2ctest provides an in-memory I2C bus to test drivers without
+// hardware. Device models written in Go are attached at addresses; the bus
+// is an i2c.Bus, so i2c.NewDevice gives drivers the usual Device API:
+//
+//	bus := i2ctest.NewBus()
+//	bus.Attach(0x50, i2ctest.NewEEPROM(256, 8, 1))
+//	d := i2c.NewDevice(bus, "test")
+package i2ctest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/i2c"
+)
+
+// Model is a slave attached to a Bus. It sees a transfer the way a chip
+// does: a start (or repeated start) addressing it, the bytes, then a stop.
+// Returning i2c.ErrNACK from Start or Write does not acknowledge the
+// address or the byte; any other error aborts the transfer with it.
+type Model interface {
+	Start(read bool) error
+	Write(c byte) error
+	Read() (byte, error)
+	Stop()
+}
+
+// Bus is an in-memory i2c.Bus.
+type Bus struct {
+	mu        sync.Mutex
+	models    map[int]Model
+	transfers []i2c.Record
+}
+
+// NewBus returns a Bus with no slave attached.
+func NewBus() *Bus {
+	return &Bus{models: make(map[int]Model)}
+}
+
+// Attach attaches m at addr, replacing the model there if any.
+func (b *Bus) Attach(addr int, m Model) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.models[addr] = m
+}
+
+// Detach removes the model at addr.
+func (b *Bus) Detach(addr int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.models, addr)
+}
+
+// Tx implements i2c.Bus.
+func (b *Bus) Tx(addr int, w, r []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := time.Now()
+	err := b.tx(addr, w, r)
+	rec := i2c.Record{Time: start, Addr: addr, Err: err, Duration: time.Since(start)}
+	rec.Write = append([]byte(nil), w...)
+	if err == nil {
+		rec.Read = append([]byte(nil), r...)
+	} else {
+		rec.Read = make([]byte, len(r))
+	}
+	b.transfers = append(b.transfers, rec)
+	return err
+}
+
+func (b *Bus) tx(addr int, w, r []byte) (err error) {
+	m, ok := b.models[addr]
+	if !ok {
+		return i2c.ErrNACK
+	}
+	started := false
+	defer func() {
+		if started {
+			m.Stop()
+		}
+	}()
+
+	if len(w) > 0 || len(r) == 0 {
+		if err = m.Start(false); err != nil {
+			return
+		}
+		started = true
+		for _, c := range w {
+			if err = m.Write(c); err != nil {
+				return
+			}
+		}
+	}
+	if len(r) > 0 {
+		if err = m.Start(true); err != nil {
+			return
+		}
+		started = true
+		for i := range r {
+			if r[i], err = m.Read(); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Close implements i2c.Bus.
+func (b *Bus) Close() error {
+	return nil
+}
+
+// Transfers returns the transfers seen so far, failed ones included.
+func (b *Bus) Transfers() []i2c.Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]i2c.Record(nil), b.transfers...)
+}
+
+// Reset forgets the transfers seen so far.
+func (b *Bus) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.transfers = nil
+}
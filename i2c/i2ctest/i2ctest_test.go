@@ -0,0 +1,148 @@
+package i2ctest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/i2c"
+)
+
+func TestRegisterFile(t *testing.T) {
+	bus := NewBus()
+	regs := NewRegisterFile(16)
+	bus.Attach(0x40, regs)
+	d := i2c.NewDevice(bus, "test")
+	if err := d.SetAddr(0x40); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SmbusWriteWordData(0x02, 0xbeef); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(regs.Regs[2:4], []byte{0xef, 0xbe}) {
+		t.Errorf("regs = % x", regs.Regs)
+	}
+	if v, err := d.SmbusReadByteData(0x03); err != nil || v != 0xbe {
+		t.Errorf("SmbusReadByteData() = %#x, %v", v, err)
+	}
+	// the pointer wraps at the end of the register file
+	if err := d.SysfsWriteReg(0x0f, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if regs.Regs[15] != 1 || regs.Regs[0] != 2 {
+		t.Errorf("regs = % x", regs.Regs)
+	}
+	if _, err := d.SmbusReadByteData(0x10); !errors.Is(err, i2c.ErrNACK) {
+		t.Errorf("register out of range: err = %v, want ErrNACK", err)
+	}
+
+	tr := bus.Transfers()
+	if len(tr) != 4 {
+		t.Fatalf("got %d transfers, want 4", len(tr))
+	}
+	if tr[1].Direction() != "write-read" || !bytes.Equal(tr[1].Write, []byte{0x03}) || !bytes.Equal(tr[1].Read, []byte{0xbe}) {
+		t.Errorf("transfer 1 = %+v", tr[1])
+	}
+	bus.Reset()
+	if len(bus.Transfers()) != 0 {
+		t.Error("Reset() kept transfers")
+	}
+}
+
+func TestEEPROM(t *testing.T) {
+	tests := []struct {
+		name      string
+		addrBytes int
+		addr      []byte
+		data      []byte
+		at        int
+		want      []byte // memory from at
+		read      []byte // sequential read from addr
+	}{
+		{"24c02 in page", 1, []byte{0x10}, []byte{1, 2, 3}, 0x10, []byte{1, 2, 3}, []byte{1, 2, 3}},
+		{"24c02 page wrap", 1, []byte{0x16}, []byte{1, 2, 3}, 0x10, []byte{3, 0xff, 0xff, 0xff, 0xff, 0xff, 1, 2}, []byte{1, 2, 0xff}},
+		{"24c32 two address bytes", 2, []byte{0x01, 0x00}, []byte{7, 8}, 0x100, []byte{7, 8}, []byte{7, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewBus()
+			rom := NewEEPROM(4096, 8, tt.addrBytes)
+			rom.BusyPolls = 2
+			bus.Attach(0x50, rom)
+			d := i2c.NewDevice(bus, "test")
+			d.SetAddr(0x50)
+
+			if err := d.SysfsWrite(append(append([]byte(nil), tt.addr...), tt.data...)); err != nil {
+				t.Fatal(err)
+			}
+			// acknowledge polling during the write cycle
+			for i := 0; i < 2; i++ {
+				if err := d.SysfsWrite(tt.addr); !errors.Is(err, i2c.ErrNACK) {
+					t.Fatalf("poll %d: err = %v, want ErrNACK", i, err)
+				}
+			}
+			if !bytes.Equal(rom.Mem[tt.at:tt.at+len(tt.want)], tt.want) {
+				t.Errorf("Mem = % x, want % x", rom.Mem[tt.at:tt.at+len(tt.want)], tt.want)
+			}
+			buf := make([]byte, len(tt.read))
+			if err := d.Tx(0x50, tt.addr, buf); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf, tt.read) {
+				t.Errorf("read back % x, want % x", buf, tt.read)
+			}
+		})
+	}
+}
+
+func TestNewEEPROM_invalid(t *testing.T) {
+	tests := []struct {
+		name                      string
+		size, pageSize, addrBytes int
+	}{
+		{"no page", 256, 0, 1},
+		{"no memory", 0, 8, 1},
+		{"partial page", 100, 8, 1},
+		{"no address", 256, 8, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewEEPROM(%d, %d, %d) did not panic", tt.size, tt.pageSize, tt.addrBytes)
+				}
+			}()
+			NewEEPROM(tt.size, tt.pageSize, tt.addrBytes)
+		})
+	}
+}
+
+func TestFaults(t *testing.T) {
+	bus := NewBus()
+	bus.Attach(0x20, Nak{})
+	bus.Attach(0x21, &Stretch{Model: NewRegisterFile(4), Delay: time.Millisecond})
+	bus.Attach(0x22, &Stretch{Model: NewRegisterFile(4), Delay: time.Second, Timeout: 25 * time.Millisecond})
+	d := i2c.NewDevice(bus, "test")
+
+	tests := []struct {
+		name string
+		addr int
+		want error
+	}{
+		{"missing", 0x30, i2c.ErrNACK},
+		{"nak", 0x20, i2c.ErrNACK},
+		{"stretch", 0x21, nil},
+		{"stuck clock", 0x22, i2c.ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d.SetAddr(tt.addr)
+			_, err := d.SmbusReadByteData(0)
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package i2ctest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/i2c"
+)
+
+// RegisterFile is a chip with 8-bit registers: the first byte written
+// selects the register, further bytes are written from there on, and reads
+// go on from the selected register. The pointer wraps at the end.
+type RegisterFile struct {
+	Regs []byte
+
+	ptr   int
+	first bool
+}
+
+// NewRegisterFile returns a RegisterFile of size registers, all zero.
+func NewRegisterFile(size int) *RegisterFile {
+	return &RegisterFile{Regs: make([]byte, size)}
+}
+
+func (m *RegisterFile) Start(read bool) error {
+	m.first = !read
+	return nil
+}
+
+func (m *RegisterFile) Write(c byte) error {
+	if m.first {
+		m.first = false
+		if int(c) >= len(m.Regs) {
+			return i2c.ErrNACK
+		}
+		m.ptr = int(c)
+		return nil
+	}
+	m.Regs[m.ptr] = c
+	m.ptr = (m.ptr + 1) % len(m.Regs)
+	return nil
+}
+
+func (m *RegisterFile) Read() (byte, error) {
+	c := m.Regs[m.ptr]
+	m.ptr = (m.ptr + 1) % len(m.Regs)
+	return c, nil
+}
+
+func (m *RegisterFile) Stop() {}
+
+// EEPROM is a 24cXX-style eeprom. The first AddrBytes bytes written set
+// the memory address (MSB first). Writes wrap inside the current page of
+// PageSize bytes, like the real chips. After a write the chip runs its
+// internal write cycle and does not acknowledge its address for the next
+// BusyPolls tries, so drivers doing acknowledge polling can be tested.
+type EEPROM struct {
+	Mem       []byte
+	PageSize  int
+	AddrBytes int
+	BusyPolls int
+
+	ptr     int
+	addrIn  int // address bytes received in this write
+	written bool
+	busy    int
+}
+
+// NewEEPROM returns an EEPROM of size bytes, erased to 0xff. size must be
+// a multiple of pageSize, which must be positive, and addrBytes is 1 or 2.
+// It panics otherwise, as these are the mistakes of a test.
+func NewEEPROM(size int, pageSize int, addrBytes int) *EEPROM {
+	if pageSize <= 0 || size <= 0 || size%pageSize != 0 {
+		panic(fmt.Sprintf("i2ctest: invalid EEPROM size %d with %d-byte pages", size, pageSize))
+	}
+	if addrBytes != 1 && addrBytes != 2 {
+		panic(fmt.Sprintf("i2ctest: invalid EEPROM address length %d", addrBytes))
+	}
+	m := &EEPROM{Mem: make([]byte, size), PageSize: pageSize, AddrBytes: addrBytes}
+	for i := range m.Mem {
+		m.Mem[i] = 0xff
+	}
+	return m
+}
+
+func (m *EEPROM) Start(read bool) error {
+	if m.busy > 0 {
+		m.busy--
+		return i2c.ErrNACK
+	}
+	if !read {
+		m.addrIn = 0
+	}
+	return nil
+}
+
+func (m *EEPROM) Write(c byte) error {
+	if m.addrIn < m.AddrBytes {
+		if m.addrIn == 0 {
+			m.ptr = 0
+		}
+		m.ptr = (m.ptr<<8 | int(c)) % len(m.Mem)
+		m.addrIn++
+		return nil
+	}
+	page := m.ptr - m.ptr%m.PageSize
+	m.Mem[m.ptr] = c
+	m.ptr = page + (m.ptr+1-page)%m.PageSize
+	m.written = true
+	return nil
+}
+
+func (m *EEPROM) Read() (byte, error) {
+	c := m.Mem[m.ptr]
+	m.ptr = (m.ptr + 1) % len(m.Mem)
+	return c, nil
+}
+
+func (m *EEPROM) Stop() {
+	if m.written {
+		m.written = false
+		m.busy = m.BusyPolls
+	}
+}
+
+// Nak is a chip that never acknowledges, e.g. one held in reset.
+type Nak struct{}
+
+func (Nak) Start(read bool) error { return i2c.ErrNACK }
+func (Nak) Write(c byte) error    { return i2c.ErrNACK }
+func (Nak) Read() (byte, error)   { return 0xff, nil }
+func (Nak) Stop()                 {}
+
+// Stretch wraps a model that stretches the clock by Delay on every byte.
+// When Timeout is set and Delay exceeds it, bytes fail with i2c.ErrTimeout
+// as a master giving up on a stuck clock would.
+type Stretch struct {
+	Model
+	Delay   time.Duration
+	Timeout time.Duration
+}
+
+func (m *Stretch) stretch() error {
+	if m.Timeout > 0 && m.Delay > m.Timeout {
+		return i2c.ErrTimeout
+	}
+	time.Sleep(m.Delay)
+	return nil
+}
+
+func (m *Stretch) Write(c byte) error {
+	if err := m.stretch(); err != nil {
+		return err
+	}
+	return m.Model.Write(c)
+}
+
+func (m *Stretch) Read() (byte, error) {
+	if err := m.stretch(); err != nil {
+		return 0, err
+	}
+	return m.Model.Read()
+}
@@ -0,0 +1,425 @@
+package serial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A Codec frames messages on a byte stream. Decode is fed the bytes
+// received one at a time; Encode is independent of it.
+type Codec interface {
+	// Encode returns the bytes to send for the frame p.
+	Encode(p []byte) ([]byte, error)
+	// Decode takes the next byte received, it returns the frame it
+	// completes, nil otherwise. After an error the codec waits for the
+	// next frame.
+	Decode(c byte) ([]byte, error)
+	// InFrame reports whether Decode is in the middle of a frame: some
+	// of it came, it is not complete and it was not reported bad. Bytes
+	// outside frames, e.g. the noise before STX, do not count.
+	InFrame() bool
+	// Reset drops the frame being received.
+	Reset()
+}
+
+// Framing errors.
+var (
+	ErrFrameTooLong = errors.New("serial: frame too long")
+	ErrFraming      = errors.New("serial: framing error")
+)
+
+const defaultMaxFrame = 4096
+
+func maxFrame(max int) int {
+	if max <= 0 {
+		return defaultMaxFrame
+	}
+	return max
+}
+
+type delimited struct {
+	delim []byte
+	max   int
+	buf   []byte
+	drop  bool // skipping a frame too long
+}
+
+// Delimited returns a Codec for frames ended by delim, e.g. "\r\n". The
+// frames decoded do not include it, and can't contain it. delim can't be
+// empty. max is the largest frame, 0 for 4096 bytes.
+func Delimited(delim []byte, max int) (Codec, error) {
+	if len(delim) == 0 {
+		return nil, errors.New("serial: empty delimiter")
+	}
+	return &delimited{delim: append([]byte(nil), delim...), max: maxFrame(max)}, nil
+}
+
+func (d *delimited) Encode(p []byte) ([]byte, error) {
+	if len(p) > d.max {
+		return nil, ErrFrameTooLong
+	}
+	if bytes.Contains(p, d.delim) {
+		return nil, fmt.Errorf("serial: frame contains the delimiter %q", d.delim)
+	}
+	return append(append(make([]byte, 0, len(p)+len(d.delim)), p...), d.delim...), nil
+}
+
+func (d *delimited) Decode(c byte) ([]byte, error) {
+	d.buf = append(d.buf, c)
+	if bytes.HasSuffix(d.buf, d.delim) {
+		frame, drop := d.buf[:len(d.buf)-len(d.delim)], d.drop
+		d.Reset()
+		if drop {
+			return nil, nil
+		}
+		return frame, nil
+	}
+	if d.drop {
+		// keep what may start the delimiter
+		d.buf = append(d.buf[:0], d.buf[len(d.buf)-len(d.delim)+1:]...)
+		return nil, nil
+	}
+	if len(d.buf) >= d.max+len(d.delim) {
+		d.drop = true
+		return nil, ErrFrameTooLong
+	}
+	return nil, nil
+}
+
+func (d *delimited) InFrame() bool {
+	return len(d.buf) > 0 && !d.drop
+}
+
+func (d *delimited) Reset() {
+	d.buf = nil
+	d.drop = false
+}
+
+type lengthPrefixed struct {
+	size  int
+	order binary.ByteOrder
+	max   int
+	buf   []byte
+	n     int // frame length, -1 while reading the prefix
+}
+
+// LengthPrefixed returns a Codec for frames preceded by their length on
+// size (1, 2 or 4) bytes in the given order. max is the largest frame, 0
+// for 4096 bytes.
+func LengthPrefixed(size int, order binary.ByteOrder, max int) (Codec, error) {
+	if size != 1 && size != 2 && size != 4 {
+		return nil, fmt.Errorf("serial: invalid length prefix size %d", size)
+	}
+	max = maxFrame(max)
+	if limit := 1<<(8*uint(size)) - 1; size < 4 && max > limit {
+		max = limit
+	}
+	return &lengthPrefixed{size: size, order: order, max: max, n: -1}, nil
+}
+
+func (l *lengthPrefixed) Encode(p []byte) ([]byte, error) {
+	if len(p) > l.max {
+		return nil, ErrFrameTooLong
+	}
+	b := make([]byte, l.size, l.size+len(p))
+	switch l.size {
+	case 1:
+		b[0] = byte(len(p))
+	case 2:
+		l.order.PutUint16(b, uint16(len(p)))
+	case 4:
+		l.order.PutUint32(b, uint32(len(p)))
+	}
+	return append(b, p...), nil
+}
+
+func (l *lengthPrefixed) Decode(c byte) ([]byte, error) {
+	l.buf = append(l.buf, c)
+	if l.n < 0 {
+		if len(l.buf) < l.size {
+			return nil, nil
+		}
+		var n uint32
+		switch l.size {
+		case 1:
+			n = uint32(l.buf[0])
+		case 2:
+			n = uint32(l.order.Uint16(l.buf))
+		case 4:
+			n = l.order.Uint32(l.buf)
+		}
+		if n > uint32(l.max) {
+			l.Reset()
+			return nil, ErrFrameTooLong
+		}
+		l.n = int(n)
+		l.buf = make([]byte, 0, l.n)
+		if l.n > 0 {
+			return nil, nil
+		}
+	}
+	if len(l.buf) < l.n {
+		return nil, nil
+	}
+	frame := l.buf
+	l.Reset()
+	return frame, nil
+}
+
+func (l *lengthPrefixed) InFrame() bool {
+	return len(l.buf) > 0 || l.n >= 0
+}
+
+func (l *lengthPrefixed) Reset() {
+	l.buf = nil
+	l.n = -1
+}
+
+// STX/ETX framing bytes.
+const (
+	STX = 0x02
+	ETX = 0x03
+	DLE = 0x10
+)
+
+// escaped is a codec with start and end bytes, and an escape byte.
+type escaped struct {
+	max int
+	buf []byte
+	// in is set inside a frame, esc after the escape byte, drop while
+	// skipping a bad frame
+	in, esc, drop bool
+}
+
+type stxetx struct{ escaped }
+
+// STXETX returns a Codec for frames between STX and ETX, with STX, ETX
+// and DLE in the data sent as DLE and the byte. Bytes outside frames are
+// ignored. max is the largest frame, 0 for 4096 bytes.
+func STXETX(max int) Codec {
+	return &stxetx{escaped{max: maxFrame(max)}}
+}
+
+func (s *stxetx) Encode(p []byte) ([]byte, error) {
+	if len(p) > s.max {
+		return nil, ErrFrameTooLong
+	}
+	b := make([]byte, 0, len(p)+2)
+	b = append(b, STX)
+	for _, c := range p {
+		if c == STX || c == ETX || c == DLE {
+			b = append(b, DLE)
+		}
+		b = append(b, c)
+	}
+	return append(b, ETX), nil
+}
+
+func (s *stxetx) Decode(c byte) ([]byte, error) {
+	switch {
+	case !s.in:
+		if c == STX {
+			s.in = true
+			s.buf = make([]byte, 0, 64)
+		}
+		return nil, nil
+	case s.esc:
+		s.esc = false
+	case c == DLE:
+		s.esc = true
+		return nil, nil
+	case c == STX:
+		// a new frame before the end of this one
+		s.buf = make([]byte, 0, 64)
+		if s.drop {
+			s.drop = false
+			return nil, nil
+		}
+		return nil, ErrFraming
+	case c == ETX:
+		frame, drop := s.buf, s.drop
+		s.Reset()
+		if drop {
+			return nil, nil
+		}
+		return frame, nil
+	}
+	if s.drop {
+		// the escaped bytes of a frame too long, an escaped STX among
+		// them does not start a frame
+		return nil, nil
+	}
+	if len(s.buf) == s.max {
+		s.buf = nil
+		s.drop = true
+		return nil, ErrFrameTooLong
+	}
+	s.buf = append(s.buf, c)
+	return nil, nil
+}
+
+func (s *stxetx) InFrame() bool {
+	return s.in && !s.drop
+}
+
+func (s *escaped) Reset() {
+	s.buf = nil
+	s.in, s.esc, s.drop = false, false, false
+}
+
+// SLIP bytes, RFC 1055.
+const (
+	slipEnd    = 0xc0
+	slipEsc    = 0xdb
+	slipEscEnd = 0xdc
+	slipEscEsc = 0xdd
+)
+
+type slip struct{ escaped }
+
+// SLIP returns a Codec for SLIP (RFC 1055) frames. Empty frames are
+// skipped. max is the largest frame, 0 for 4096 bytes.
+func SLIP(max int) Codec {
+	return &slip{escaped{max: maxFrame(max)}}
+}
+
+func (s *slip) Encode(p []byte) ([]byte, error) {
+	if len(p) > s.max {
+		return nil, ErrFrameTooLong
+	}
+	// the END in front flushes the noise received before the frame
+	b := make([]byte, 0, len(p)+2)
+	b = append(b, slipEnd)
+	for _, c := range p {
+		switch c {
+		case slipEnd:
+			b = append(b, slipEsc, slipEscEnd)
+		case slipEsc:
+			b = append(b, slipEsc, slipEscEsc)
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, slipEnd), nil
+}
+
+func (s *slip) Decode(c byte) ([]byte, error) {
+	switch {
+	case c == slipEnd:
+		frame, drop := s.buf, s.drop
+		s.Reset()
+		if drop || len(frame) == 0 {
+			return nil, nil
+		}
+		return frame, nil
+	case s.drop:
+		return nil, nil
+	case s.esc:
+		s.esc = false
+		switch c {
+		case slipEscEnd:
+			c = slipEnd
+		case slipEscEsc:
+			c = slipEsc
+		default:
+			s.drop = true
+			return nil, ErrFraming
+		}
+	case c == slipEsc:
+		s.esc = true
+		return nil, nil
+	}
+	if len(s.buf) == s.max {
+		s.drop = true
+		return nil, ErrFrameTooLong
+	}
+	s.buf = append(s.buf, c)
+	return nil, nil
+}
+
+func (s *slip) InFrame() bool {
+	return (len(s.buf) > 0 || s.esc) && !s.drop
+}
+
+type cobs struct {
+	max  int
+	buf  []byte
+	drop bool
+}
+
+// COBS returns a Codec for frames in Consistent Overhead Byte Stuffing,
+// ended by a zero byte. Zero bytes between frames are skipped. max is the largest
+// frame, 0 for 4096 bytes.
+func COBS(max int) Codec {
+	return &cobs{max: maxFrame(max)}
+}
+
+func (c *cobs) Encode(p []byte) ([]byte, error) {
+	if len(p) > c.max {
+		return nil, ErrFrameTooLong
+	}
+	b := make([]byte, 1, len(p)+len(p)/254+2)
+	code, at := byte(1), 0
+	for i, v := range p {
+		if v != 0 {
+			b = append(b, v)
+			code++
+		}
+		// a full block at the end needs no block after it
+		if v == 0 || code == 0xff && i < len(p)-1 {
+			b[at] = code
+			code, at = 1, len(b)
+			b = append(b, 0)
+		}
+	}
+	b[at] = code
+	return append(b, 0), nil
+}
+
+func (c *cobs) Decode(v byte) ([]byte, error) {
+	if v != 0 {
+		if c.drop {
+			return nil, nil
+		}
+		// the encoding adds one byte every 254
+		if len(c.buf) > c.max+c.max/254+1 {
+			c.drop = true
+			return nil, ErrFrameTooLong
+		}
+		c.buf = append(c.buf, v)
+		return nil, nil
+	}
+	in, drop := c.buf, c.drop
+	c.Reset()
+	if drop || len(in) == 0 {
+		return nil, nil
+	}
+	frame := make([]byte, 0, len(in))
+	for i := 0; i < len(in); {
+		code := int(in[i])
+		i++
+		if i+code-1 > len(in) {
+			return nil, ErrFraming
+		}
+		frame = append(frame, in[i:i+code-1]...)
+		i += code - 1
+		if code < 0xff && i < len(in) {
+			frame = append(frame, 0)
+		}
+	}
+	if len(frame) > c.max {
+		return nil, ErrFrameTooLong
+	}
+	return frame, nil
+}
+
+func (c *cobs) InFrame() bool {
+	return len(c.buf) > 0 && !c.drop
+}
+
+func (c *cobs) Reset() {
+	c.buf = nil
+	c.drop = false
+}
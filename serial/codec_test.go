@@ -0,0 +1,223 @@
+package serial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// mustLength is LengthPrefixed with a valid size.
+func mustLength(size int, order binary.ByteOrder, max int) Codec {
+	c, err := LengthPrefixed(size, order, max)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func mustDelimited(delim []byte, max int) Codec {
+	c, err := Delimited(delim, max)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// decode feeds b to c and returns the frames and errors it gave.
+func decode(c Codec, b []byte) (frames [][]byte, errs []error) {
+	for _, v := range b {
+		frame, err := c.Decode(v)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if frame != nil {
+			frames = append(frames, frame)
+		}
+	}
+	return
+}
+
+func TestCodec_roundTrip(t *testing.T) {
+	payloads := [][]byte{
+		{},
+		[]byte("hello"),
+		{0x00, 0x02, 0x03, 0x10, 0xc0, 0xdb, 0xdc, 0xdd, 0x00},
+		bytes.Repeat([]byte{0xa5}, 254),
+		bytes.Repeat([]byte{0x00, 0x01}, 300),
+	}
+	tests := []struct {
+		name  string
+		codec func() Codec
+		empty bool // empty frames are delivered
+	}{
+		{"delimited", func() Codec { return mustDelimited([]byte{'\r', '\n'}, 0) }, true},
+		{"length 1", func() Codec { return mustLength(1, binary.BigEndian, 0) }, true},
+		{"length 2", func() Codec { return mustLength(2, binary.LittleEndian, 0) }, true},
+		{"length 4", func() Codec { return mustLength(4, binary.BigEndian, 0) }, true},
+		{"stx/etx", func() Codec { return STXETX(0) }, true},
+		{"slip", func() Codec { return SLIP(0) }, false},
+		{"cobs", func() Codec { return COBS(0) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.codec()
+			var stream []byte
+			var want [][]byte
+			for _, p := range payloads {
+				b, err := c.Encode(p)
+				if tt.name == "delimited" && bytes.Contains(p, []byte{'\r', '\n'}) ||
+					tt.name == "length 1" && len(p) > 255 {
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Encode(% x) error = %v", p, err)
+				}
+				stream = append(stream, b...)
+				if len(p) > 0 || tt.empty {
+					want = append(want, p)
+				}
+			}
+			frames, errs := decode(c, stream)
+			if errs != nil {
+				t.Fatalf("Decode errors %v", errs)
+			}
+			if len(frames) != len(want) {
+				t.Fatalf("Decode got %d frames, want %d", len(frames), len(want))
+			}
+			for i := range want {
+				if !bytes.Equal(frames[i], want[i]) {
+					t.Errorf("frame %d = % x, want % x", i, frames[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCodec_Encode(t *testing.T) {
+	block := bytes.Repeat([]byte{0xa5}, 254)
+	tests := []struct {
+		name  string
+		codec Codec
+		p     []byte
+		want  []byte
+	}{
+		{"slip", SLIP(0), []byte{0x01, 0xc0, 0xdb}, []byte{0xc0, 0x01, 0xdb, 0xdc, 0xdb, 0xdd, 0xc0}},
+		{"cobs zero", COBS(0), []byte{0x00}, []byte{0x01, 0x01, 0x00}},
+		{"cobs", COBS(0), []byte{0x11, 0x22, 0x00, 0x33}, []byte{0x03, 0x11, 0x22, 0x02, 0x33, 0x00}},
+		{"cobs empty", COBS(0), []byte{}, []byte{0x01, 0x00}},
+		{"cobs 254", COBS(0), block, append(append([]byte{0xff}, block...), 0x00)},
+		{"cobs 255", COBS(0), append(block, 0xa5), append(append([]byte{0xff}, block...), 0x02, 0xa5, 0x00)},
+		{"cobs 254 and zero", COBS(0), append(block, 0x00), append(append([]byte{0xff}, block...), 0x01, 0x01, 0x00)},
+		{"stx/etx", STXETX(0), []byte{'A', 0x03, 0x10}, []byte{0x02, 'A', 0x10, 0x03, 0x10, 0x10, 0x03}},
+		{"length", mustLength(2, binary.BigEndian, 0), []byte("ab"), []byte{0x00, 0x02, 'a', 'b'}},
+		{"delimited", mustDelimited([]byte{'\n'}, 0), []byte("ok"), []byte("ok\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.codec.Encode(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Encode(% x) = % x, want % x", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodec_errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		codec  Codec
+		in     []byte
+		frames []string
+		errs   []error
+	}{
+		{"delimited too long", mustDelimited([]byte{'\n'}, 3), []byte("abcdef\nok\n"), []string{"ok"}, []error{ErrFrameTooLong}},
+		{"delimited too long crlf", mustDelimited([]byte("\r\n"), 2), []byte("abc\r\r\nok\r\n"), []string{"ok"}, []error{ErrFrameTooLong}},
+		{"length too long", mustLength(1, binary.BigEndian, 2), []byte{3, 2, 'o', 'k'}, []string{"ok"}, []error{ErrFrameTooLong}},
+		{"stx/etx noise", STXETX(0), []byte("xy\x02ok\x03z"), []string{"ok"}, nil},
+		{"stx/etx restart", STXETX(0), []byte("\x02ab\x02ok\x03"), []string{"ok"}, []error{ErrFraming}},
+		{"stx/etx too long", STXETX(2), []byte("\x02abc\x03\x02ok\x03"), []string{"ok"}, []error{ErrFrameTooLong}},
+		{"stx/etx too long escaped stx", STXETX(2), []byte("\x02abc\x10\x02xy\x03\x02ok\x03"), []string{"ok"}, []error{ErrFrameTooLong}},
+		{"stx/etx too long restart", STXETX(2), []byte("\x02abc\x02ok\x03"), []string{"ok"}, []error{ErrFrameTooLong}},
+		{"slip bad escape", SLIP(0), []byte{0xc0, 'a', 0xdb, 'b', 'c', 0xc0, 'o', 'k', 0xc0}, []string{"ok"}, []error{ErrFraming}},
+		{"slip too long", SLIP(2), []byte{'a', 'b', 'c', 0xc0, 'o', 'k', 0xc0}, []string{"ok"}, []error{ErrFrameTooLong}},
+		{"cobs skip zeros", COBS(0), []byte{0x00, 0x00, 0x03, 'o', 'k', 0x00, 0x00}, []string{"ok"}, nil},
+		{"cobs bad code", COBS(0), []byte{0x05, 'a', 0x00, 0x03, 'o', 'k', 0x00}, []string{"ok"}, []error{ErrFraming}},
+		{"cobs too long", COBS(2), []byte{0x04, 'a', 'b', 'c', 0x05, 0x00, 0x03, 'o', 'k', 0x00}, []string{"ok"}, []error{ErrFrameTooLong}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, errs := decode(tt.codec, tt.in)
+			var got []string
+			for _, f := range frames {
+				got = append(got, string(f))
+			}
+			if len(got) != len(tt.frames) || len(errs) != len(tt.errs) {
+				t.Fatalf("Decode(% x) = %q, %v, want %q, %v", tt.in, got, errs, tt.frames, tt.errs)
+			}
+			for i := range got {
+				if got[i] != tt.frames[i] {
+					t.Errorf("frame %d = %q, want %q", i, got[i], tt.frames[i])
+				}
+			}
+			for i := range errs {
+				if errs[i] != tt.errs[i] {
+					t.Errorf("error %d = %v, want %v", i, errs[i], tt.errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCodec_Encode_errors(t *testing.T) {
+	if _, err := mustDelimited([]byte{'\n'}, 0).Encode([]byte("a\nb")); err == nil {
+		t.Error("Delimited Encode with the delimiter: no error")
+	}
+	if _, err := mustLength(1, binary.BigEndian, 0).Encode(make([]byte, 256)); err != ErrFrameTooLong {
+		t.Errorf("LengthPrefixed Encode of 256 bytes error = %v, want %v", err, ErrFrameTooLong)
+	}
+	if _, err := SLIP(4).Encode(make([]byte, 5)); err != ErrFrameTooLong {
+		t.Errorf("SLIP Encode error = %v, want %v", err, ErrFrameTooLong)
+	}
+	if _, err := LengthPrefixed(3, binary.BigEndian, 0); err == nil {
+		t.Error("LengthPrefixed(3) succeeded")
+	}
+	if _, err := Delimited(nil, 0); err == nil {
+		t.Error("Delimited(nil) succeeded")
+	}
+}
+
+func TestCodec_InFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		in    []byte
+		want  bool
+	}{
+		{"delimited", mustDelimited([]byte{'\n'}, 0), []byte("ab"), true},
+		{"delimited end", mustDelimited([]byte{'\n'}, 0), []byte("ab\n"), false},
+		{"delimited too long", mustDelimited([]byte{'\n'}, 1), []byte("abc"), false},
+		{"length prefix", mustLength(2, binary.BigEndian, 0), []byte{0x00}, true},
+		{"length data", mustLength(1, binary.BigEndian, 0), []byte{0x02, 'a'}, true},
+		{"length end", mustLength(1, binary.BigEndian, 0), []byte{0x00}, false},
+		{"stx/etx noise", STXETX(0), []byte("xy\x03"), false},
+		{"stx/etx", STXETX(0), []byte("\x02a"), true},
+		{"stx/etx end", STXETX(0), []byte("\x02a\x03"), false},
+		{"stx/etx too long", STXETX(1), []byte("\x02ab"), false},
+		{"slip end", SLIP(0), []byte{0xc0}, false},
+		{"slip", SLIP(0), []byte{0xc0, 'a'}, true},
+		{"slip bad escape", SLIP(0), []byte{0xc0, 0xdb, 'a', 'b'}, false},
+		{"cobs zeros", COBS(0), []byte{0x00, 0x00}, false},
+		{"cobs", COBS(0), []byte{0x00, 0x03, 'o'}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decode(tt.codec, tt.in)
+			if got := tt.codec.InFrame(); got != tt.want {
+				t.Errorf("InFrame() after % x = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
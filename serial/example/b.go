@@ -1,19 +1,22 @@
+// +build ignore
+
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"time"
 
-	"github.com/tarm/serial"
+	"github.com/flyingyizi/go-wiringPi/serial"
 )
 
 func main() {
-	c := &serial.Config{Name: "/dev/ttyUSB0", Baud: 9600}
-	s, err := serial.OpenPort(c)
-
+	s, err := serial.Open("/dev/ttyUSB0", serial.Config{Baud: 9600})
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
+	defer s.Close()
 
 	_, err = s.Write([]byte("\x16\x02N0C0 G A\x03\x0d\x0a"))
 
@@ -21,13 +24,19 @@ func main() {
 		fmt.Println(err)
 	}
 
-	//Which will read data until the first \x0a.
-	reader := bufio.NewReader(s)
-	reply, err := reader.ReadBytes('\x0a')
+	// The replies end with \x0a; a reply is dropped when its bytes stop
+	// coming for 100ms.
+	c, err := serial.Delimited([]byte{'\x0a'}, 0)
+	if err != nil {
+		panic(err)
+	}
+	f := serial.NewFramer(s, c, 100*time.Millisecond)
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reply, err := f.ReadFrame(ctx)
 	if err != nil {
 		panic(err)
 	}
 	fmt.Println(reply)
-
-	s.Close()
 }
@@ -0,0 +1,38 @@
+// +build ignore
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/serial"
+	"github.com/flyingyizi/go-wiringPi/serial/modbus"
+)
+
+// Reads the first ten holding registers of the slave at address 1, on an
+// RS-485 adapter.
+func main() {
+	c, err := modbus.Open("/dev/ttyUSB0",
+		serial.Config{Baud: 19200, Parity: serial.ParityEven},
+		modbus.Config{Timeout: 500 * time.Millisecond, Retries: 2})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	regs, err := c.ReadHoldingRegisters(ctx, 1, 0x0000, 10)
+	switch {
+	case errors.Is(err, modbus.IllegalDataAddress):
+		fmt.Println("the slave has no such registers")
+	case err != nil:
+		fmt.Println(err)
+	default:
+		fmt.Printf("%#04x\n", regs)
+	}
+}
@@ -0,0 +1,33 @@
+// +build ignore
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/flyingyizi/go-wiringPi/serial"
+)
+
+func main() {
+	ports, err := serial.Ports()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range ports {
+		fmt.Printf("%s driver %s", p.Name, p.Driver)
+		switch {
+		case p.Header:
+			fmt.Print(", GPIO header")
+		case p.Bluetooth:
+			fmt.Print(", Bluetooth")
+		}
+		if p.USB {
+			fmt.Printf(", USB %04x:%04x %s %s serial %s", p.VID, p.PID, p.Manufacturer, p.Product, p.SerialNumber)
+		}
+		fmt.Println()
+		for _, l := range p.ByID {
+			fmt.Println("\t", l)
+		}
+	}
+}
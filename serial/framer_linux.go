@@ -0,0 +1,194 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrInterByteTimeout is returned by ReadFrame when the bytes of a frame
+// stopped coming for longer than the inter-byte timeout; the partial
+// frame is dropped.
+var ErrInterByteTimeout = errors.New("serial: inter-byte timeout")
+
+// Frame is a frame, or an error, delivered by Framer.Frames.
+type Frame struct {
+	Data []byte
+	Err  error
+}
+
+// chunk is what one Read returned, with the time it returned.
+type chunk struct {
+	b   []byte
+	t   time.Time
+	err error
+}
+
+// Framer reads and writes the frames of a Codec on a port, or on any
+// io.ReadWriter.
+type Framer struct {
+	rw        io.ReadWriter
+	codec     Codec
+	interByte time.Duration
+
+	start  sync.Once
+	chunks chan chunk
+	done   chan struct{}
+	close  sync.Once
+
+	mu      sync.Mutex // serializes ReadFrame
+	pending []byte
+	last    time.Time // when the pending bytes came
+	err     error     // the read error, once the reader stopped
+
+	wmu sync.Mutex
+}
+
+// NewFramer returns a Framer reading and writing the frames of c on rw.
+// interByte is the longest gap between the bytes of a frame, 0 for no
+// limit; at 9600 baud a byte takes about 1ms.
+func NewFramer(rw io.ReadWriter, c Codec, interByte time.Duration) *Framer {
+	return &Framer{
+		rw:        rw,
+		codec:     c,
+		interByte: interByte,
+		chunks:    make(chan chunk),
+		done:      make(chan struct{}),
+	}
+}
+
+// reader reads rw until it fails or the Framer is closed.
+func (f *Framer) reader() {
+	for {
+		b := make([]byte, 256)
+		n, err := f.rw.Read(b)
+		if n == 0 && err == ErrTimeout {
+			// Config.ReadTimeout
+			continue
+		}
+		select {
+		case f.chunks <- chunk{b: b[:n], t: time.Now(), err: err}:
+		case <-f.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ReadFrame returns the next frame received. It returns ErrFraming,
+// ErrFrameTooLong or ErrInterByteTimeout for a bad frame, and may be
+// called again then. A read error of the port ends the frames.
+func (f *Framer) ReadFrame(ctx context.Context) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.start.Do(func() { go f.reader() })
+
+	for {
+		for len(f.pending) > 0 {
+			c := f.pending[0]
+			f.pending = f.pending[1:]
+			if frame, err := f.codec.Decode(c); err != nil || frame != nil {
+				return frame, err
+			}
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+
+		var (
+			timer  *time.Timer
+			expire <-chan time.Time
+		)
+		partial := f.interByte > 0 && f.codec.InFrame()
+		if partial {
+			timer = time.NewTimer(f.interByte - time.Since(f.last))
+			expire = timer.C
+		}
+		var err error
+		select {
+		case c := <-f.chunks:
+			gap := partial && c.t.Sub(f.last) > f.interByte
+			f.pending, f.last = c.b, c.t
+			if c.err != nil {
+				f.err = c.err
+			}
+			if gap {
+				// the bytes came late, while no ReadFrame was waiting
+				err = f.timeout()
+			}
+		case <-expire:
+			err = f.timeout()
+		case <-f.done:
+			err = errFramerClosed
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *Framer) timeout() error {
+	f.codec.Reset()
+	return ErrInterByteTimeout
+}
+
+// Frames delivers the frames received on the returned channel, until ctx
+// is done or the port fails; the last Frame then holds the read error.
+// ReadFrame must not be called meanwhile.
+func (f *Framer) Frames(ctx context.Context) <-chan Frame {
+	ch := make(chan Frame)
+	go func() {
+		defer close(ch)
+		for {
+			data, err := f.ReadFrame(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case ch <- Frame{Data: data, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil && !frameError(err) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// frameError tells whether err is about one frame only.
+func frameError(err error) bool {
+	return err == ErrFraming || err == ErrFrameTooLong || err == ErrInterByteTimeout
+}
+
+// WriteFrame encodes p and writes it.
+func (f *Framer) WriteFrame(p []byte) error {
+	b, err := f.codec.Encode(p)
+	if err != nil {
+		return err
+	}
+	f.wmu.Lock()
+	defer f.wmu.Unlock()
+	_, err = f.rw.Write(b)
+	return err
+}
+
+var errFramerClosed = errors.New("serial: framer closed")
+
+// Close stops the Framer. It does not close the port; the reading
+// goroutine ends when its Read returns, close the port to end it at
+// once.
+func (f *Framer) Close() error {
+	f.close.Do(func() { close(f.done) })
+	return nil
+}
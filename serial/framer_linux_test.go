@@ -0,0 +1,199 @@
+package serial
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+type pipe struct {
+	io.Reader
+	io.Writer
+}
+
+// newFramer returns a Framer reading what is written to w, and writing to
+// r.
+func newFramer(c Codec, interByte time.Duration) (f *Framer, w *io.PipeWriter, r *io.PipeReader) {
+	in, w := io.Pipe()
+	r, out := io.Pipe()
+	return NewFramer(pipe{in, out}, c, interByte), w, r
+}
+
+func TestFramer_ReadFrame(t *testing.T) {
+	f, w, _ := newFramer(SLIP(0), 50*time.Millisecond)
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		// two frames in one write, one in pieces, then a partial frame
+		// left hanging, and a frame after it
+		w.Write([]byte{0xc0, 'a', 0xc0, 0xc0, 'b', 'c'})
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte{0xdb, 0xdc, 0xc0})
+		w.Write([]byte{'x', 'y'})
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte{0xc0, 'o', 'k', 0xc0})
+		w.Close()
+	}()
+	tests := []struct {
+		want string
+		err  error
+	}{
+		{"a", nil},
+		{"bc\xc0", nil},
+		{"", ErrInterByteTimeout},
+		{"ok", nil},
+		{"", io.EOF},
+		{"", io.EOF},
+	}
+	for i, tt := range tests {
+		got, err := f.ReadFrame(ctx)
+		if string(got) != tt.want || err != tt.err {
+			t.Fatalf("ReadFrame %d = %q, %v, want %q, %v", i, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// A frame whose bytes came apart while nobody was reading is still
+// dropped.
+func TestFramer_lateBytes(t *testing.T) {
+	f, w, _ := newFramer(mustDelimited([]byte{'\n'}, 0), 20*time.Millisecond)
+	defer f.Close()
+	go func() {
+		w.Write([]byte("ab"))
+		time.Sleep(60 * time.Millisecond)
+		w.Write([]byte("cd\nok\n"))
+	}()
+	ctx := context.Background()
+	// the reader is started by the first ReadFrame
+	ctx0, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	f.ReadFrame(ctx0)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := f.ReadFrame(ctx); err != ErrInterByteTimeout {
+		t.Fatalf("ReadFrame error = %v, want %v", err, ErrInterByteTimeout)
+	}
+	for _, want := range []string{"cd", "ok"} {
+		got, err := f.ReadFrame(ctx)
+		if err != nil || string(got) != want {
+			t.Fatalf("ReadFrame = %q, %v, want %q", got, err, want)
+		}
+	}
+}
+
+// Silence outside frames, after noise or the zeros between COBS frames,
+// is no inter-byte timeout.
+func TestFramer_noise(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		noise []byte
+		frame []byte
+	}{
+		{"stx/etx", STXETX(0), []byte{0x16, 0x16}, []byte("\x02ok\x03")},
+		{"cobs", COBS(0), []byte{0x00, 0x00}, []byte{0x03, 'o', 'k', 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, w, _ := newFramer(tt.codec, 20*time.Millisecond)
+			defer f.Close()
+			go func() {
+				w.Write(tt.noise)
+				time.Sleep(60 * time.Millisecond)
+				w.Write(tt.frame)
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if got, err := f.ReadFrame(ctx); err != nil || string(got) != "ok" {
+				t.Fatalf("ReadFrame = %q, %v, want %q", got, err, "ok")
+			}
+		})
+	}
+}
+
+func TestFramer_context(t *testing.T) {
+	f, _, _ := newFramer(STXETX(0), 0)
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := f.ReadFrame(ctx); err != context.DeadlineExceeded {
+		t.Errorf("ReadFrame error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestFramer_Frames(t *testing.T) {
+	f, w, r := newFramer(COBS(0), 0)
+	defer f.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frames := f.Frames(ctx)
+
+	// write the frames on one side, and loop them back
+	go io.Copy(w, r)
+	errc := make(chan error, 1)
+	go func() {
+		for _, p := range []string{"one", "", "two\x00"} {
+			if err := f.WriteFrame([]byte(p)); err != nil {
+				errc <- err
+				return
+			}
+		}
+		// through the loop too, to keep the order
+		_, err := f.rw.Write([]byte{0x07, 0x00})
+		errc <- err
+	}()
+	for _, want := range []Frame{{Data: []byte("one")}, {Data: []byte{}}, {Data: []byte("two\x00")}, {Err: ErrFraming}} {
+		select {
+		case got := <-frames:
+			if string(got.Data) != string(want.Data) || got.Err != want.Err {
+				t.Fatalf("frame = %q, %v, want %q, %v", got.Data, got.Err, want.Data, want.Err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no frame")
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case _, ok := <-frames:
+		if ok {
+			t.Error("frame after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed")
+	}
+}
+
+// The read timeouts of the port do not end the frames.
+func TestFramer_port(t *testing.T) {
+	p, m := openPort(t, Config{Baud: 115200, ReadTimeout: 10 * time.Millisecond})
+	defer m.Close()
+	defer p.Close()
+	f := NewFramer(p, STXETX(0), 0)
+	defer f.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m.Write([]byte("\x16\x02N0C0 G A\x03\r\n"))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := f.ReadFrame(ctx)
+	if err != nil || string(got) != "N0C0 G A" {
+		t.Fatalf("ReadFrame = %q, %v, want %q", got, err, "N0C0 G A")
+	}
+
+	if err := f.WriteFrame([]byte{'a', ETX}); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 8)
+	n, err := m.Read(b)
+	if err != nil || string(b[:n]) != "\x02a\x10\x03\x03" {
+		t.Errorf("sent % x, %v", b[:n], err)
+	}
+}
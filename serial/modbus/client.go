@@ -0,0 +1,219 @@
+package modbus
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Port is the serial port a Client talks on, e.g. a *serial.Port opened
+// without VMin, VTime and ReadTimeout.
+type Port interface {
+	io.ReadWriter
+	SetReadDeadline(t time.Time) error
+}
+
+// Client is a Modbus RTU master. Its requests may be issued from several
+// goroutines, they go on the line one at a time.
+type Client struct {
+	p         Port
+	cfg       Config
+	char, gap time.Duration
+
+	mu   sync.Mutex
+	idle time.Time // when the line has been silent for a frame gap
+}
+
+// NewClient returns a Client on p, see Open.
+func NewClient(p Port, cfg Config) (*Client, error) {
+	if err := cfg.check(); err != nil {
+		return nil, err
+	}
+	return &Client{p: p, cfg: cfg, char: charTime(cfg.Baud), gap: frameGap(cfg.Baud)}, nil
+}
+
+// Close closes the port.
+func (c *Client) Close() error {
+	if cl, ok := c.p.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// do sends the request pdu to slave and returns the response pdu, once
+// valid accepts it. It retries according to Config.Retries, and returns
+// the last failure as an *Error.
+func (c *Client) do(ctx context.Context, op string, slave byte, pdu []byte, valid func(resp []byte) bool) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	adu := make([]byte, 0, len(pdu)+3)
+	adu = append(adu, slave)
+	adu = append(adu, pdu...)
+	crc := CRC16(adu)
+	adu = append(adu, byte(crc), byte(crc>>8))
+
+	var err error
+	for i := 0; i <= c.cfg.Retries; i++ {
+		if err = sleep(ctx, c.idle); err != nil {
+			break
+		}
+		var resp []byte
+		if resp, err = c.try(ctx, adu, valid); err == nil {
+			return resp, nil
+		}
+		if !retryable(err) {
+			break
+		}
+		c.drain()
+	}
+	return nil, &Error{Op: op, Slave: slave, Err: err}
+}
+
+// try sends the request adu once and reads the response.
+func (c *Client) try(ctx context.Context, adu []byte, valid func([]byte) bool) ([]byte, error) {
+	if _, err := c.p.Write(adu); err != nil {
+		return nil, err
+	}
+	// Write returns once the bytes are in the output buffer
+	sent := time.Now().Add(time.Duration(len(adu)) * c.char)
+	if adu[0] == 0 {
+		// a broadcast, no slave answers
+		err := sleep(ctx, sent.Add(c.cfg.TurnaroundDelay))
+		c.idle = time.Now()
+		return nil, err
+	}
+
+	resp, err := c.read(ctx, sent.Add(c.cfg.Timeout))
+	c.idle = time.Now().Add(c.gap)
+	if err != nil {
+		return nil, err
+	}
+	n := len(resp)
+	if CRC16(resp[:n-2]) != uint16(resp[n-2])|uint16(resp[n-1])<<8 {
+		return nil, ErrCRC
+	}
+	if resp[0] != adu[0] {
+		return nil, ErrResponse
+	}
+	pdu, fc := resp[1:n-2], adu[1]
+	if pdu[0] == fc|0x80 {
+		return nil, &Exception{Function: fc, Code: ExceptionCode(pdu[1])}
+	}
+	if pdu[0] != fc || !valid(pdu) {
+		return nil, ErrResponse
+	}
+	return pdu, nil
+}
+
+// read reads a response frame until deadline.
+func (c *Client) read(ctx context.Context, deadline time.Time) ([]byte, error) {
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	// a read in flight only ends with its deadline
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.p.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	// every frame has at least 5 bytes, 3 tell its length
+	buf := make([]byte, maxFrame)
+	n, need := 0, 3
+	for n < need {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := c.p.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		m, err := c.p.Read(buf[n:need])
+		n += m
+		if l := frameLength(buf[:n]); l < 0 {
+			return nil, ErrResponse
+		} else if l > 0 {
+			need = l
+		}
+		if err == nil {
+			continue
+		}
+		if !timeout(err) {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, ErrTimeout
+		}
+	}
+	return buf[:n], nil
+}
+
+// maxFrame is the length of the longest RTU frame.
+const maxFrame = 256
+
+// frameLength returns the length of the response frame starting with b,
+// 0 when b is too short to tell, -1 for an unknown function or a byte
+// count too large for a frame.
+func frameLength(b []byte) int {
+	if len(b) < 2 {
+		return 0
+	}
+	switch fc := b[1]; {
+	case fc&0x80 != 0:
+		return 5
+	case fc >= fcReadCoils && fc <= fcReadInputRegisters, fc == fcReadWriteMultipleRegisters:
+		if len(b) < 3 {
+			return 0
+		}
+		if n := 5 + int(b[2]); n <= maxFrame {
+			return n
+		}
+		return -1
+	case fc == fcWriteSingleCoil, fc == fcWriteSingleRegister, fc == fcWriteMultipleCoils, fc == fcWriteMultipleRegisters:
+		return 8
+	}
+	return -1
+}
+
+// drain discards what comes in until the line is silent for a frame gap,
+// so that the rest of a garbled or late response does not precede the
+// next one.
+func (c *Client) drain() {
+	b := make([]byte, 64)
+	for end := time.Now().Add(c.cfg.Timeout); time.Now().Before(end); {
+		c.p.SetReadDeadline(time.Now().Add(c.gap))
+		if _, err := c.p.Read(b); err != nil {
+			break
+		}
+	}
+	c.idle = time.Now()
+}
+
+func timeout(err error) bool {
+	t, ok := err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
+
+// sleep waits until t, or until ctx is done.
+func sleep(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
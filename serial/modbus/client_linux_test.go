@@ -0,0 +1,273 @@
+package modbus_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/serial"
+	"github.com/flyingyizi/go-wiringPi/serial/modbus"
+	"github.com/flyingyizi/go-wiringPi/serial/modbus/modbustest"
+	"github.com/flyingyizi/go-wiringPi/serial/serialtest"
+)
+
+// serve returns a Client talking to s over a pty, and the function
+// closing them.
+func serve(t *testing.T, s *modbustest.Slave, cfg modbus.Config) (*modbus.Client, func()) {
+	pty, err := serialtest.NewPty()
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	c, err := modbus.Open(pty.Name, serial.Config{Baud: 115200, Parity: serial.ParityEven}, cfg)
+	if err != nil {
+		pty.Close()
+		t.Fatal(err)
+	}
+	go s.Serve(pty.Master)
+	return c, func() {
+		c.Close()
+		pty.Close()
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		do    func(c *modbus.Client) (interface{}, error)
+		want  interface{}
+		check func(s *modbustest.Slave) bool
+	}{
+		{"read coils", func(c *modbus.Client) (interface{}, error) {
+			return c.ReadCoils(ctx, 1, 2, 10)
+		}, []bool{true, false, false, false, false, false, false, false, false, true}, nil},
+		{"read discrete inputs", func(c *modbus.Client) (interface{}, error) {
+			return c.ReadDiscreteInputs(ctx, 1, 0, 3)
+		}, []bool{false, true, false}, nil},
+		{"read holding registers", func(c *modbus.Client) (interface{}, error) {
+			return c.ReadHoldingRegisters(ctx, 1, 0, 3)
+		}, []uint16{0x0102, 0, 0xfffe}, nil},
+		{"read input registers", func(c *modbus.Client) (interface{}, error) {
+			return c.ReadInputRegisters(ctx, 1, 7, 1)
+		}, []uint16{0x4242}, nil},
+		{"write single coil", func(c *modbus.Client) (interface{}, error) {
+			return nil, c.WriteSingleCoil(ctx, 1, 5, true)
+		}, nil, func(s *modbustest.Slave) bool { return s.Coils[5] }},
+		{"write single register", func(c *modbus.Client) (interface{}, error) {
+			return nil, c.WriteSingleRegister(ctx, 1, 9, 0xcafe)
+		}, nil, func(s *modbustest.Slave) bool { return s.HoldingRegisters[9] == 0xcafe }},
+		{"write multiple coils", func(c *modbus.Client) (interface{}, error) {
+			return nil, c.WriteMultipleCoils(ctx, 1, 20, []bool{true, true, false, true, false, false, false, false, true})
+		}, nil, func(s *modbustest.Slave) bool {
+			return reflect.DeepEqual(s.Coils[20:29], []bool{true, true, false, true, false, false, false, false, true})
+		}},
+		{"write multiple registers", func(c *modbus.Client) (interface{}, error) {
+			return nil, c.WriteMultipleRegisters(ctx, 1, 30, []uint16{1, 2, 3})
+		}, nil, func(s *modbustest.Slave) bool { return reflect.DeepEqual(s.HoldingRegisters[30:33], []uint16{1, 2, 3}) }},
+		{"read/write multiple registers", func(c *modbus.Client) (interface{}, error) {
+			return c.ReadWriteMultipleRegisters(ctx, 1, 1, 3, 2, []uint16{0xaaaa})
+		}, []uint16{0, 0xaaaa, 0}, nil},
+		{"broadcast", func(c *modbus.Client) (interface{}, error) {
+			return nil, c.WriteSingleRegister(ctx, 0, 40, 7)
+		}, nil, func(s *modbustest.Slave) bool { return s.HoldingRegisters[40] == 7 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := modbustest.NewSlave(1, 64)
+			s.Coils[2], s.Coils[11] = true, true
+			s.DiscreteInputs[1] = true
+			s.HoldingRegisters[0], s.HoldingRegisters[2] = 0x0102, 0xfffe
+			s.InputRegisters[7] = 0x4242
+			c, done := serve(t, s, modbus.Config{Baud: 115200, Timeout: 200 * time.Millisecond, TurnaroundDelay: 20 * time.Millisecond})
+			defer done()
+
+			got, err := tt.do(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if tt.check != nil {
+				s.Lock()
+				ok := tt.check(s)
+				s.Unlock()
+				if !ok {
+					t.Error("slave data not written")
+				}
+			}
+		})
+	}
+}
+
+func TestClient_exception(t *testing.T) {
+	s := modbustest.NewSlave(1, 8)
+	c, done := serve(t, s, modbus.Config{Baud: 115200, Timeout: 200 * time.Millisecond, Retries: 2})
+	defer done()
+
+	_, err := c.ReadHoldingRegisters(context.Background(), 1, 6, 4)
+	if !errors.Is(err, modbus.IllegalDataAddress) {
+		t.Fatalf("err = %v, want %v", err, modbus.IllegalDataAddress)
+	}
+	var e *modbus.Error
+	if !errors.As(err, &e) || e.Op != "read holding registers" || e.Slave != 1 {
+		t.Errorf("err = %#v", err)
+	}
+	if n := len(s.Requests()); n != 1 {
+		t.Errorf("exception tried %d times, want 1", n)
+	}
+}
+
+func TestClient_retries(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    func(try int, resp []byte) []byte
+		retries  int
+		err      error
+		requests int
+	}{
+		{"dropped once", func(try int, resp []byte) []byte {
+			if try == 1 {
+				return nil
+			}
+			return resp
+		}, 1, nil, 2},
+		{"bad CRC once", func(try int, resp []byte) []byte {
+			if try == 1 {
+				resp[3] ^= 0x01
+			}
+			return resp
+		}, 2, nil, 2},
+		{"wrong slave once", func(try int, resp []byte) []byte {
+			if try == 1 {
+				resp[0] = 9
+				crc := modbus.CRC16(resp[:len(resp)-2])
+				resp[len(resp)-2], resp[len(resp)-1] = byte(crc), byte(crc>>8)
+			}
+			return resp
+		}, 1, nil, 2},
+		{"trailing garbage", func(try int, resp []byte) []byte {
+			if try == 1 {
+				return append([]byte{0xff}, resp...)
+			}
+			return resp
+		}, 1, nil, 2},
+		{"byte count too long", func(try int, resp []byte) []byte {
+			if try == 1 {
+				return []byte{resp[0], resp[1], 0xfc, 0x00}
+			}
+			return resp
+		}, 1, nil, 2},
+		{"never", func(try int, resp []byte) []byte { return nil }, 2, modbus.ErrTimeout, 3},
+		{"bad CRC", func(try int, resp []byte) []byte {
+			resp[len(resp)-1] ^= 0xff
+			return resp
+		}, 1, modbus.ErrCRC, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := modbustest.NewSlave(1, 8)
+			s.HoldingRegisters[0] = 0x1234
+			s.Fault = tt.fault
+			c, done := serve(t, s, modbus.Config{Baud: 115200, Timeout: 100 * time.Millisecond, Retries: tt.retries})
+			defer done()
+
+			got, err := c.ReadHoldingRegisters(context.Background(), 1, 0, 1)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && got[0] != 0x1234 {
+				t.Errorf("got %#x", got)
+			}
+			if n := len(s.Requests()); n != tt.requests {
+				t.Errorf("%d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func TestClient_retries_request(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries int
+		err     error
+	}{
+		{"retried", 1, nil},
+		{"not retried", 0, modbus.ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := modbustest.NewSlave(1, 8)
+			s.HoldingRegisters[0] = 0x1234
+			// the slave loses byte 3 of the first request
+			d, err := serialtest.Attach(s, &serialtest.Faults{Rx: serialtest.Drop(3)})
+			if err != nil {
+				t.Skipf("no pseudo-terminals: %v", err)
+			}
+			defer d.Close()
+			c, err := modbus.Open(d.Name, serial.Config{Baud: 115200, Parity: serial.ParityEven},
+				modbus.Config{Baud: 115200, Timeout: 100 * time.Millisecond, Retries: tt.retries})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			got, err := c.ReadHoldingRegisters(context.Background(), 1, 0, 1)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && got[0] != 0x1234 {
+				t.Errorf("got %#x", got)
+			}
+			if err == nil {
+				// the request missing a byte was dropped, the retry answered
+				if n := len(s.Requests()); n != 1 {
+					t.Errorf("%d requests, want 1", n)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_context(t *testing.T) {
+	s := modbustest.NewSlave(1, 8)
+	s.Delay = time.Second
+	c, done := serve(t, s, modbus.Config{Baud: 115200, Timeout: 5 * time.Second})
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := c.ReadCoils(ctx, 1, 0, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("canceled after %v", d)
+	}
+}
+
+func TestClient_invalid(t *testing.T) {
+	c, err := modbus.NewClient(nil, modbus.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.ReadCoils(ctx, 0, 0, 1); err == nil {
+		t.Error("broadcast read: no error")
+	}
+	if _, err := c.ReadHoldingRegisters(ctx, 1, 0, 126); err == nil {
+		t.Error("126 registers: no error")
+	}
+	if err := c.WriteMultipleCoils(ctx, 1, 0, nil); err == nil {
+		t.Error("no coils: no error")
+	}
+	if err := c.WriteSingleCoil(ctx, 248, 0, true); err == nil {
+		t.Error("slave 248: no error")
+	}
+	if _, err := modbus.Open("/dev/null", serial.Config{ReadTimeout: time.Second}, modbus.Config{}); err == nil {
+		t.Error("Open with a ReadTimeout: no error")
+	}
+}
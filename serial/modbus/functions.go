@@ -0,0 +1,218 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Function codes.
+const (
+	fcReadCoils                  = 1
+	fcReadDiscreteInputs         = 2
+	fcReadHoldingRegisters       = 3
+	fcReadInputRegisters         = 4
+	fcWriteSingleCoil            = 5
+	fcWriteSingleRegister        = 6
+	fcWriteMultipleCoils         = 15
+	fcWriteMultipleRegisters     = 16
+	fcReadWriteMultipleRegisters = 23
+)
+
+// Quantity limits of the requests, so that the frames fit in 256 bytes.
+const (
+	maxReadBits       = 2000
+	maxReadRegisters  = 125
+	maxWriteBits      = 1968
+	maxWriteRegisters = 123
+	maxRWRegisters    = 121 // written by function 23
+)
+
+// request builds the pdu of function fc with the 16 bit fields and the
+// data.
+func request(fc byte, fields []uint16, data []byte) []byte {
+	pdu := make([]byte, 1, 1+2*len(fields)+1+len(data))
+	pdu[0] = fc
+	for _, v := range fields {
+		pdu = append(pdu, byte(v>>8), byte(v))
+	}
+	if data != nil {
+		pdu = append(pdu, byte(len(data)))
+		pdu = append(pdu, data...)
+	}
+	return pdu
+}
+
+// check validates the slave address and the quantity of a request.
+func check(op string, slave byte, read bool, n, max int) error {
+	switch {
+	case slave > 247:
+		return &Error{Op: op, Slave: slave, Err: errors.New("invalid slave address")}
+	case slave == 0 && read:
+		return &Error{Op: op, Slave: slave, Err: errors.New("a read can't be broadcast")}
+	case n < 1 || n > max:
+		return &Error{Op: op, Slave: slave, Err: fmt.Errorf("invalid quantity %d, 1 to %d", n, max)}
+	}
+	return nil
+}
+
+// ReadCoils reads quantity coils from addr, function 1.
+func (c *Client) ReadCoils(ctx context.Context, slave byte, addr, quantity uint16) ([]bool, error) {
+	return c.readBits(ctx, "read coils", fcReadCoils, slave, addr, quantity)
+}
+
+// ReadDiscreteInputs reads quantity discrete inputs from addr, function 2.
+func (c *Client) ReadDiscreteInputs(ctx context.Context, slave byte, addr, quantity uint16) ([]bool, error) {
+	return c.readBits(ctx, "read discrete inputs", fcReadDiscreteInputs, slave, addr, quantity)
+}
+
+func (c *Client) readBits(ctx context.Context, op string, fc byte, slave byte, addr, n uint16) ([]bool, error) {
+	if err := check(op, slave, true, int(n), maxReadBits); err != nil {
+		return nil, err
+	}
+	size := (int(n) + 7) / 8
+	resp, err := c.do(ctx, op, slave, request(fc, []uint16{addr, n}, nil), func(r []byte) bool {
+		return len(r) == 2+size && int(r[1]) == size
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unpackBits(resp[2:], int(n)), nil
+}
+
+// ReadHoldingRegisters reads quantity holding registers from addr,
+// function 3.
+func (c *Client) ReadHoldingRegisters(ctx context.Context, slave byte, addr, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(ctx, "read holding registers", fcReadHoldingRegisters, slave, addr, quantity)
+}
+
+// ReadInputRegisters reads quantity input registers from addr, function 4.
+func (c *Client) ReadInputRegisters(ctx context.Context, slave byte, addr, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(ctx, "read input registers", fcReadInputRegisters, slave, addr, quantity)
+}
+
+func (c *Client) readRegisters(ctx context.Context, op string, fc byte, slave byte, addr, n uint16) ([]uint16, error) {
+	if err := check(op, slave, true, int(n), maxReadRegisters); err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, op, slave, request(fc, []uint16{addr, n}, nil), validRegisters(int(n)))
+	if err != nil {
+		return nil, err
+	}
+	return unpackRegisters(resp[2:]), nil
+}
+
+func validRegisters(n int) func([]byte) bool {
+	return func(r []byte) bool {
+		return len(r) == 2+2*n && int(r[1]) == 2*n
+	}
+}
+
+// WriteSingleCoil sets the coil at addr, function 5.
+func (c *Client) WriteSingleCoil(ctx context.Context, slave byte, addr uint16, v bool) error {
+	value := uint16(0x0000)
+	if v {
+		value = 0xff00
+	}
+	return c.writeSingle(ctx, "write single coil", fcWriteSingleCoil, slave, addr, value)
+}
+
+// WriteSingleRegister sets the holding register at addr, function 6.
+func (c *Client) WriteSingleRegister(ctx context.Context, slave byte, addr, v uint16) error {
+	return c.writeSingle(ctx, "write single register", fcWriteSingleRegister, slave, addr, v)
+}
+
+func (c *Client) writeSingle(ctx context.Context, op string, fc byte, slave byte, addr, v uint16) error {
+	if err := check(op, slave, false, 1, 1); err != nil {
+		return err
+	}
+	req := request(fc, []uint16{addr, v}, nil)
+	_, err := c.do(ctx, op, slave, req, echo(req))
+	return err
+}
+
+// echo accepts a response repeating the start of req, up to 5 bytes.
+func echo(req []byte) func([]byte) bool {
+	return func(r []byte) bool {
+		return len(r) == 5 && string(r) == string(req[:5])
+	}
+}
+
+// WriteMultipleCoils sets the coils from addr, function 15.
+func (c *Client) WriteMultipleCoils(ctx context.Context, slave byte, addr uint16, values []bool) error {
+	const op = "write multiple coils"
+	if err := check(op, slave, false, len(values), maxWriteBits); err != nil {
+		return err
+	}
+	req := request(fcWriteMultipleCoils, []uint16{addr, uint16(len(values))}, packBits(values))
+	_, err := c.do(ctx, op, slave, req, echo(req))
+	return err
+}
+
+// WriteMultipleRegisters sets the holding registers from addr, function
+// 16.
+func (c *Client) WriteMultipleRegisters(ctx context.Context, slave byte, addr uint16, values []uint16) error {
+	const op = "write multiple registers"
+	if err := check(op, slave, false, len(values), maxWriteRegisters); err != nil {
+		return err
+	}
+	req := request(fcWriteMultipleRegisters, []uint16{addr, uint16(len(values))}, packRegisters(values))
+	_, err := c.do(ctx, op, slave, req, echo(req))
+	return err
+}
+
+// ReadWriteMultipleRegisters sets the holding registers from writeAddr,
+// then reads quantity holding registers from readAddr, in one
+// transaction, function 23.
+func (c *Client) ReadWriteMultipleRegisters(ctx context.Context, slave byte, readAddr, quantity, writeAddr uint16, values []uint16) ([]uint16, error) {
+	const op = "read/write multiple registers"
+	if err := check(op, slave, true, int(quantity), maxReadRegisters); err != nil {
+		return nil, err
+	}
+	if err := check(op, slave, true, len(values), maxRWRegisters); err != nil {
+		return nil, err
+	}
+	req := request(fcReadWriteMultipleRegisters,
+		[]uint16{readAddr, quantity, writeAddr, uint16(len(values))}, packRegisters(values))
+	resp, err := c.do(ctx, op, slave, req, validRegisters(int(quantity)))
+	if err != nil {
+		return nil, err
+	}
+	return unpackRegisters(resp[2:]), nil
+}
+
+// packBits packs v, the first bit in the low bit of the first byte.
+func packBits(v []bool) []byte {
+	b := make([]byte, (len(v)+7)/8)
+	for i, on := range v {
+		if on {
+			b[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return b
+}
+
+func unpackBits(b []byte, n int) []bool {
+	v := make([]bool, n)
+	for i := range v {
+		v[i] = b[i/8]&(1<<uint(i%8)) != 0
+	}
+	return v
+}
+
+func packRegisters(v []uint16) []byte {
+	b := make([]byte, 2*len(v))
+	for i, r := range v {
+		binary.BigEndian.PutUint16(b[2*i:], r)
+	}
+	return b
+}
+
+func unpackRegisters(b []byte) []uint16 {
+	v := make([]uint16, len(b)/2)
+	for i := range v {
+		v[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return v
+}
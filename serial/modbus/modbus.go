@@ -0,0 +1,178 @@
+// Package modbus is a Modbus RTU master on a serial port, e.g. RS-485
+// sensors and drives:
+//
+//	c, err := modbus.Open("/dev/ttyUSB0", serial.Config{Baud: 19200, Parity: serial.ParityEven}, modbus.Config{Retries: 2})
+//	...
+//	regs, err := c.ReadHoldingRegisters(ctx, 1, 0x0000, 10)
+//
+// The frames are separated by the 3.5 characters of silence derived from
+// the baud rate. The end of a response is found from its length, not from
+// a silence: Linux hands the bytes over late by a few ms (the tty flip
+// buffer, USB adapters polling every 1 to 16ms).
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config is the setup of a Client.
+type Config struct {
+	// Baud is the rate of the port, for the frame timing, 0 for 9600.
+	Baud int
+	// Timeout bounds the wait for a response, from the end of the
+	// request, 0 for 1s.
+	Timeout time.Duration
+	// Retries is the number of tries after the first one, when a response
+	// times out or is garbled. Exceptions are not retried.
+	Retries int
+	// TurnaroundDelay is the wait after a broadcast, for the slaves to
+	// process it, 0 for 100ms.
+	TurnaroundDelay time.Duration
+}
+
+// check validates c and fills in the defaults.
+func (c *Config) check() error {
+	if c.Baud == 0 {
+		c.Baud = 9600
+	} else if c.Baud < 0 {
+		return fmt.Errorf("modbus: invalid baud rate %d", c.Baud)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = time.Second
+	} else if c.Timeout < 0 {
+		return fmt.Errorf("modbus: invalid timeout %v", c.Timeout)
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("modbus: invalid retries %d", c.Retries)
+	}
+	if c.TurnaroundDelay == 0 {
+		c.TurnaroundDelay = 100 * time.Millisecond
+	} else if c.TurnaroundDelay < 0 {
+		return fmt.Errorf("modbus: invalid turnaround delay %v", c.TurnaroundDelay)
+	}
+	return nil
+}
+
+// charTime is the time a character takes on the line: 11 bits, a start
+// bit, 8 data bits, a parity bit or a second stop bit, and a stop bit.
+func charTime(baud int) time.Duration {
+	return time.Duration(11 * int64(time.Second) / int64(baud))
+}
+
+// frameGap is the silence between two frames, 3.5 characters, fixed at
+// 1750µs above 19200 baud.
+func frameGap(baud int) time.Duration {
+	if baud > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return time.Duration(77 * int64(time.Second) / int64(2*baud))
+}
+
+// Error kinds. Every error returned by a Client request is an *Error,
+// whose Err is one of these, an *Exception, the context error or the
+// error of the port.
+var (
+	// ErrTimeout : no response came in time.
+	ErrTimeout = errors.New("no response")
+	// ErrCRC : the response came with a bad CRC.
+	ErrCRC = errors.New("CRC error")
+	// ErrResponse : the response does not match the request.
+	ErrResponse = errors.New("invalid response")
+)
+
+// Error records a failed request together with the slave it was sent to.
+type Error struct {
+	Op    string // request, e.g. "read holding registers"
+	Slave byte   // slave address, 0 for a broadcast
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("modbus %s on slave %d: %v", e.Op, e.Slave, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error { return e.Err }
+
+// retryable reports whether err is worth another try.
+func retryable(err error) bool {
+	return err == ErrTimeout || err == ErrCRC || err == ErrResponse
+}
+
+// ExceptionCode is the code of an exception response. It is an error, so
+// that errors.Is(err, modbus.IllegalDataAddress) tells the exception.
+type ExceptionCode byte
+
+const (
+	IllegalFunction              ExceptionCode = 0x01
+	IllegalDataAddress           ExceptionCode = 0x02
+	IllegalDataValue             ExceptionCode = 0x03
+	ServerDeviceFailure          ExceptionCode = 0x04
+	Acknowledge                  ExceptionCode = 0x05
+	ServerDeviceBusy             ExceptionCode = 0x06
+	MemoryParityError            ExceptionCode = 0x08
+	GatewayPathUnavailable       ExceptionCode = 0x0a
+	GatewayTargetFailedToRespond ExceptionCode = 0x0b
+)
+
+var exceptionNames = map[ExceptionCode]string{
+	IllegalFunction:              "illegal function",
+	IllegalDataAddress:           "illegal data address",
+	IllegalDataValue:             "illegal data value",
+	ServerDeviceFailure:          "server device failure",
+	Acknowledge:                  "acknowledge",
+	ServerDeviceBusy:             "server device busy",
+	MemoryParityError:            "memory parity error",
+	GatewayPathUnavailable:       "gateway path unavailable",
+	GatewayTargetFailedToRespond: "gateway target device failed to respond",
+}
+
+func (c ExceptionCode) Error() string {
+	if s, ok := exceptionNames[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("exception %#02x", byte(c))
+}
+
+// Exception is an exception response of a slave.
+type Exception struct {
+	Function byte // function code of the request
+	Code     ExceptionCode
+}
+
+func (e *Exception) Error() string {
+	return fmt.Sprintf("exception %d (%v) to function %d", e.Code, e.Code, e.Function)
+}
+
+// Is reports whether target is the code of e.
+func (e *Exception) Is(target error) bool {
+	c, ok := target.(ExceptionCode)
+	return ok && c == e.Code
+}
+
+var crcTable [256]uint16
+
+func init() {
+	for i := range crcTable {
+		crc := uint16(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+		crcTable[i] = crc
+	}
+}
+
+// CRC16 returns the Modbus CRC of b, sent low byte first.
+func CRC16(b []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, v := range b {
+		crc = crc>>8 ^ crcTable[byte(crc)^v]
+	}
+	return crc
+}
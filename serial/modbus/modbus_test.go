@@ -0,0 +1,91 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want uint16
+	}{
+		{"read holding registers", []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0a}, 0xcdc5},
+		{"read coils", []byte{0x11, 0x01, 0x00, 0x13, 0x00, 0x25}, 0x840e},
+		{"empty", nil, 0xffff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CRC16(tt.b); got != tt.want {
+				t.Errorf("CRC16(% x) = %#04x, want %#04x", tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_frameGap(t *testing.T) {
+	tests := []struct {
+		baud int
+		want time.Duration
+	}{
+		{1200, 32083333 * time.Nanosecond},
+		{9600, 4010416 * time.Nanosecond},
+		{19200, 2005208 * time.Nanosecond},
+		{38400, 1750 * time.Microsecond},
+		{115200, 1750 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		if got := frameGap(tt.baud); got != tt.want {
+			t.Errorf("frameGap(%d) = %v, want %v", tt.baud, got, tt.want)
+		}
+	}
+	if got := charTime(9600); got != 1145833*time.Nanosecond {
+		t.Errorf("charTime(9600) = %v", got)
+	}
+}
+
+func Test_frameLength(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want int
+	}{
+		{"short", []byte{1}, 0},
+		{"exception", []byte{1, 0x83}, 5},
+		{"read, no count", []byte{1, 3}, 0},
+		{"read", []byte{1, 3, 4}, 9},
+		{"read/write", []byte{1, 23, 2}, 7},
+		{"write", []byte{1, 16}, 8},
+		{"unknown", []byte{1, 0x2b}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := frameLength(tt.b); got != tt.want {
+				t.Errorf("frameLength(% x) = %d, want %d", tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestException(t *testing.T) {
+	var err error = &Error{Op: "read coils", Slave: 3, Err: &Exception{Function: 1, Code: IllegalDataAddress}}
+	if !errors.Is(err, IllegalDataAddress) {
+		t.Error("errors.Is(err, IllegalDataAddress) = false")
+	}
+	if errors.Is(err, IllegalDataValue) {
+		t.Error("errors.Is(err, IllegalDataValue) = true")
+	}
+	var e *Exception
+	if !errors.As(err, &e) || e.Function != 1 {
+		t.Errorf("errors.As(err) = %v", e)
+	}
+	want := "modbus read coils on slave 3: exception 2 (illegal data address) to function 1"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if s := ExceptionCode(0x42).Error(); s != "exception 0x42" {
+		t.Errorf("ExceptionCode(0x42).Error() = %q", s)
+	}
+}
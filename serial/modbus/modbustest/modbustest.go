@@ -0,0 +1,346 @@
+// Package modbustest provides an in-process Modbus RTU slave to test
+// masters without hardware. It is a serialtest.Model, so it can sit on a
+// pseudo-terminal with the faults of serialtest:
+//
+//	s := modbustest.NewSlave(1, 100)
+//	d, _ := serialtest.Attach(s, &serialtest.Faults{Rx: serialtest.Drop(3)})
+//	c, _ := modbus.Open(d.Name, serial.Config{Baud: 19200}, modbus.Config{})
+//
+// or serve any byte stream with Serve.
+package modbustest
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/serial/modbus"
+	"github.com/flyingyizi/go-wiringPi/serial/serialtest"
+)
+
+// Slave is a Modbus slave holding its coils, discrete inputs, holding and
+// input registers in memory. Requests addressed to other slaves are
+// ignored, broadcasts are carried out without a response, and so are the
+// requests with a bad CRC, as a real slave does.
+type Slave struct {
+	ID byte
+
+	mu               sync.Mutex
+	Coils            []bool
+	DiscreteInputs   []bool
+	HoldingRegisters []uint16
+	InputRegisters   []uint16
+
+	// Delay is the wait before each response.
+	Delay time.Duration
+	// Gap is the silence that ends a frame, 0 for 3.5 characters at 9600
+	// baud. An incomplete request followed by a gap is dropped, as a real
+	// slave does, so the next one is read from its start.
+	Gap time.Duration
+	// Fault, when set, is given each response frame before it is sent
+	// (the try number counts from 1), and returns the bytes to send
+	// instead, nil for none: a response dropped, corrupted or split.
+	Fault func(try int, resp []byte) []byte
+	// Exception, when set, answers the requests with it instead.
+	Exception modbus.ExceptionCode
+
+	requests [][]byte
+
+	// rx is the partial request kept by Receive, last when it came
+	rmu  sync.Mutex
+	rx   []byte
+	last time.Time
+}
+
+var _ serialtest.Model = (*Slave)(nil)
+
+// NewSlave returns a slave at address id, with n of each coils, inputs
+// and registers.
+func NewSlave(id byte, n int) *Slave {
+	return &Slave{
+		ID:               id,
+		Coils:            make([]bool, n),
+		DiscreteInputs:   make([]bool, n),
+		HoldingRegisters: make([]uint16, n),
+		InputRegisters:   make([]uint16, n),
+	}
+}
+
+// Lock locks the slave data, while a test sets or checks it.
+func (s *Slave) Lock() { s.mu.Lock() }
+
+// Unlock unlocks the slave data.
+func (s *Slave) Unlock() { s.mu.Unlock() }
+
+// Requests returns the request frames received, for this slave or not.
+func (s *Slave) Requests() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([][]byte(nil), s.requests...)
+}
+
+const defaultGap = 77 * time.Second / (2 * 9600)
+
+func (s *Slave) gap() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Gap <= 0 {
+		return defaultGap
+	}
+	return s.Gap
+}
+
+// Receive implements serialtest.Model: it takes the bytes of the requests
+// and returns the responses. A partial request is kept for the next call,
+// unless that comes after Gap.
+func (s *Slave) Receive(b []byte) []byte {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+
+	now := time.Now()
+	if len(s.rx) > 0 && now.Sub(s.last) > s.gap() {
+		s.rx = s.rx[:0]
+	}
+	s.last = now
+	var out []byte
+	s.rx, _ = s.frames(append(s.rx, b...), func(resp []byte) error {
+		out = append(out, resp...)
+		return nil
+	})
+	return out
+}
+
+// Serve reads the requests from rw and writes the responses, until a read
+// fails.
+func (s *Slave) Serve(rw io.ReadWriter) error {
+	gap := s.gap()
+
+	var buf []byte
+	b := make([]byte, 256)
+	for {
+		start := time.Now()
+		n, err := rw.Read(b)
+		if len(buf) > 0 && time.Since(start) > gap {
+			// the line was silent after an incomplete frame
+			buf = buf[:0]
+		}
+		var werr error
+		buf, werr = s.frames(append(buf, b[:n]...), func(resp []byte) error {
+			_, err := rw.Write(resp)
+			return err
+		})
+		if werr != nil {
+			return werr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// frames handles the complete requests at the start of buf, passing the
+// responses to send, and returns the rest of buf.
+func (s *Slave) frames(buf []byte, send func(resp []byte) error) ([]byte, error) {
+	for {
+		l := requestLength(buf)
+		if l == 0 || l > len(buf) {
+			return buf, nil
+		}
+		if l < 0 {
+			// an unknown function, the frame is what came
+			l = len(buf)
+		}
+		if resp := s.frame(buf[:l]); resp != nil {
+			if err := send(resp); err != nil {
+				return buf, err
+			}
+		}
+		buf = buf[l:]
+	}
+}
+
+// requestLength returns the length of the request frame starting with b,
+// 0 when b is too short to tell, -1 for an unknown function.
+func requestLength(b []byte) int {
+	if len(b) < 2 {
+		return 0
+	}
+	switch b[1] {
+	case 1, 2, 3, 4, 5, 6:
+		return 8
+	case 15, 16:
+		if len(b) < 7 {
+			return 0
+		}
+		return 9 + int(b[6])
+	case 23:
+		if len(b) < 11 {
+			return 0
+		}
+		return 13 + int(b[10])
+	}
+	return -1
+}
+
+// frame handles a request frame, and returns the response frame, nil for
+// none.
+func (s *Slave) frame(req []byte) []byte {
+	s.mu.Lock()
+	s.requests = append(s.requests, append([]byte(nil), req...))
+	try := 0
+	for _, r := range s.requests {
+		if string(r) == string(req) {
+			try++
+		}
+	}
+	delay, fault := s.Delay, s.Fault
+	s.mu.Unlock()
+
+	n := len(req)
+	if n < 4 || modbus.CRC16(req[:n-2]) != uint16(req[n-2])|uint16(req[n-1])<<8 {
+		return nil
+	}
+	if req[0] != s.ID && req[0] != 0 {
+		return nil
+	}
+	pdu := s.Handle(req[1 : n-2])
+	if req[0] == 0 {
+		return nil
+	}
+	resp := append([]byte{s.ID}, pdu...)
+	crc := modbus.CRC16(resp)
+	resp = append(resp, byte(crc), byte(crc>>8))
+	if fault != nil {
+		resp = fault(try, resp)
+	}
+	time.Sleep(delay)
+	return resp
+}
+
+// Handle carries out the request pdu and returns the response pdu.
+func (s *Slave) Handle(pdu []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(pdu) == 0 {
+		return []byte{0x80, byte(modbus.IllegalFunction)}
+	}
+	fc := pdu[0]
+	exception := func(c modbus.ExceptionCode) []byte {
+		return []byte{fc | 0x80, byte(c)}
+	}
+	switch fc {
+	case 1, 2, 3, 4, 5, 6, 15, 16, 23:
+	default:
+		return exception(modbus.IllegalFunction)
+	}
+	if s.Exception != 0 {
+		return exception(s.Exception)
+	}
+	u16 := func(i int) int {
+		if len(pdu) < i+2 {
+			return -1
+		}
+		return int(binary.BigEndian.Uint16(pdu[i:]))
+	}
+	addr, n := u16(1), u16(3)
+	if addr < 0 || n < 0 {
+		return exception(modbus.IllegalDataValue)
+	}
+	in := func(size int) bool { return addr+n <= size }
+
+	switch fc {
+	case 1, 2:
+		bits := s.Coils
+		if fc == 2 {
+			bits = s.DiscreteInputs
+		}
+		if n < 1 || n > 2000 {
+			return exception(modbus.IllegalDataValue)
+		}
+		if !in(len(bits)) {
+			return exception(modbus.IllegalDataAddress)
+		}
+		data := make([]byte, (n+7)/8)
+		for i, on := range bits[addr : addr+n] {
+			if on {
+				data[i/8] |= 1 << uint(i%8)
+			}
+		}
+		return append([]byte{fc, byte(len(data))}, data...)
+	case 3, 4:
+		regs := s.HoldingRegisters
+		if fc == 4 {
+			regs = s.InputRegisters
+		}
+		if n < 1 || n > 125 {
+			return exception(modbus.IllegalDataValue)
+		}
+		if !in(len(regs)) {
+			return exception(modbus.IllegalDataAddress)
+		}
+		return append([]byte{fc, byte(2 * n)}, registers(regs[addr:addr+n])...)
+	case 5:
+		if n != 0 && n != 0xff00 {
+			return exception(modbus.IllegalDataValue)
+		}
+		if addr >= len(s.Coils) {
+			return exception(modbus.IllegalDataAddress)
+		}
+		s.Coils[addr] = n == 0xff00
+		return append([]byte(nil), pdu[:5]...)
+	case 6:
+		if addr >= len(s.HoldingRegisters) {
+			return exception(modbus.IllegalDataAddress)
+		}
+		s.HoldingRegisters[addr] = uint16(n)
+		return append([]byte(nil), pdu[:5]...)
+	case 15:
+		if n < 1 || n > 1968 || len(pdu) != 6+(n+7)/8 || int(pdu[5]) != (n+7)/8 {
+			return exception(modbus.IllegalDataValue)
+		}
+		if !in(len(s.Coils)) {
+			return exception(modbus.IllegalDataAddress)
+		}
+		for i := 0; i < n; i++ {
+			s.Coils[addr+i] = pdu[6+i/8]&(1<<uint(i%8)) != 0
+		}
+		return append([]byte(nil), pdu[:5]...)
+	case 16:
+		if n < 1 || n > 123 || len(pdu) != 6+2*n || int(pdu[5]) != 2*n {
+			return exception(modbus.IllegalDataValue)
+		}
+		if !in(len(s.HoldingRegisters)) {
+			return exception(modbus.IllegalDataAddress)
+		}
+		for i := 0; i < n; i++ {
+			s.HoldingRegisters[addr+i] = binary.BigEndian.Uint16(pdu[6+2*i:])
+		}
+		return append([]byte(nil), pdu[:5]...)
+	case 23:
+		waddr, wn := u16(5), u16(7)
+		if n < 1 || n > 125 || waddr < 0 || wn < 1 || wn > 121 || len(pdu) != 10+2*wn || int(pdu[9]) != 2*wn {
+			return exception(modbus.IllegalDataValue)
+		}
+		if !in(len(s.HoldingRegisters)) || waddr+wn > len(s.HoldingRegisters) {
+			return exception(modbus.IllegalDataAddress)
+		}
+		// the write is carried out before the read
+		for i := 0; i < wn; i++ {
+			s.HoldingRegisters[waddr+i] = binary.BigEndian.Uint16(pdu[10+2*i:])
+		}
+		return append([]byte{fc, byte(2 * n)}, registers(s.HoldingRegisters[addr:addr+n])...)
+	}
+	return nil
+}
+
+func registers(v []uint16) []byte {
+	b := make([]byte, 2*len(v))
+	for i, r := range v {
+		binary.BigEndian.PutUint16(b[2*i:], r)
+	}
+	return b
+}
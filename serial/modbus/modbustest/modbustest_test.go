@@ -0,0 +1,149 @@
+package modbustest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/serial/modbus"
+)
+
+func TestSlave_Handle(t *testing.T) {
+	tests := []struct {
+		name string
+		pdu  []byte
+		want []byte
+	}{
+		{"read coils", []byte{1, 0x00, 0x01, 0x00, 0x0a}, []byte{1, 2, 0x05, 0x02}},
+		{"read holding", []byte{3, 0x00, 0x02, 0x00, 0x02}, []byte{3, 4, 0x12, 0x34, 0x00, 0x00}},
+		{"read input", []byte{4, 0x00, 0x00, 0x00, 0x01}, []byte{4, 2, 0xbe, 0xef}},
+		{"write coil", []byte{5, 0x00, 0x0f, 0xff, 0x00}, []byte{5, 0x00, 0x0f, 0xff, 0x00}},
+		{"write coil value", []byte{5, 0x00, 0x0f, 0x12, 0x34}, []byte{0x85, 3}},
+		{"write registers", []byte{16, 0x00, 0x0e, 0x00, 0x02, 4, 0, 1, 0, 2}, []byte{16, 0x00, 0x0e, 0x00, 0x02}},
+		{"write registers past the end", []byte{16, 0x00, 0x0f, 0x00, 0x02, 4, 0, 1, 0, 2}, []byte{0x90, 2}},
+		{"read write", []byte{23, 0x00, 0x02, 0x00, 0x01, 0x00, 0x02, 0x00, 0x01, 2, 0xab, 0xcd}, []byte{23, 2, 0xab, 0xcd}},
+		{"read past the end", []byte{3, 0x00, 0x0f, 0x00, 0x02}, []byte{0x83, 2}},
+		{"no quantity", []byte{3, 0x00, 0x00, 0x00, 0x00}, []byte{0x83, 3}},
+		{"short", []byte{3, 0x00}, []byte{0x83, 3}},
+		{"unknown function", []byte{0x2b, 0x0e}, []byte{0xab, 1}},
+		{"empty", nil, []byte{0x80, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSlave(1, 16)
+			s.Coils[1], s.Coils[3], s.Coils[10] = true, true, true
+			s.HoldingRegisters[2] = 0x1234
+			s.InputRegisters[0] = 0xbeef
+			if got := s.Handle(tt.pdu); !bytes.Equal(got, tt.want) {
+				t.Errorf("Handle(% x) = % x, want % x", tt.pdu, got, tt.want)
+			}
+		})
+	}
+
+	s := NewSlave(1, 16)
+	s.Exception = modbus.ServerDeviceBusy
+	if got := s.Handle([]byte{3, 0, 0, 0, 1}); !bytes.Equal(got, []byte{0x83, 6}) {
+		t.Errorf("Handle with an exception = % x", got)
+	}
+}
+
+type rw struct {
+	io.Reader
+	io.Writer
+}
+
+// frame appends the CRC to b.
+func frame(b ...byte) []byte {
+	crc := modbus.CRC16(b)
+	return append(b, byte(crc), byte(crc>>8))
+}
+
+func TestSlave_Serve(t *testing.T) {
+	s := NewSlave(1, 16)
+	s.HoldingRegisters[0] = 0x0102
+	var in, out bytes.Buffer
+	// a request for slave 2, one with a bad CRC, a good one, and a
+	// broadcast
+	in.Write(frame(0x02, 0x03, 0x00, 0x00, 0x00, 0x01))
+	in.Write([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00})
+	in.Write(frame(0x01, 0x03, 0x00, 0x00, 0x00, 0x01))
+	in.Write(frame(0x00, 0x06, 0x00, 0x01, 0xab, 0xcd))
+	if err := s.Serve(rw{&in, &out}); err != io.EOF {
+		t.Errorf("Serve() error = %v, want EOF", err)
+	}
+	want := frame(0x01, 0x03, 0x02, 0x01, 0x02)
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Serve wrote % x, want % x", out.Bytes(), want)
+	}
+	if n := len(s.Requests()); n != 4 {
+		t.Errorf("%d requests, want 4", n)
+	}
+	if s.HoldingRegisters[1] != 0xabcd {
+		t.Errorf("broadcast not carried out, register 1 = %#x", s.HoldingRegisters[1])
+	}
+}
+
+// chunks is a reader returning its chunks one by one, after their delay.
+type chunks []struct {
+	delay time.Duration
+	b     []byte
+}
+
+func (c *chunks) Read(p []byte) (int, error) {
+	if len(*c) == 0 {
+		return 0, io.EOF
+	}
+	ch := (*c)[0]
+	*c = (*c)[1:]
+	time.Sleep(ch.delay)
+	return copy(p, ch.b), nil
+}
+
+func TestSlave_Serve_gap(t *testing.T) {
+	s := NewSlave(1, 16)
+	s.HoldingRegisters[0] = 0x0102
+	s.Gap = 5 * time.Millisecond
+	req := frame(0x01, 0x03, 0x00, 0x00, 0x00, 0x01)
+	// a request missing a byte, silence, then the request in two reads
+	in := chunks{
+		{0, append(append([]byte(nil), req[:3]...), req[4:]...)},
+		{50 * time.Millisecond, req[:5]},
+		{0, req[5:]},
+	}
+	var out bytes.Buffer
+	if err := s.Serve(rw{&in, &out}); err != io.EOF {
+		t.Errorf("Serve() error = %v, want EOF", err)
+	}
+	want := frame(0x01, 0x03, 0x02, 0x01, 0x02)
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Serve wrote % x, want % x", out.Bytes(), want)
+	}
+	if got := s.Requests(); len(got) != 1 || !bytes.Equal(got[0], req) {
+		t.Errorf("requests % x, want % x", got, req)
+	}
+}
+
+func TestSlave_Receive(t *testing.T) {
+	s := NewSlave(1, 16)
+	s.HoldingRegisters[0] = 0x0102
+	s.Gap = 5 * time.Millisecond
+	req := frame(0x01, 0x03, 0x00, 0x00, 0x00, 0x01)
+	want := frame(0x01, 0x03, 0x02, 0x01, 0x02)
+
+	if got := s.Receive(req[:3]); got != nil {
+		t.Errorf("Receive of a partial request = % x", got)
+	}
+	if got := s.Receive(req[3:]); !bytes.Equal(got, want) {
+		t.Errorf("Receive = % x, want % x", got, want)
+	}
+	// a request missing its end, silence, then a whole one
+	s.Receive(req[:5])
+	time.Sleep(20 * time.Millisecond)
+	if got := s.Receive(req); !bytes.Equal(got, want) {
+		t.Errorf("Receive after a gap = % x, want % x", got, want)
+	}
+	if n := len(s.Requests()); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+}
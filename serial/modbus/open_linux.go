@@ -0,0 +1,31 @@
+package modbus
+
+import (
+	"errors"
+
+	"github.com/flyingyizi/go-wiringPi/serial"
+)
+
+// Open opens the serial device name with sc, e.g. 19200 baud, 8 data
+// bits, even parity (the Modbus default), and an RS485 setup, and returns
+// a Client on it. cfg.Baud is taken from sc.
+// The client must be closed once it is no longer in use.
+func Open(name string, sc serial.Config, cfg Config) (*Client, error) {
+	if sc.VMin > 0 || sc.VTime > 0 || sc.ReadTimeout > 0 {
+		return nil, errors.New("modbus: the client times the reads, VMin, VTime and ReadTimeout must be 0")
+	}
+	cfg.Baud = sc.Baud
+	if cfg.Baud == 0 {
+		cfg.Baud = 9600
+	}
+	p, err := serial.Open(name, sc)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(p, cfg)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,137 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// FlowControl is the flow control of a port.
+type FlowControl int
+
+const (
+	FlowNone FlowControl = iota
+	// FlowHardware is RTS/CTS: the port sends while CTS is asserted and
+	// asserts RTS while it can receive.
+	FlowHardware
+	// FlowSoftware is XON/XOFF, 0x11 and 0x13 in the data.
+	FlowSoftware
+)
+
+// Lines are modem control lines, the TIOCM_ bits.
+type Lines uint32
+
+const (
+	DTR Lines = 0x002 // data terminal ready, output
+	RTS Lines = 0x004 // request to send, output
+	CTS Lines = 0x020 // clear to send, input
+	DCD Lines = 0x040 // data carrier detect, input
+	RI  Lines = 0x080 // ring indicator, input
+	DSR Lines = 0x100 // data set ready, input
+
+	inputLines = CTS | DCD | RI | DSR
+)
+
+var lineNames = []struct {
+	l    Lines
+	name string
+}{{DTR, "DTR"}, {RTS, "RTS"}, {CTS, "CTS"}, {DCD, "DCD"}, {RI, "RI"}, {DSR, "DSR"}}
+
+// String returns the lines set, e.g. "DTR|CTS".
+func (l Lines) String() string {
+	var s []string
+	for _, n := range lineNames {
+		if l&n.l != 0 {
+			s = append(s, n.name)
+			l &^= n.l
+		}
+	}
+	if l != 0 {
+		s = append(s, fmt.Sprintf("%#x", uint32(l)))
+	}
+	if len(s) == 0 {
+		return "0"
+	}
+	return strings.Join(s, "|")
+}
+
+// Lines returns the modem lines asserted.
+func (p *Port) Lines() (Lines, error) {
+	var l Lines
+	err := p.ioctl(tiocmget, unsafe.Pointer(&l))
+	return l, err
+}
+
+func (p *Port) setLines(l Lines, on bool) error {
+	req := uintptr(tiocmbic)
+	if on {
+		req = tiocmbis
+	}
+	return p.ioctl(req, unsafe.Pointer(&l))
+}
+
+// SetRTS asserts or releases RTS. With FlowHardware the UART drives it.
+func (p *Port) SetRTS(on bool) error {
+	return p.setLines(RTS, on)
+}
+
+// SetDTR asserts or releases DTR.
+func (p *Port) SetDTR(on bool) error {
+	return p.setLines(DTR, on)
+}
+
+// WaitLines waits until one of the input lines (CTS, DCD, RI, DSR) in
+// lines changes, with TIOCMIWAIT, and returns the lines then asserted.
+// The ioctl can't be interrupted: when ctx is done first it is left
+// waiting, on a copy of the descriptor, until the next change, and the
+// tty stays open until then, even once the port is closed. Close lifts
+// Exclusive in that case, so that the port can be opened again.
+func (p *Port) WaitLines(ctx context.Context, lines Lines) (Lines, error) {
+	if lines&inputLines == 0 || lines&^inputLines != 0 {
+		return 0, fmt.Errorf("serial: can't wait for %v", lines)
+	}
+	rc, err := p.f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	// a copy, so that Close does not wait for the ioctl
+	fd := -1
+	rc.Control(func(f uintptr) { fd, err = syscall.Dup(int(f)) })
+	if err != nil {
+		return 0, os.NewSyscallError("dup", err)
+	}
+	done := make(chan error, 1)
+	atomic.AddInt32(&p.waits, 1)
+	go func() {
+		done <- ioctlInt(uintptr(fd), tiocmiwait, uintptr(lines))
+		syscall.Close(fd)
+		atomic.AddInt32(&p.waits, -1)
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			return 0, os.NewSyscallError("ioctl", err)
+		}
+		return p.Lines()
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Break sends a break, the line held at 0 for d, once the bytes written
+// are sent.
+func (p *Port) Break(d time.Duration) error {
+	if err := p.Drain(); err != nil {
+		return err
+	}
+	if err := p.ioctlInt(tiocsbrk, 0); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return p.ioctlInt(tioccbrk, 0)
+}
@@ -0,0 +1,122 @@
+package serial
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLines_String(t *testing.T) {
+	tests := []struct {
+		l    Lines
+		want string
+	}{
+		{0, "0"},
+		{RTS, "RTS"},
+		{DTR | CTS | DSR, "DTR|CTS|DSR"},
+		{DCD | RI | 0x001, "DCD|RI|0x1"},
+	}
+	for _, tt := range tests {
+		if got := tt.l.String(); got != tt.want {
+			t.Errorf("Lines(%#x).String() = %q, want %q", uint32(tt.l), got, tt.want)
+		}
+	}
+}
+
+func Test_setFlow(t *testing.T) {
+	tests := []struct {
+		name  string
+		flow  FlowControl
+		cflag uint32
+		iflag uint32
+	}{
+		{"none", FlowNone, 0, 0},
+		{"hardware", FlowHardware, crtscts, 0},
+		{"software", FlowSoftware, 0, ixon | ixoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tio := termios2{cflag: crtscts, iflag: ixany}
+			tio.setFlow(tt.flow)
+			if tio.cflag != tt.cflag || tio.iflag != tt.iflag {
+				t.Errorf("cflag %#o iflag %#o, want %#o %#o", tio.cflag, tio.iflag, tt.cflag, tt.iflag)
+			}
+			if tt.flow == FlowSoftware && (tio.cc[vstart] != 0x11 || tio.cc[vstop] != 0x13) {
+				t.Errorf("start/stop = %#x/%#x", tio.cc[vstart], tio.cc[vstop])
+			}
+		})
+	}
+}
+
+func TestPort_softwareFlow(t *testing.T) {
+	p, m := openPort(t, Config{FlowControl: FlowSoftware, ReadTimeout: 50 * time.Millisecond})
+	defer m.Close()
+	defer p.Close()
+
+	read := func(n int) string {
+		buf := make([]byte, n)
+		m.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		got := 0
+		for got < n {
+			k, err := m.Read(buf[got:])
+			got += k
+			if err != nil {
+				break
+			}
+		}
+		return string(buf[:got])
+	}
+
+	// XOFF stops the output, Write waits; XON restarts it. Neither is
+	// read.
+	m.Write([]byte{0x13})
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Write([]byte("abc"))
+		done <- err
+	}()
+	if got := read(3); got != "" {
+		t.Errorf("sent %q after XOFF", got)
+	}
+	m.Write([]byte{0x11})
+	if got := read(3); got != "abc" {
+		t.Errorf("sent %q after XON, want abc", got)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if n, err := p.Read(make([]byte, 8)); err != ErrTimeout {
+		t.Errorf("XON/XOFF read: %d, %v", n, err)
+	}
+}
+
+func TestPort_Break(t *testing.T) {
+	p, m := openPort(t, Config{})
+	defer m.Close()
+	defer p.Close()
+
+	start := time.Now()
+	if err := p.Break(20 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("Break() took %v", d)
+	}
+}
+
+func TestPort_WaitLines(t *testing.T) {
+	p, m := openPort(t, Config{})
+	defer m.Close()
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := p.WaitLines(ctx, RTS); err == nil {
+		t.Error("WaitLines(RTS) succeeded, RTS is an output")
+	}
+	// a pty has no modem lines: the ioctl fails at once
+	if _, err := p.WaitLines(ctx, CTS|DCD); err == nil || err == context.DeadlineExceeded {
+		t.Errorf("WaitLines() on a pty: %v", err)
+	}
+}
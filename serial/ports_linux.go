@@ -0,0 +1,177 @@
+package serial
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flyingyizi/go-wiringPi/board"
+)
+
+// Where the kernel puts ttys, variables for the tests.
+var (
+	devDir   = "/dev"
+	sysfsTTY = "/sys/class/tty"
+)
+
+// boardHasBluetooth tells whether the PL011 of the Pi drives Bluetooth,
+// a variable for the tests. Off a Pi the board is unknown and has none.
+var boardHasBluetooth = func() bool {
+	info, _, _ := board.GetBoardInfo()
+	return info.HasBluetooth()
+}
+
+// UART tells which UART of the Pi a port is.
+type UART int
+
+const (
+	UARTNone  UART = iota // not a UART of the Pi, e.g. a USB adapter
+	UARTPL011             // ARM PL011, /dev/ttyAMA*
+	UARTMini              // mini UART of the auxiliary peripheral, /dev/ttyS0
+)
+
+// PortInfo describes a serial port.
+type PortInfo struct {
+	// Name is the device node, e.g. "/dev/ttyUSB0".
+	Name string
+	// ByID are the links to it in /dev/serial/by-id, which keep their
+	// name across reboots.
+	ByID []string
+	// Driver is the kernel driver, e.g. "ftdi_sio" or "uart-pl011".
+	Driver string
+
+	UART UART
+	// Header tells the port is on the GPIO header pins 8 and 10
+	// (/dev/serial0), Bluetooth that it drives the Bluetooth chip
+	// (/dev/serial1).
+	Header    bool
+	Bluetooth bool
+
+	// USB tells the port is a USB adapter, with the ids and strings of
+	// the USB device.
+	USB          bool
+	VID, PID     uint16
+	SerialNumber string
+	Manufacturer string
+	Product      string
+}
+
+// Ports lists the serial ports: /dev/ttyAMA*, /dev/ttyS*, /dev/ttyUSB*,
+// /dev/ttyACM* and the targets of /dev/serial/by-id, sorted by name.
+// ttyS ports without hardware are left out.
+func Ports() ([]PortInfo, error) {
+	found := map[string]bool{}
+	var names []string
+	add := func(name string) {
+		if !found[name] {
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, pattern := range []string{"ttyAMA*", "ttyS*", "ttyUSB*", "ttyACM*"} {
+		matches, err := filepath.Glob(filepath.Join(devDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			add(m)
+		}
+	}
+	byID := map[string][]string{}
+	links, _ := filepath.Glob(filepath.Join(devDir, "serial/by-id/*"))
+	for _, l := range links {
+		if target, err := filepath.EvalSymlinks(l); err == nil {
+			byID[target] = append(byID[target], l)
+			add(target)
+		}
+	}
+	sort.Strings(names)
+
+	serial0, _ := filepath.EvalSymlinks(filepath.Join(devDir, "serial0"))
+	serial1, _ := filepath.EvalSymlinks(filepath.Join(devDir, "serial1"))
+	bluetooth := boardHasBluetooth()
+
+	var ports []PortInfo
+	for _, name := range names {
+		p := PortInfo{Name: name, ByID: byID[name]}
+		base := filepath.Base(name)
+		if strings.HasPrefix(base, "ttyS") && readString(filepath.Join(sysfsTTY, base, "type")) == "0" {
+			// the 8250 driver makes ttyS0 to ttyS3 whether there is a
+			// UART or not, the others are PORT_UNKNOWN
+			continue
+		}
+		dev, _ := filepath.EvalSymlinks(filepath.Join(sysfsTTY, base, "device"))
+		for dev != "" {
+			driver, err := filepath.EvalSymlinks(filepath.Join(dev, "driver"))
+			if err != nil {
+				break
+			}
+			if !strings.Contains(driver, "/serial-base/") {
+				p.Driver = filepath.Base(driver)
+				break
+			}
+			// since Linux 6.3 the tty hangs below a serial core port
+			// and controller, then the UART
+			dev = filepath.Dir(dev)
+		}
+
+		switch {
+		case p.Driver == "uart-pl011" || strings.HasPrefix(base, "ttyAMA"):
+			p.UART = UARTPL011
+		case p.Driver == "bcm2835-aux-uart":
+			p.UART = UARTMini
+		}
+		switch {
+		case serial0 != "" || serial1 != "":
+			p.Header = name == serial0
+			p.Bluetooth = name == serial1
+		case p.UART != UARTNone:
+			// the first PL011 goes to Bluetooth when there is one, and
+			// the mini UART to the header in its place
+			p.Bluetooth = bluetooth && base == "ttyAMA0"
+			p.Header = bluetooth && p.UART == UARTMini || !bluetooth && base == "ttyAMA0"
+		}
+		if dev != "" {
+			p.usb(dev)
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+// usb fills in the USB fields from the first USB device above dev.
+func (p *PortInfo) usb(dev string) {
+	for d := dev; d != "/" && d != "."; d = filepath.Dir(d) {
+		vid, err := readHex(filepath.Join(d, "idVendor"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		p.USB = true
+		p.VID = vid
+		p.PID, _ = readHex(filepath.Join(d, "idProduct"))
+		p.SerialNumber = readString(filepath.Join(d, "serial"))
+		p.Manufacturer = readString(filepath.Join(d, "manufacturer"))
+		p.Product = readString(filepath.Join(d, "product"))
+		return
+	}
+}
+
+func readString(name string) string {
+	b, _ := ioutil.ReadFile(name)
+	return strings.TrimSpace(string(b))
+}
+
+func readHex(name string) (uint16, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(b)), 16, 16)
+	return uint16(v), err
+}
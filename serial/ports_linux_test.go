@@ -0,0 +1,120 @@
+package serial
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPorts(t *testing.T) {
+	root, err := ioutil.TempDir("", "serialports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer func(d, s string, bt func() bool) { devDir, sysfsTTY, boardHasBluetooth = d, s, bt }(devDir, sysfsTTY, boardHasBluetooth)
+	devDir = filepath.Join(root, "dev")
+	sysfsTTY = filepath.Join(root, "sys/class/tty")
+
+	write := func(p, content string) {
+		p = filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(p, target string) {
+		p = filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(root, target)
+		}
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink(target, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// tty links to its device, and the device to its driver
+	tty := func(name, dev, driver string) {
+		write("dev/"+name, "")
+		link("sys/class/tty/"+name+"/device", "sys/devices/"+dev)
+		link("sys/devices/"+dev+"/driver", "sys/bus/drivers/"+driver)
+	}
+	usbDevice := func(dev, vid, pid, serial, manufacturer, product string) {
+		write("sys/devices/"+dev+"/idVendor", vid+"\n")
+		write("sys/devices/"+dev+"/idProduct", pid+"\n")
+		write("sys/devices/"+dev+"/serial", serial+"\n")
+		write("sys/devices/"+dev+"/manufacturer", manufacturer+"\n")
+		write("sys/devices/"+dev+"/product", product+"\n")
+	}
+
+	// Linux 6.3 puts serial core devices between the tty and the UART
+	tty("ttyAMA0", "platform/soc/3f201000.serial/3f201000.serial:0/3f201000.serial:0.0", "serial-base/drivers/port")
+	link("sys/devices/platform/soc/3f201000.serial/3f201000.serial:0/driver", "sys/bus/serial-base/drivers/ctrl")
+	link("sys/devices/platform/soc/3f201000.serial/driver", "sys/bus/amba/drivers/uart-pl011")
+	tty("ttyS0", "platform/soc/3f215040.serial", "bcm2835-aux-uart")
+	write("sys/class/tty/ttyS0/type", "4\n")
+	tty("ttyS1", "platform/serial8250", "serial8250")
+	write("sys/class/tty/ttyS1/type", "0\n")
+	tty("ttyUSB0", "usb1/1-1/1-1.2/1-1.2:1.0/ttyUSB0", "ftdi_sio")
+	usbDevice("usb1/1-1/1-1.2", "0403", "6001", "A6008isP", "FTDI", "FT232R USB UART")
+	tty("ttyACM0", "usb1/1-1/1-1.3/1-1.3:1.0", "cdc_acm")
+	usbDevice("usb1/1-1/1-1.3", "2341", "0043", "7523", "Arduino (www.arduino.cc)", "Uno")
+	link("dev/serial/by-id/usb-FTDI_FT232R_USB_UART_A6008isP-if00-port0", "dev/ttyUSB0")
+
+	dev := func(name string) string { return filepath.Join(devDir, name) }
+	usb := []PortInfo{
+		{Name: dev("ttyACM0"), Driver: "cdc_acm", USB: true, VID: 0x2341, PID: 0x43, SerialNumber: "7523",
+			Manufacturer: "Arduino (www.arduino.cc)", Product: "Uno"},
+		{Name: dev("ttyUSB0"), Driver: "ftdi_sio", USB: true, VID: 0x403, PID: 0x6001, SerialNumber: "A6008isP",
+			Manufacturer: "FTDI", Product: "FT232R USB UART",
+			ByID: []string{dev("serial/by-id/usb-FTDI_FT232R_USB_UART_A6008isP-if00-port0")}},
+	}
+	tests := []struct {
+		name      string
+		links     bool // /dev/serial0 and /dev/serial1
+		bluetooth bool
+		pl011     PortInfo
+		mini      PortInfo
+	}{
+		{"bluetooth", false, true,
+			PortInfo{Name: dev("ttyAMA0"), Driver: "uart-pl011", UART: UARTPL011, Bluetooth: true},
+			PortInfo{Name: dev("ttyS0"), Driver: "bcm2835-aux-uart", UART: UARTMini, Header: true}},
+		{"no bluetooth", false, false,
+			PortInfo{Name: dev("ttyAMA0"), Driver: "uart-pl011", UART: UARTPL011, Header: true},
+			PortInfo{Name: dev("ttyS0"), Driver: "bcm2835-aux-uart", UART: UARTMini}},
+		{"swapped by an overlay", true, true,
+			PortInfo{Name: dev("ttyAMA0"), Driver: "uart-pl011", UART: UARTPL011, Header: true},
+			PortInfo{Name: dev("ttyS0"), Driver: "bcm2835-aux-uart", UART: UARTMini, Bluetooth: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(dev("serial0"))
+			os.Remove(dev("serial1"))
+			if tt.links {
+				os.Symlink("ttyAMA0", dev("serial0"))
+				os.Symlink("ttyS0", dev("serial1"))
+			}
+			boardHasBluetooth = func() bool { return tt.bluetooth }
+
+			got, err := Ports()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []PortInfo{usb[0], tt.pl011, tt.mini, usb[1]}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Ports() =\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
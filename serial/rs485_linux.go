@@ -0,0 +1,126 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/flyingyizi/go-wiringPi/gpio"
+)
+
+// RS485 is the setup of a port driving an RS-485 transceiver. The UART
+// asserts RTS, wired to the transceiver driver enable, while it sends;
+// when the UART has no RS-485 mode, Write toggles DriverEnable instead.
+type RS485 struct {
+	// ActiveLow drives RTS (and DriverEnable) low while sending.
+	ActiveLow bool
+	// DelayBeforeSend and DelayAfterSend are waited after enabling the
+	// driver and before disabling it. In the UART's RS-485 mode the
+	// kernel counts them in whole milliseconds, they are rounded up.
+	DelayBeforeSend time.Duration
+	DelayAfterSend  time.Duration
+	// RxDuringTx keeps receiving while sending, the port then reads
+	// back what it sends.
+	RxDuringTx bool
+
+	// DriverEnable is the GPIO line used when the UART has no RS-485
+	// mode, a *gpio.Pin for instance, nil to fail then. Its timing is as
+	// good as the scheduler.
+	DriverEnable gpio.Line
+}
+
+func (r *RS485) check() error {
+	if r.DelayBeforeSend < 0 || r.DelayAfterSend < 0 {
+		return errors.New("serial: negative RS-485 delay")
+	}
+	return nil
+}
+
+// serial_rs485 flags
+const (
+	serRS485Enabled      = 1 << 0
+	serRS485RTSOnSend    = 1 << 1
+	serRS485RTSAfterSend = 1 << 2
+	serRS485RxDuringTx   = 1 << 4
+)
+
+// serialRS485 is struct serial_rs485 of linux/serial.h.
+type serialRS485 struct {
+	flags              uint32
+	delayRTSBeforeSend uint32 // ms
+	delayRTSAfterSend  uint32 // ms
+	padding            [5]uint32
+}
+
+func ms(d time.Duration) uint32 {
+	return uint32((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+func (r *RS485) kernel() serialRS485 {
+	k := serialRS485{
+		flags:              serRS485Enabled | serRS485RTSOnSend,
+		delayRTSBeforeSend: ms(r.DelayBeforeSend),
+		delayRTSAfterSend:  ms(r.DelayAfterSend),
+	}
+	if r.ActiveLow {
+		k.flags = serRS485Enabled | serRS485RTSAfterSend
+	}
+	if r.RxDuringTx {
+		k.flags |= serRS485RxDuringTx
+	}
+	return k
+}
+
+// setRS485 enables the RS-485 mode of the UART, or returns the driver
+// enable pin to toggle when it has none.
+func setRS485(fd uintptr, r *RS485) (gpio.Line, error) {
+	k := r.kernel()
+	err := ioctl(fd, tiocsrs485, unsafe.Pointer(&k))
+	if err == nil {
+		return nil, nil
+	}
+	if r.DriverEnable == nil {
+		return nil, fmt.Errorf("serial: no RS-485 mode (%v) and no driver enable pin", err)
+	}
+	// disabled before it drives, a glitch would garble the bus
+	if err = r.drive(false); err != nil {
+		return nil, err
+	}
+	r.DriverEnable.Output()
+	return r.DriverEnable, nil
+}
+
+// drive enables or disables the transceiver driver with DriverEnable.
+func (r *RS485) drive(on bool) error {
+	if on != r.ActiveLow {
+		return r.DriverEnable.High()
+	}
+	return r.DriverEnable.Low()
+}
+
+// writeDE writes b with the driver enabled, as the kernel RS-485 mode
+// does: the driver is disabled once the bytes are sent, and what was
+// read back meanwhile is dropped, unless RxDuringTx.
+func (p *Port) writeDE(b []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := p.cfg.RS485
+	if err = r.drive(true); err != nil {
+		return 0, err
+	}
+	time.Sleep(r.DelayBeforeSend)
+	n, err = p.f.Write(b)
+	if err == nil {
+		err = p.Drain()
+	}
+	time.Sleep(r.DelayAfterSend)
+	if err == nil && !r.RxDuringTx {
+		err = p.ioctlInt(tcflsh, tciflush)
+	}
+	if derr := r.drive(false); err == nil {
+		err = derr
+	}
+	return n, err
+}
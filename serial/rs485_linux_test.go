@@ -0,0 +1,97 @@
+package serial
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestRS485_kernel(t *testing.T) {
+	tests := []struct {
+		name string
+		r    RS485
+		want serialRS485
+	}{
+		{"default", RS485{}, serialRS485{flags: serRS485Enabled | serRS485RTSOnSend}},
+		{"active low", RS485{ActiveLow: true}, serialRS485{flags: serRS485Enabled | serRS485RTSAfterSend}},
+		{"rx during tx", RS485{RxDuringTx: true}, serialRS485{flags: serRS485Enabled | serRS485RTSOnSend | serRS485RxDuringTx}},
+		{"delays", RS485{DelayBeforeSend: time.Millisecond, DelayAfterSend: 1500 * time.Microsecond},
+			serialRS485{flags: serRS485Enabled | serRS485RTSOnSend, delayRTSBeforeSend: 1, delayRTSAfterSend: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.kernel(); got != tt.want {
+				t.Errorf("kernel() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if s := unsafe.Sizeof(serialRS485{}); s != 32 {
+		t.Errorf("sizeof(struct serial_rs485) = %d, want 32", s)
+	}
+}
+
+// pin records the levels it is set to.
+type pin struct {
+	output bool
+	levels []bool
+}
+
+func (p *pin) Input()              { p.output = false }
+func (p *pin) Output()             { p.output = true }
+func (p *pin) High() error         { p.levels = append(p.levels, true); return nil }
+func (p *pin) Low() error          { p.levels = append(p.levels, false); return nil }
+func (p *pin) Read() (uint, error) { return 0, nil }
+
+func TestRS485_driverEnable(t *testing.T) {
+	tests := []struct {
+		name      string
+		activeLow bool
+		want      []bool
+	}{
+		{"active high", false, []bool{false, true, false}},
+		{"active low", true, []bool{true, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			de := &pin{}
+			// a pty has no RS-485 mode
+			p, m := openPort(t, Config{RS485: &RS485{
+				ActiveLow:       tt.activeLow,
+				DelayBeforeSend: 10 * time.Millisecond,
+				DelayAfterSend:  10 * time.Millisecond,
+				DriverEnable:    de,
+			}})
+			defer m.Close()
+			defer p.Close()
+
+			if !de.output || len(de.levels) != 1 {
+				t.Fatalf("driver enable after Open: %+v", de)
+			}
+			start := time.Now()
+			if n, err := p.Write([]byte("abc")); n != 3 || err != nil {
+				t.Fatalf("Write() = %d, %v", n, err)
+			}
+			if d := time.Since(start); d < 20*time.Millisecond {
+				t.Errorf("Write() took %v, want the delays", d)
+			}
+			if !reflect.DeepEqual(de.levels, tt.want) {
+				t.Errorf("driver enable went %v, want %v", de.levels, tt.want)
+			}
+			buf := make([]byte, 3)
+			if n, err := m.Read(buf); err != nil || string(buf[:n]) != "abc" {
+				t.Errorf("read %q, %v", buf[:n], err)
+			}
+		})
+	}
+
+	mm, name := openPty(t)
+	defer mm.Close()
+	if p, err := Open(name, Config{RS485: &RS485{}}); err == nil {
+		p.Close()
+		t.Error("Open() without RS-485 mode nor driver enable pin succeeded")
+	}
+	if _, err := Open(name, Config{FlowControl: FlowHardware, RS485: &RS485{DriverEnable: &pin{}}}); err == nil {
+		t.Error("Open() with RS-485 and RTS/CTS flow control succeeded")
+	}
+}
@@ -1,11 +1,302 @@
 // +build linux
 
+// Package serial drives serial ports (the Pi UARTs, USB adapters) with
+// termios ioctls, without cgo. Ports are always in raw mode: bytes go
+// through unchanged, with no echo, line editing or signal characters.
 package serial
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
+
+	"github.com/flyingyizi/go-wiringPi/gpio"
+)
+
+// Parity is the parity bit of a frame.
+type Parity byte
+
+const (
+	ParityNone  Parity = 'N'
+	ParityOdd   Parity = 'O'
+	ParityEven  Parity = 'E'
+	ParityMark  Parity = 'M' // always 1
+	ParitySpace Parity = 'S' // always 0
 )
 
+// Config is the setup of a port.
+type Config struct {
+	// Baud is the rate in bits per second, 0 for 9600. Rates without a
+	// termios Bxxx constant (e.g. 250000 for DMX) are set with BOTHER,
+	// when the UART can do them.
+	Baud int
+	// DataBits is 5 to 8, 0 for 8.
+	DataBits int
+	// Parity is 0 for none.
+	Parity Parity
+	// StopBits is 1 or 2, 0 for 1.
+	StopBits int
+
+	// ReadTimeout bounds each Read, which returns ErrTimeout when no
+	// byte came in time. 0 waits for ever, or until the read deadline.
+	ReadTimeout time.Duration
+	// VMin and VTime select the termios read timing instead: a Read
+	// returns once VMin bytes came, or VTime after the last byte (at
+	// most 25.5s, in tenths of a second), see termios(3). A Read timing
+	// out with nothing returns ErrTimeout. Deadlines are not available
+	// then, and ReadTimeout is ignored.
+	VMin  uint8
+	VTime time.Duration
+
+	// Exclusive denies other opens of the port while it is open
+	// (TIOCEXCL); root is not denied.
+	Exclusive bool
+
+	// FlowControl is FlowNone, FlowHardware or FlowSoftware.
+	FlowControl FlowControl
+	// RS485 sets the port up to drive an RS-485 transceiver, nil for
+	// none.
+	RS485 *RS485
+}
+
+// termiosTiming tells whether reads follow VMIN and VTIME.
+func (c *Config) termiosTiming() bool {
+	return c.VMin > 0 || c.VTime > 0
+}
+
+// check validates c and fills in the defaults.
+func (c *Config) check() error {
+	if c.Baud == 0 {
+		c.Baud = 9600
+	} else if c.Baud < 0 {
+		return fmt.Errorf("serial: invalid baud rate %d", c.Baud)
+	}
+	if c.DataBits == 0 {
+		c.DataBits = 8
+	} else if c.DataBits < 5 || c.DataBits > 8 {
+		return fmt.Errorf("serial: invalid data bits %d", c.DataBits)
+	}
+	switch c.Parity {
+	case 0:
+		c.Parity = ParityNone
+	case ParityNone, ParityOdd, ParityEven, ParityMark, ParitySpace:
+	default:
+		return fmt.Errorf("serial: invalid parity %q", c.Parity)
+	}
+	if c.StopBits == 0 {
+		c.StopBits = 1
+	} else if c.StopBits != 1 && c.StopBits != 2 {
+		return fmt.Errorf("serial: invalid stop bits %d", c.StopBits)
+	}
+	if c.ReadTimeout < 0 {
+		return fmt.Errorf("serial: invalid read timeout %v", c.ReadTimeout)
+	}
+	if c.FlowControl < FlowNone || c.FlowControl > FlowSoftware {
+		return fmt.Errorf("serial: invalid flow control %d", c.FlowControl)
+	}
+	if c.RS485 != nil {
+		if c.FlowControl == FlowHardware {
+			return errors.New("serial: RTS drives the RS-485 transceiver, it can't do flow control")
+		}
+		if err := c.RS485.check(); err != nil {
+			return err
+		}
+	}
+	var t termios2
+	return t.setTimeouts(c.VMin, c.VTime)
+}
+
+// ErrTimeout is returned by Read when Config.ReadTimeout or Config.VTime
+// expires with no byte read.
+var ErrTimeout error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "serial: read timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Port is an open serial port.
+type Port struct {
+	f   *os.File
+	cfg Config
+
+	// de is the driver enable pin toggled by Write, nil when the UART
+	// drives the RS-485 transceiver itself
+	de gpio.Line
+	mu sync.Mutex // serializes the writes with de
+
+	// waits counts the WaitLines ioctls in flight, see Close
+	waits int32
+}
+
+// Open opens the serial device name, e.g. "/dev/serial0" or
+// "/dev/ttyUSB0", and sets it up with cfg.
+// All ports must be closed once they are no longer in use.
+func Open(name string, cfg Config) (*Port, error) {
+	if err := cfg.check(); err != nil {
+		return nil, err
+	}
+	// O_NONBLOCK so that the open does not wait for the carrier
+	fd, err := syscall.Open(name, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	if err = setup(uintptr(fd), &cfg); err == nil && cfg.termiosTiming() {
+		// VMIN and VTIME only apply to blocking reads; os.NewFile
+		// leaves a blocking descriptor out of the poller
+		err = syscall.SetNonblock(fd, false)
+	}
+	var de gpio.Line
+	if err == nil && cfg.RS485 != nil {
+		de, err = setRS485(uintptr(fd), cfg.RS485)
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &Port{f: os.NewFile(uintptr(fd), name), cfg: cfg, de: de}, nil
+}
+
+func setup(fd uintptr, cfg *Config) error {
+	if cfg.Exclusive {
+		if err := ioctl(fd, tiocexcl, nil); err != nil {
+			return err
+		}
+	}
+	var t termios2
+	if err := ioctl(fd, tcgets2, unsafe.Pointer(&t)); err != nil {
+		return err
+	}
+	t.makeRaw()
+	t.setBaud(cfg.Baud)
+	t.setFrame(cfg.DataBits, cfg.Parity, cfg.StopBits)
+	t.setFlow(cfg.FlowControl)
+	min, timeout := cfg.VMin, cfg.VTime
+	if !cfg.termiosTiming() {
+		// reads go through the poller, they return what is there
+		min, timeout = 1, 0
+	}
+	if err := t.setTimeouts(min, timeout); err != nil {
+		return err
+	}
+	return ioctl(fd, tcsets2, unsafe.Pointer(&t))
+}
+
+// ioctl runs an ioctl on the port without taking its descriptor out of
+// the poller, as File.Fd does.
+func (p *Port) ioctl(req uintptr, arg unsafe.Pointer) error {
+	return p.control(func(fd uintptr) error { return ioctl(fd, req, arg) })
+}
+
+func (p *Port) ioctlInt(req, arg uintptr) error {
+	return p.control(func(fd uintptr) error { return ioctlInt(fd, req, arg) })
+}
+
+func (p *Port) control(f func(fd uintptr) error) error {
+	rc, err := p.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err = rc.Control(func(fd uintptr) { ferr = f(fd) }); err != nil {
+		return err
+	}
+	if ferr != nil {
+		return &os.SyscallError{Syscall: "ioctl", Err: ferr}
+	}
+	return nil
+}
+
+// Name returns the name the port was opened with.
+func (p *Port) Name() string {
+	return p.f.Name()
+}
+
+// Read reads up to len(b) bytes, it waits for at least one, see
+// Config.ReadTimeout and Config.VTime.
+func (p *Port) Read(b []byte) (n int, err error) {
+	if p.cfg.termiosTiming() {
+		n, err = p.f.Read(b)
+		if n == 0 && err == io.EOF {
+			// read(2) returned 0: VTIME expired
+			err = ErrTimeout
+		}
+		return
+	}
+	if p.cfg.ReadTimeout > 0 {
+		if err = p.f.SetReadDeadline(time.Now().Add(p.cfg.ReadTimeout)); err != nil {
+			return 0, err
+		}
+		n, err = p.f.Read(b)
+		if n == 0 && os.IsTimeout(err) {
+			err = ErrTimeout
+		}
+		return
+	}
+	return p.f.Read(b)
+}
+
+// Write writes b, it returns once the bytes are in the output buffer,
+// see Drain. With an RS-485 driver enable pin, it returns once the bytes
+// are sent and the transceiver released.
+func (p *Port) Write(b []byte) (int, error) {
+	if p.de != nil {
+		return p.writeDE(b)
+	}
+	return p.f.Write(b)
+}
+
+// SetReadDeadline sets the deadline of the Read calls, when reads do not
+// use VMIN and VTIME. Config.ReadTimeout overrides it.
+func (p *Port) SetReadDeadline(t time.Time) error {
+	return p.f.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of the Write calls, when reads do
+// not use VMIN and VTIME.
+func (p *Port) SetWriteDeadline(t time.Time) error {
+	return p.f.SetWriteDeadline(t)
+}
+
+// Flush discards the bytes received and not read yet, and those written
+// and not sent yet.
+func (p *Port) Flush() error {
+	return p.ioctlInt(tcflsh, tcioflush)
+}
+
+// Drain waits until the bytes written are sent.
+func (p *Port) Drain() error {
+	// TCSBRK with a non zero argument is tcdrain
+	return p.ioctlInt(tcsbrk, 1)
+}
+
+// Buffered returns the number of bytes received and not read yet, and the
+// number of bytes written and not sent yet.
+func (p *Port) Buffered() (in, out int, err error) {
+	var n int32
+	if err = p.ioctl(tiocinq, unsafe.Pointer(&n)); err != nil {
+		return
+	}
+	in = int(n)
+	if err = p.ioctl(tiocoutq, unsafe.Pointer(&n)); err != nil {
+		return
+	}
+	return in, int(n), nil
+}
+
+// Close closes the port. A WaitLines given up on keeps the tty open
+// until the lines change, Close then lifts Exclusive so that it does not
+// outlive the port.
+func (p *Port) Close() error {
+	if p.cfg.Exclusive && atomic.LoadInt32(&p.waits) > 0 {
+		p.ioctl(tiocnxcl, nil)
+	}
+	return p.f.Close()
+}
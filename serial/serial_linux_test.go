@@ -0,0 +1,332 @@
+package serial
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/flyingyizi/go-wiringPi/serial/serialtest"
+)
+
+// pty is the master side of a pseudo-terminal, its Close closes both
+// sides.
+type pty struct {
+	*os.File
+	p *serialtest.Pty
+}
+
+func (m pty) Close() error { return m.p.Close() }
+
+// openPty returns the master side of a new pseudo-terminal and the name
+// of its slave side. The master must be closed.
+func openPty(t *testing.T) (pty, string) {
+	p, err := serialtest.NewPty()
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	return pty{p.Master, p}, p.Name
+}
+
+func openPort(t *testing.T, cfg Config) (*Port, pty) {
+	m, name := openPty(t)
+	p, err := Open(name, cfg)
+	if err != nil {
+		m.Close()
+		t.Fatal(err)
+	}
+	return p, m
+}
+
+func (p *Port) termios(t *testing.T) termios2 {
+	var tio termios2
+	if err := p.ioctl(tcgets2, unsafe.Pointer(&tio)); err != nil {
+		t.Fatal(err)
+	}
+	return tio
+}
+
+func Test_termios2(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		cflag uint32 // expected under baud, size, parity and stop bits
+		speed uint32
+	}{
+		{"default", Config{}, 0000015 | cs8, 9600},
+		{"115200 8N1", Config{Baud: 115200}, 0010002 | cs8, 115200},
+		{"7E1", Config{Baud: 1200, DataBits: 7, Parity: ParityEven}, 0000011 | cs7 | parenb, 1200},
+		{"8O2", Config{Baud: 4800, Parity: ParityOdd, StopBits: 2}, 0000014 | cs8 | parenb | parodd | cstopb, 4800},
+		{"mark", Config{Baud: 9600, Parity: ParityMark}, 0000015 | cs8 | parenb | parodd | cmspar, 9600},
+		{"space", Config{Baud: 9600, DataBits: 5, Parity: ParitySpace}, 0000015 | cs5 | parenb | cmspar, 9600},
+		{"DMX", Config{Baud: 250000, StopBits: 2}, bother | cs8 | cstopb, 250000},
+		{"MIDI", Config{Baud: 31250}, bother | cs8, 31250},
+	}
+	const mask = cbaud | cibaud | csize | parenb | parodd | cmspar | cstopb
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.check(); err != nil {
+				t.Fatal(err)
+			}
+			// start from a cooked 7E1 terminal at 38400
+			tio := termios2{iflag: icrnl | ixon, oflag: opost, cflag: 0000017 | cs7 | parenb | hupcl, lflag: icanon | echo | isig}
+			tio.makeRaw()
+			tio.setBaud(tt.cfg.Baud)
+			tio.setFrame(tt.cfg.DataBits, tt.cfg.Parity, tt.cfg.StopBits)
+
+			if got := tio.cflag & mask; got != tt.cflag {
+				t.Errorf("cflag = %#o, want %#o", got, tt.cflag)
+			}
+			if tio.ospeed != tt.speed || tio.ispeed != tt.speed {
+				t.Errorf("speed = %d/%d, want %d", tio.ispeed, tio.ospeed, tt.speed)
+			}
+			if tio.lflag != 0 || tio.oflag != 0 || tio.iflag&^inpck != 0 || tio.cflag&(cread|clocal|hupcl) != cread|clocal {
+				t.Errorf("not raw: %+v", tio)
+			}
+			if got := tio.iflag&inpck != 0; got != (tt.cfg.Parity != ParityNone) {
+				t.Errorf("parity check = %v", got)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	// the pty driver keeps the speed but forces 8 bits without parity
+	tests := []struct {
+		name  string
+		cfg   Config
+		speed uint32
+	}{
+		{"default", Config{}, 9600},
+		{"115200", Config{Baud: 115200}, 115200},
+		{"BOTHER", Config{Baud: 250000}, 250000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, m := openPort(t, tt.cfg)
+			defer m.Close()
+			defer p.Close()
+
+			tio := p.termios(t)
+			if tio.ospeed != tt.speed || tio.ispeed != tt.speed {
+				t.Errorf("speed = %d/%d, want %d", tio.ispeed, tio.ospeed, tt.speed)
+			}
+			if tio.lflag&(icanon|echo|isig) != 0 || tio.oflag&opost != 0 || tio.cc[vmin] != 1 || tio.cc[vtime] != 0 {
+				t.Errorf("not raw: %+v", tio)
+			}
+		})
+	}
+}
+
+func TestOpen_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"baud", Config{Baud: -1}},
+		{"data bits", Config{DataBits: 9}},
+		{"parity", Config{Parity: 'X'}},
+		{"stop bits", Config{StopBits: 3}},
+		{"read timeout", Config{ReadTimeout: -time.Second}},
+		{"vtime", Config{VTime: 26 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if p, err := Open("/dev/null", tt.cfg); err == nil {
+				p.Close()
+				t.Errorf("Open(%+v) succeeded", tt.cfg)
+			}
+		})
+	}
+	if _, err := Open("/dev/null", Config{}); err == nil {
+		t.Error("Open() of a non terminal succeeded")
+	}
+}
+
+func TestOpen_exclusive(t *testing.T) {
+	p, m := openPort(t, Config{Exclusive: true})
+	defer m.Close()
+	defer p.Close()
+
+	var excl int32
+	if err := p.ioctl(tiocgexcl, unsafe.Pointer(&excl)); err != nil {
+		t.Skipf("TIOCGEXCL: %v", err)
+	}
+	if excl == 0 {
+		t.Error("port not exclusive")
+	}
+	if os.Geteuid() == 0 {
+		return // root opens it anyway
+	}
+	if q, err := Open(p.Name(), Config{}); err == nil {
+		q.Close()
+		t.Error("second Open() succeeded")
+	}
+}
+
+func TestPort_Close_exclusive(t *testing.T) {
+	p, m := openPort(t, Config{Exclusive: true})
+	defer m.Close()
+
+	// a WaitLines given up on holds a copy of the descriptor
+	fd, err := syscall.Dup(int(p.f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fd)
+	p.waits = 1
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var excl int32
+	if err := ioctl(uintptr(fd), tiocgexcl, unsafe.Pointer(&excl)); err != nil {
+		t.Skipf("TIOCGEXCL: %v", err)
+	}
+	if excl != 0 {
+		t.Error("port still exclusive after Close")
+	}
+}
+
+func TestPort_ReadWrite(t *testing.T) {
+	p, m := openPort(t, Config{Baud: 115200, ReadTimeout: time.Second})
+	defer m.Close()
+	defer p.Close()
+
+	// raw: no CR/LF translation, no echo, ^C is a byte
+	msg := []byte("hello\r\n\x03\x00\xff")
+	if _, err := m.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 0, len(msg))
+	buf := make([]byte, 64)
+	for len(got) < len(msg) {
+		n, err := p.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("port read %q, want %q", got, msg)
+	}
+
+	if _, err := p.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	got = got[:0]
+	for len(got) < len(msg) {
+		n, err := m.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("master read %q, want %q", got, msg)
+	}
+}
+
+func TestPort_Flush(t *testing.T) {
+	p, m := openPort(t, Config{ReadTimeout: 50 * time.Millisecond})
+	defer m.Close()
+	defer p.Close()
+
+	m.Write([]byte("stale"))
+	time.Sleep(10 * time.Millisecond)
+	if in, _, err := p.Buffered(); err != nil || in != 5 {
+		t.Errorf("Buffered() = %d, %v, want 5", in, err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := p.Read(make([]byte, 8)); err != ErrTimeout {
+		t.Errorf("Read() after Flush() = %d, %v", n, err)
+	}
+}
+
+func TestPort_timeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		wait time.Duration
+	}{
+		{"read timeout", Config{ReadTimeout: 50 * time.Millisecond}, 50 * time.Millisecond},
+		{"vtime", Config{VTime: 100 * time.Millisecond}, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, m := openPort(t, tt.cfg)
+			defer m.Close()
+			defer p.Close()
+
+			start := time.Now()
+			n, err := p.Read(make([]byte, 8))
+			if n != 0 || err != ErrTimeout {
+				t.Errorf("Read() = %d, %v, want ErrTimeout", n, err)
+			}
+			if d := time.Since(start); d < tt.wait || d > tt.wait+time.Second {
+				t.Errorf("Read() returned after %v, want %v", d, tt.wait)
+			}
+
+			m.Write([]byte("ab"))
+			buf := make([]byte, 8)
+			if n, err = p.Read(buf); err != nil || string(buf[:n]) != "ab" {
+				t.Errorf("Read() = %q, %v", buf[:n], err)
+			}
+		})
+	}
+}
+
+func TestPort_vmin(t *testing.T) {
+	p, m := openPort(t, Config{VMin: 4, VTime: 2 * time.Second})
+	defer m.Close()
+	defer p.Close()
+
+	go func() {
+		m.Write([]byte("ab"))
+		time.Sleep(20 * time.Millisecond)
+		m.Write([]byte("cd"))
+	}()
+	buf := make([]byte, 8)
+	n, err := p.Read(buf)
+	if err != nil || string(buf[:n]) != "abcd" {
+		t.Errorf("Read() = %q, %v, want 4 bytes", buf[:n], err)
+	}
+	if err := p.SetReadDeadline(time.Now()); err == nil {
+		t.Error("SetReadDeadline() succeeded with VMIN")
+	}
+}
+
+func TestPort_deadline(t *testing.T) {
+	p, m := openPort(t, Config{})
+	defer m.Close()
+	defer p.Close()
+
+	p.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := p.Read(make([]byte, 8)); !os.IsTimeout(err) || err == ErrTimeout {
+		t.Errorf("Read() past the deadline: %v", err)
+	}
+
+	// Close wakes up a blocked Read
+	p.SetReadDeadline(time.Time{})
+	done := make(chan error)
+	go func() {
+		_, err := p.Read(make([]byte, 8))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Read() on a closed port succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Error("Close() did not wake up Read()")
+	}
+}
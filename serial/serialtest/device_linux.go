@@ -0,0 +1,133 @@
+package serialtest
+
+import (
+	"sync"
+	"time"
+)
+
+// Faults are injected by a Device between its model and the port.
+type Faults struct {
+	// Rx alters the bytes from the port to the model, Tx the bytes from
+	// the model to the port, nil for none.
+	Rx, Tx Fault
+	// Delay is the wait before each reply of the model.
+	Delay time.Duration
+}
+
+// Device is a Model sitting on the far side of a pseudo-terminal, open
+// Name with serial.Open to talk to it.
+type Device struct {
+	Name string
+
+	pty    *Pty
+	m      Model
+	faults Faults
+	done   chan struct{}
+
+	wmu sync.Mutex // serializes the writes to the port
+
+	mu       sync.Mutex
+	rx, tx   []byte
+	nrx, ntx int // bytes seen by the faults
+}
+
+// Attach returns a Device running m, f may be nil.
+// The device must be closed once it is no longer in use.
+func Attach(m Model, f *Faults) (*Device, error) {
+	p, err := NewPty()
+	if err != nil {
+		return nil, err
+	}
+	d := &Device{Name: p.Name, pty: p, m: m, done: make(chan struct{})}
+	if f != nil {
+		d.faults = *f
+	}
+	go d.run()
+	return d, nil
+}
+
+func (d *Device) run() {
+	defer close(d.done)
+	b := make([]byte, 256)
+	for {
+		n, err := d.pty.Master.Read(b)
+		if err != nil {
+			return
+		}
+		in := d.pass(b[:n], d.faults.Rx, &d.nrx, &d.rx)
+		if len(in) == 0 {
+			continue
+		}
+		reply := d.m.Receive(in)
+		if len(reply) == 0 {
+			continue
+		}
+		time.Sleep(d.faults.Delay)
+		if _, err := d.write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// pass applies fault to b, counting the bytes in *n, and records what
+// passed in *rec.
+func (d *Device) pass(b []byte, fault Fault, n *int, rec *[]byte) []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		ok := true
+		if fault != nil {
+			c, ok = fault(*n, c)
+		}
+		*n++
+		if ok {
+			out = append(out, c)
+		}
+	}
+	*rec = append(*rec, out...)
+	return out
+}
+
+func (d *Device) write(b []byte) (int, error) {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+
+	out := d.pass(b, d.faults.Tx, &d.ntx, &d.tx)
+	if len(out) == 0 {
+		return len(b), nil
+	}
+	if _, err := d.pty.Master.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Write sends b to the port unsolicited, through the Tx faults.
+func (d *Device) Write(b []byte) (int, error) {
+	return d.write(b)
+}
+
+// Received returns the bytes the model received, after the Rx faults.
+func (d *Device) Received() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]byte(nil), d.rx...)
+}
+
+// Sent returns the bytes sent to the port, after the Tx faults.
+func (d *Device) Sent() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]byte(nil), d.tx...)
+}
+
+// Close stops the device and closes the pseudo-terminal.
+func (d *Device) Close() error {
+	err := d.pty.Close()
+	<-d.done
+	return err
+}
@@ -0,0 +1,43 @@
+package serialtest_test
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/serial"
+	"github.com/flyingyizi/go-wiringPi/serial/serialtest"
+)
+
+// A modem answering AT commands, in place of a real UART.
+func ExampleAttach() {
+	modem := serialtest.NewScript(
+		serialtest.Step{Expect: []byte("AT\r"), Reply: []byte("OK\r\n")},
+		serialtest.Step{Expect: []byte("ATI\r"), Reply: []byte("go-modem 1.0\r\n")},
+	)
+	d, err := serialtest.Attach(modem, &serialtest.Faults{Delay: 10 * time.Millisecond})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer d.Close()
+
+	p, err := serial.Open(d.Name, serial.Config{Baud: 115200, ReadTimeout: time.Second})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer p.Close()
+
+	r := bufio.NewReader(p)
+	for _, cmd := range []string{"AT\r", "ATI\r"} {
+		p.Write([]byte(cmd))
+		line, _ := r.ReadString('\n')
+		fmt.Printf("%q\n", line)
+	}
+	fmt.Println(modem.Err())
+	// Output:
+	// "OK\r\n"
+	// "go-modem 1.0\r\n"
+	// <nil>
+}
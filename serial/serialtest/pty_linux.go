@@ -0,0 +1,145 @@
+package serialtest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Pty is a pseudo-terminal. Its slave side, Name, is the port under test,
+// opened with serial.Open; the test talks on the master side.
+//
+// The slave side is set to raw mode and held open until Close: the
+// bytes written to the master before the port is opened are not echoed,
+// and the master does not read EIO while the port is closed.
+type Pty struct {
+	Master *os.File
+	Name   string
+
+	slave *os.File
+}
+
+// NewPty returns a new pseudo-terminal.
+func NewPty() (*Pty, error) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	// not m.Fd(), the master would leave the poller and lose its
+	// deadlines
+	rc, err := m.SyscallConn()
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	var unlock int32
+	var n uint32
+	rc.Control(func(fd uintptr) {
+		if err = ioctl(fd, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err == nil {
+			err = ioctl(fd, syscall.TIOCGPTN, unsafe.Pointer(&n))
+		}
+	})
+	if err != nil {
+		m.Close()
+		return nil, &os.SyscallError{Syscall: "ioctl", Err: err}
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+	s, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	if err = makeRaw(s); err != nil {
+		s.Close()
+		m.Close()
+		return nil, err
+	}
+	return &Pty{Master: m, Name: name, slave: s}, nil
+}
+
+// Close closes both sides.
+func (p *Pty) Close() error {
+	err := p.Master.Close()
+	if serr := p.slave.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw sets f to raw mode, as cfmakeraw(3).
+func makeRaw(f *os.File) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rc.Control(func(fd uintptr) {
+		var t syscall.Termios
+		if err = ioctl(fd, syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+			return
+		}
+		t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+			syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+		t.Oflag &^= syscall.OPOST
+		t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+		t.Cflag &^= syscall.CSIZE | syscall.PARENB
+		t.Cflag |= syscall.CS8
+		t.Cc[syscall.VMIN], t.Cc[syscall.VTIME] = 1, 0
+		err = ioctl(fd, syscall.TCSETS, unsafe.Pointer(&t))
+	})
+	if err != nil {
+		return &os.SyscallError{Syscall: "ioctl", Err: err}
+	}
+	return nil
+}
+
+// Link is a virtual null-modem cable: what is written on the port A is
+// read on the port B, and the other way round.
+type Link struct {
+	A, B string
+
+	a, b *Pty
+	wg   sync.WaitGroup
+}
+
+// NewLink returns a new Link.
+func NewLink() (*Link, error) {
+	a, err := NewPty()
+	if err != nil {
+		return nil, err
+	}
+	b, err := NewPty()
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	l := &Link{A: a.Name, B: b.Name, a: a, b: b}
+	l.wg.Add(2)
+	go l.copy(b.Master, a.Master)
+	go l.copy(a.Master, b.Master)
+	return l, nil
+}
+
+func (l *Link) copy(dst io.Writer, src io.Reader) {
+	defer l.wg.Done()
+	io.Copy(dst, src)
+}
+
+// Close closes both ports of the link.
+func (l *Link) Close() error {
+	err := l.a.Close()
+	if berr := l.b.Close(); err == nil {
+		err = berr
+	}
+	l.wg.Wait()
+	return err
+}
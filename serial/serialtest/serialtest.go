@@ -0,0 +1,164 @@
+// Package serialtest provides pseudo-terminals to test the serial package,
+// and the protocols on top of it, without hardware. A device model written
+// in Go sits on the far side of a port opened with serial.Open:
+//
+//	d, err := serialtest.Attach(serialtest.Echo{}, nil)
+//	...
+//	p, err := serial.Open(d.Name, serial.Config{Baud: 115200})
+//
+// Faults drop, corrupt or delay the bytes on their way; a Link joins two
+// ports like a null-modem cable.
+package serialtest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Model is a device on the far side of a port. It is given the bytes
+// that came from the port, in pieces of any size, and returns the bytes
+// to send back, nil for none. A model framing requests keeps the partial
+// ones itself.
+type Model interface {
+	Receive(b []byte) []byte
+}
+
+// ModelFunc is a function used as a Model.
+type ModelFunc func(b []byte) []byte
+
+// Receive implements Model.
+func (f ModelFunc) Receive(b []byte) []byte { return f(b) }
+
+// Echo is a loopback plug: it sends back what it receives.
+type Echo struct{}
+
+// Receive implements Model.
+func (Echo) Receive(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+// Step is an exchange of a Script.
+type Step struct {
+	// Expect is the request.
+	Expect []byte
+	// Reply is sent back once the request came, nil for nothing.
+	Reply []byte
+	// Delay is the wait before the reply.
+	Delay time.Duration
+}
+
+// Script answers the requests it expects, in order. Once a request does
+// not match, it stops answering; Err tells what went wrong.
+type Script struct {
+	mu    sync.Mutex
+	steps []Step
+	buf   []byte
+	next  int
+	err   error
+}
+
+// NewScript returns a Script going through steps.
+func NewScript(steps ...Step) *Script {
+	return &Script{steps: steps}
+}
+
+// Receive implements Model.
+func (s *Script) Receive(b []byte) []byte {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.buf = append(s.buf, b...)
+	var reply []byte
+	var delay time.Duration
+	for s.err == nil && len(s.buf) > 0 {
+		if s.next == len(s.steps) {
+			s.err = fmt.Errorf("serialtest: % x after the last step", s.buf)
+			break
+		}
+		step := s.steps[s.next]
+		n := len(step.Expect)
+		if len(s.buf) < n {
+			if !bytes.HasPrefix(step.Expect, s.buf) {
+				s.err = fmt.Errorf("serialtest: step %d: got % x, want % x", s.next, s.buf, step.Expect)
+			}
+			break
+		}
+		if !bytes.Equal(s.buf[:n], step.Expect) {
+			s.err = fmt.Errorf("serialtest: step %d: got % x, want % x", s.next, s.buf[:n], step.Expect)
+			break
+		}
+		s.buf = s.buf[n:]
+		s.next++
+		reply = append(reply, step.Reply...)
+		delay += step.Delay
+	}
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+	return reply
+}
+
+// Err returns the first mismatch, or an error when steps are left.
+func (s *Script) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err == nil && s.next < len(s.steps) {
+		return fmt.Errorf("serialtest: step %d of %d not done, got % x", s.next, len(s.steps), s.buf)
+	}
+	return s.err
+}
+
+// Fault alters the bytes going one way, n counts the bytes from 0. It
+// returns the byte to pass on, and false to drop it.
+type Fault func(n int, c byte) (byte, bool)
+
+// Drop drops the bytes at the given counts.
+func Drop(at ...int) Fault {
+	return func(n int, c byte) (byte, bool) {
+		return c, !contains(at, n)
+	}
+}
+
+// Corrupt inverts the bits of the bytes at the given counts.
+func Corrupt(at ...int) Fault {
+	return func(n int, c byte) (byte, bool) {
+		if contains(at, n) {
+			c = ^c
+		}
+		return c, true
+	}
+}
+
+// Random drops bytes with the probability drop, and flips a bit of bytes
+// with the probability corrupt, reproducibly for a seed.
+func Random(seed int64, drop, corrupt float64) Fault {
+	var mu sync.Mutex
+	r := rand.New(rand.NewSource(seed))
+	return func(n int, c byte) (byte, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Float64() < drop {
+			return c, false
+		}
+		if r.Float64() < corrupt {
+			c ^= 1 << uint(r.Intn(8))
+		}
+		return c, true
+	}
+}
+
+func contains(s []int, n int) bool {
+	for _, v := range s {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package serialtest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/serial"
+)
+
+// open opens the port name, it must be closed.
+func open(t *testing.T, name string) *serial.Port {
+	p, err := serial.Open(name, serial.Config{Baud: 115200, ReadTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// attach attaches m, the device must be closed.
+func attach(t *testing.T, m Model, f *Faults) *Device {
+	d, err := Attach(m, f)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	return d
+}
+
+func readN(t *testing.T, p *serial.Port, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(p, b); err != nil {
+		t.Fatalf("read %d bytes: % x, %v", n, b, err)
+	}
+	return b
+}
+
+func TestDevice(t *testing.T) {
+	msg := []byte("hello\r\n")
+	tests := []struct {
+		name     string
+		faults   *Faults
+		want     []byte
+		received []byte
+	}{
+		{"echo", nil, msg, msg},
+		{"dropped to the port", &Faults{Tx: Drop(0, 6)}, []byte("ello\r"), msg},
+		{"corrupted to the port", &Faults{Tx: Corrupt(1)}, []byte("h\x9allo\r\n"), msg},
+		{"dropped from the port", &Faults{Rx: Drop(5)}, []byte("hello\n"), []byte("hello\n")},
+		{"delayed", &Faults{Delay: 50 * time.Millisecond}, msg, msg},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := attach(t, Echo{}, tt.faults)
+			defer d.Close()
+			p := open(t, d.Name)
+			defer p.Close()
+			start := time.Now()
+			if _, err := p.Write(msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := readN(t, p, len(tt.want)); !bytes.Equal(got, tt.want) {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+			if tt.faults != nil && time.Since(start) < tt.faults.Delay {
+				t.Errorf("reply after %v, want %v", time.Since(start), tt.faults.Delay)
+			}
+			if got := d.Received(); !bytes.Equal(got, tt.received) {
+				t.Errorf("Received() = %q, want %q", got, tt.received)
+			}
+			if got := d.Sent(); !bytes.Equal(got, tt.want) {
+				t.Errorf("Sent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDevice_script(t *testing.T) {
+	s := NewScript(
+		Step{Expect: []byte("AT\r"), Reply: []byte("OK\r\n")},
+		Step{Expect: []byte("AT+GMR\r"), Reply: []byte("1.2\r\nOK\r\n"), Delay: 20 * time.Millisecond},
+	)
+	d := attach(t, s, nil)
+	defer d.Close()
+	// bytes sent before the port is opened wait for it
+	d.Write([]byte("RDY\r\n"))
+	p := open(t, d.Name)
+	defer p.Close()
+
+	if got := readN(t, p, 5); string(got) != "RDY\r\n" {
+		t.Errorf("read %q before the script", got)
+	}
+	p.Write([]byte("AT\r"))
+	if got := readN(t, p, 4); string(got) != "OK\r\n" {
+		t.Errorf("read %q, want OK", got)
+	}
+	p.Write([]byte("AT+GMR\r"))
+	if got := readN(t, p, 9); string(got) != "1.2\r\nOK\r\n" {
+		t.Errorf("read %q", got)
+	}
+	if err := s.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLink(t *testing.T) {
+	l, err := NewLink()
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	defer l.Close()
+	a := open(t, l.A)
+	defer a.Close()
+	b := open(t, l.B)
+	defer b.Close()
+
+	a.Write([]byte("ping"))
+	if got := readN(t, b, 4); string(got) != "ping" {
+		t.Errorf("B read %q", got)
+	}
+	b.Write([]byte("pong"))
+	if got := readN(t, a, 4); string(got) != "pong" {
+		t.Errorf("A read %q", got)
+	}
+}
@@ -0,0 +1,82 @@
+package serialtest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	s := NewScript(
+		Step{Expect: []byte("AT\r"), Reply: []byte("OK\r\n")},
+		Step{Expect: []byte("ATI\r"), Reply: []byte("modem\r\n")},
+		Step{Expect: []byte("ATZ\r")},
+	)
+	tests := []struct {
+		in, want string
+	}{
+		{"A", ""},
+		{"T\rAT", "OK\r\n"},
+		{"I\rATZ\r", "modem\r\n"},
+	}
+	for _, tt := range tests {
+		if got := s.Receive([]byte(tt.in)); string(got) != tt.want {
+			t.Errorf("Receive(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Error(err)
+	}
+	if got := s.Receive([]byte("x")); got != nil || s.Err() == nil {
+		t.Errorf("Receive after the last step = %q, Err() = %v", got, s.Err())
+	}
+
+	s = NewScript(Step{Expect: []byte("AT\r"), Reply: []byte("OK\r\n")}, Step{Expect: []byte("ATI\r")})
+	if got := s.Receive([]byte("AX")); got != nil || s.Err() == nil {
+		t.Errorf("Receive of a mismatch = %q, Err() = %v", got, s.Err())
+	}
+	s = NewScript(Step{Expect: []byte("AT\r")})
+	if s.Err() == nil {
+		t.Error("Err() with a step left = nil")
+	}
+}
+
+// run passes b through f.
+func run(f Fault, b []byte) []byte {
+	var out []byte
+	for i, c := range b {
+		if c, ok := f(i, c); ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func TestFault(t *testing.T) {
+	in := []byte{0x00, 0x11, 0x22, 0x33}
+	tests := []struct {
+		name string
+		f    Fault
+		want []byte
+	}{
+		{"drop", Drop(1, 3), []byte{0x00, 0x22}},
+		{"corrupt", Corrupt(0), []byte{0xff, 0x11, 0x22, 0x33}},
+		{"random none", Random(1, 0, 0), in},
+		{"random all dropped", Random(1, 1, 0), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.f, in); !bytes.Equal(got, tt.want) {
+				t.Errorf("got % x, want % x", got, tt.want)
+			}
+		})
+	}
+
+	big := bytes.Repeat([]byte{0x55}, 1000)
+	a, b := run(Random(7, 0.1, 0.1), big), run(Random(7, 0.1, 0.1), big)
+	if !bytes.Equal(a, b) {
+		t.Error("Random with one seed is not reproducible")
+	}
+	if len(a) < 850 || len(a) > 950 {
+		t.Errorf("Random(0.1) kept %d bytes of 1000", len(a))
+	}
+}
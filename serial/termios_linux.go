@@ -0,0 +1,194 @@
+package serial
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// termios constants and ioctl numbers of asm-generic/termbits.h and
+// asm-generic/ioctls.h, used by arm, arm64 and x86. The syscall package
+// lacks most of them for arm.
+const (
+	tcgets2    = 0x802c542a // _IOR('T', 0x2A, struct termios2)
+	tcsets2    = 0x402c542b // _IOW('T', 0x2B, struct termios2)
+	tcsbrk     = 0x5409
+	tcflsh     = 0x540b
+	tiocexcl   = 0x540c
+	tiocnxcl   = 0x540d
+	tiocgexcl  = 0x80045440 // _IOR('T', 0x40, int)
+	tiocoutq   = 0x5411
+	tiocmget   = 0x5415
+	tiocmbis   = 0x5416
+	tiocmbic   = 0x5417
+	tiocinq    = 0x541b
+	tiocsbrk   = 0x5427
+	tioccbrk   = 0x5428
+	tiocsrs485 = 0x542f
+	tiocmiwait = 0x545c
+
+	tciflush  = 0
+	tcioflush = 2
+)
+
+// c_iflag
+const (
+	ignbrk = 0000001
+	brkint = 0000002
+	parmrk = 0000010
+	inpck  = 0000020
+	istrip = 0000040
+	inlcr  = 0000100
+	igncr  = 0000200
+	icrnl  = 0000400
+	ixon   = 0002000
+	ixany  = 0004000
+	ixoff  = 0010000
+)
+
+// c_oflag
+const opost = 0000001
+
+// c_cflag
+const (
+	cbaud   = 0010017
+	bother  = 0010000
+	csize   = 0000060
+	cs5     = 0000000
+	cs6     = 0000020
+	cs7     = 0000040
+	cs8     = 0000060
+	cstopb  = 0000100
+	cread   = 0000200
+	parenb  = 0000400
+	parodd  = 0001000
+	hupcl   = 0002000
+	clocal  = 0004000
+	cibaud  = 002003600000
+	cmspar  = 010000000000
+	crtscts = 020000000000
+)
+
+// c_lflag
+const (
+	isig   = 0000001
+	icanon = 0000002
+	echo   = 0000010
+	echonl = 0000100
+	iexten = 0100000
+)
+
+// c_cc
+const (
+	vtime  = 5
+	vmin   = 6
+	vstart = 8
+	vstop  = 9
+	nccs   = 19
+)
+
+// termios2 is struct termios2, the termios with the speeds as integers.
+type termios2 struct {
+	iflag, oflag, cflag, lflag uint32
+	line                       uint8
+	cc                         [nccs]uint8
+	ispeed, ospeed             uint32
+}
+
+// bauds are the rates with a Bxxx code, the others use BOTHER.
+var bauds = map[int]uint32{
+	50: 0000001, 75: 0000002, 110: 0000003, 134: 0000004, 150: 0000005,
+	200: 0000006, 300: 0000007, 600: 0000010, 1200: 0000011, 1800: 0000012,
+	2400: 0000013, 4800: 0000014, 9600: 0000015, 19200: 0000016, 38400: 0000017,
+	57600: 0010001, 115200: 0010002, 230400: 0010003, 460800: 0010004,
+	500000: 0010005, 576000: 0010006, 921600: 0010007, 1000000: 0010010,
+	1152000: 0010011, 1500000: 0010012, 2000000: 0010013, 2500000: 0010014,
+	3000000: 0010015, 3500000: 0010016, 4000000: 0010017,
+}
+
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ioctlInt is ioctl for the requests taking an integer argument.
+func ioctlInt(fd, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw sets t up as cfmakeraw does, with the receiver on and the modem
+// lines ignored.
+func (t *termios2) makeRaw() {
+	t.iflag &^= ignbrk | brkint | parmrk | istrip | inlcr | igncr | icrnl | ixon | ixoff | ixany
+	t.oflag &^= opost
+	t.lflag &^= echo | echonl | icanon | isig | iexten
+	t.cflag &^= csize | parenb | parodd | cmspar | cstopb | crtscts | hupcl
+	t.cflag |= cs8 | cread | clocal
+}
+
+// setBaud sets both speeds to baud.
+func (t *termios2) setBaud(baud int) {
+	code, ok := bauds[baud]
+	if !ok {
+		code = bother
+	}
+	// CIBAUD left to 0: the input speed follows the output one
+	t.cflag &^= cbaud | cibaud
+	t.cflag |= code
+	t.ispeed = uint32(baud)
+	t.ospeed = uint32(baud)
+}
+
+// setFrame sets the data bits, the parity and the stop bits.
+func (t *termios2) setFrame(dataBits int, parity Parity, stopBits int) {
+	t.cflag &^= csize | parenb | parodd | cmspar | cstopb
+	t.iflag &^= inpck
+	t.cflag |= [...]uint32{cs5, cs6, cs7, cs8}[dataBits-5]
+	switch parity {
+	case ParityOdd:
+		t.cflag |= parenb | parodd
+	case ParityEven:
+		t.cflag |= parenb
+	case ParityMark:
+		t.cflag |= parenb | cmspar | parodd
+	case ParitySpace:
+		t.cflag |= parenb | cmspar
+	}
+	if t.cflag&parenb != 0 {
+		t.iflag |= inpck
+	}
+	if stopBits == 2 {
+		t.cflag |= cstopb
+	}
+}
+
+// setFlow sets the flow control.
+func (t *termios2) setFlow(flow FlowControl) {
+	t.cflag &^= crtscts
+	t.iflag &^= ixon | ixoff | ixany
+	switch flow {
+	case FlowHardware:
+		t.cflag |= crtscts
+	case FlowSoftware:
+		t.iflag |= ixon | ixoff
+		t.cc[vstart] = 0x11
+		t.cc[vstop] = 0x13
+	}
+}
+
+// setTimeouts sets VMIN and VTIME, VTIME is in tenths of a second.
+func (t *termios2) setTimeouts(min uint8, timeout time.Duration) error {
+	ds := (timeout + 100*time.Millisecond - 1) / (100 * time.Millisecond)
+	if ds < 0 || ds > 255 {
+		return fmt.Errorf("serial: VTime %v out of range", timeout)
+	}
+	t.cc[vmin] = min
+	t.cc[vtime] = uint8(ds)
+	return nil
+}
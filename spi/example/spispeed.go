@@ -1,105 +1,82 @@
+// spispeed measures the SPI throughput, like the wiringPi spiSpeed
+// example. It runs the controller in loopback mode so no chip is needed;
+// on controllers without loopback support (e.g. bcm2835), run it with
+// -loop=false and MOSI wired to MISO.
 package main
 
-_ import "github.com/flyingyizi/go-wiringPi/spi"
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
 
+	"github.com/flyingyizi/go-wiringPi/spi"
+)
 
 const (
-SPI_CHAN		=0
-NUM_TIMES		=100
-MAX_SIZE		=(1024*1024)
-)	
+	numTimes = 100
+	maxSize  = 1024 * 1024
+)
 
-/*
 func main() {
-	d, err := i2c.Open(0x39)
-	if err != nil {
-		panic(err)
+	bus := flag.Int("bus", 0, "SPI bus")
+	cs := flag.Int("cs", 0, "chip select")
+	loop := flag.Bool("loop", true, "use the controller loopback mode")
+	maxMHz := flag.Int("max", 32, "highest speed tested, in MHz")
+	flag.Parse()
+
+	mode := spi.Mode0
+	if *loop {
+		mode |= spi.Loop
 	}
-
-	// opens a 10-bit address
-	d, err = i2c.Open(i2c.TenBit(0x78))
-
-	if err != nil {
-		panic(err)
+	tx := make([]byte, maxSize)
+	rand.Read(tx)
+	rx := make([]byte, maxSize)
+
+	for speed := 1; speed <= *maxMHz; speed *= 2 {
+		d, err := spi.Open(*bus, *cs, spi.Config{Mode: mode, MaxSpeedHz: uint32(speed) * 1000000})
+		if err != nil {
+			log.Fatalf("can't open the SPI bus: %v", err)
+		}
+		if cfg, err := d.Config(); err == nil {
+			fmt.Printf("mode %v, %d Hz, %d bits per word\n", cfg.Mode, cfg.MaxSpeedHz, cfg.BitsPerWord)
+		}
+		fmt.Println("+-------+--------+----------+----------+-----------+------------+")
+		fmt.Println("|   MHz |   Size | mS/Trans |      TpS |    Mb/Sec | Latency mS |")
+		fmt.Println("+-------+--------+----------+----------+-----------+------------+")
+
+		for size := 1; size <= maxSize; size *= 2 {
+			fmt.Printf("| %5d | %6d ", speed, size)
+
+			start := time.Now()
+			for times := 0; times < numTimes; times++ {
+				if err = d.Transfer(spi.Segment{Tx: tx[:size], Rx: rx[:size]}); err != nil {
+					break
+				}
+			}
+			if err != nil {
+				fmt.Printf("SPI failure: %v\n", err)
+				break
+			}
+			if !bytes.Equal(tx[:size], rx[:size]) {
+				fmt.Println("data read back differs, is MOSI looped to MISO?")
+				break
+			}
+			timePerTransaction := time.Since(start).Seconds() / numTimes
+			dataSpeed := float64(size*8) / (1024 * 1024) / timePerTransaction
+			perfectTimePerTransaction := float64(size*8) / float64(speed*1000000)
+
+			fmt.Printf("| %8.3f ", timePerTransaction*1000)
+			fmt.Printf("| %8.1f ", 1/timePerTransaction)
+			fmt.Printf("| %9.5f ", dataSpeed)
+			fmt.Printf("|   %8.5f ", (timePerTransaction-perfectTimePerTransaction)*1000)
+			fmt.Println("|")
+		}
+
+		d.Close()
+		fmt.Println("+-------+--------+----------+----------+-----------+------------+")
+		fmt.Println()
 	}
-
-	d.Close()
-
-	_ = d
-}
-
-
-
-
-
-void spiSetup (int speed)
-{
-  if ((myFd = wiringPiSPISetup (SPI_CHAN, speed)) < 0)
-  {
-    fprintf (stderr, "Can't open the SPI bus: %s\n", strerror (errno)) ;
-    exit (EXIT_FAILURE) ;
-  }
-}
-
-
-int main (void)
-{
-  int speed, times, size ;
-  unsigned int start, end ;
-  int spiFail ;
-  unsigned char *myData ;
-  double timePerTransaction, perfectTimePerTransaction, dataSpeed ;
-
-  if ((myData = malloc (MAX_SIZE)) == NULL)
-  {
-    fprintf (stderr, "Unable to allocate buffer: %s\n", strerror (errno)) ;
-    exit (EXIT_FAILURE) ;
-  }
-
-  wiringPiSetup () ;
-
-  for (speed = 1 ; speed <= 32 ; speed *= 2)
-  {
-    printf ("+-------+--------+----------+----------+-----------+------------+\n") ;
-    printf ("|   MHz |   Size | mS/Trans |      TpS |    Mb/Sec | Latency mS |\n") ;
-    printf ("+-------+--------+----------+----------+-----------+------------+\n") ;
-
-    spiFail = FALSE ;
-    spiSetup (speed * 1000000) ;
-    for (size = 1 ; size <= MAX_SIZE ; size *= 2)
-    {
-      printf ("| %5d | %6d ", speed, size) ;
-
-      start = millis () ;
-      for (times = 0 ; times < NUM_TIMES ; ++times)
-	if (wiringPiSPIDataRW (SPI_CHAN, myData, size) == -1)
-	{
-	  printf ("SPI failure: %s\n", strerror (errno)) ;
-	  spiFail = TRUE ;
-	  break ;
-	}
-      end = millis () ;
-
-      if (spiFail)
-	break ;
-
-      timePerTransaction        = ((double)(end - start) / (double)NUM_TIMES) / 1000.0 ;
-      dataSpeed                 =  (double)(size * 8)    / (1024.0 * 1024.0) / timePerTransaction  ;
-      perfectTimePerTransaction = ((double)(size * 8))   / ((double)(speed * 1000000)) ;
-
-      printf ("| %8.3f ", timePerTransaction * 1000.0) ;
-      printf ("| %8.1f ", 1.0 / timePerTransaction) ;
-      printf ("| %9.5f ", dataSpeed) ;
-      printf ("|   %8.5f ", (timePerTransaction - perfectTimePerTransaction) * 1000.0) ;
-      printf ("|\n") ;
-
-    }
-
-    close (myFd) ;
-    printf ("+-------+--------+----------+----------+-----------+------------+\n") ;
-    printf ("\n") ;
-  }
-
-  return 0 ;
 }
-*/
\ No newline at end of file
@@ -1,51 +1,18 @@
 package spi
 
 import (
-	"errors"
-	"fmt"
 	"os"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
-/*
-#include <sys/ioctl.h>
-#include <asm/ioctl.h>
-#include <linux/spi/spidev.h>
-*/
-import "C"
-
-//Mode means wiringPi modes
-type Mode int
-
 const (
-	SpiIOCWRMode        = C.SPI_IOC_WR_MODE
-	spiIOCWRMaxSpeedHz  = C.SPI_IOC_WR_MAX_SPEED_HZ
-	spiIOCWRBitsPerWord = C.SPI_IOC_WR_BITS_PER_WORD
-	defaultDelayms      = 0
-	defaultSPIBPW       = 8
-	defaultSPISpeed     = 1000000
-
-	spiIOCMessage0    = 1073769216 //0x40006B00
-	spiIOCIncrementor = 2097152    //0x200000
-
-)
-
-/*
-const (
-	spiIOCWrMode        = 0x40016B01
-	spiIOCWrBitsPerWord = 0x40016B03
-	spiIOCWrMaxSpeedHz  = 0x40046B04
-
-	spiIOCRdBitsPerWord = 0x80016B03
-	spiIOCRdMaxSpeedHz  = 0x80046B04
-	spiIOCMessage0    = 1073769216 //0x40006B00
-	spiIOCIncrementor = 2097152    //0x200000
-
-
+	SpiIOCWRMode    = spiIOCWRMode // kept for compatibility
+	defaultSPIBPW   = 8
+	defaultSPISpeed = 1000000
 )
-*/
 
 // The SPI bus parameters
 //	Variables as they need to be passed as pointers later on
@@ -55,56 +22,29 @@ const (
 	spiDev1 string = "/dev/spidev0.1"
 )
 
-/*
- * wiringPiSPISetup:
- *	Open the SPI device, and set it up, etc. in the default MODE 0
- *********************************************************************************
- */
-func spiOpen(channel byte, model uint8, speed uint32, bpw uint8) (f *os.File, err error) {
-
-	model &= 3   // Mode is 0, 1, 2 or 3
-	channel &= 1 // Channel is 0 or 1
-	var device string
-	if channel == 0 {
-		device = spiDev0
-	} else {
-		device = spiDev1
-	}
-
+// spiOpenFile opens the spidev device and sets it up with cfg, which must
+// have been checked.
+func spiOpenFile(device string, cfg *Config) (f *os.File, err error) {
 	f, err = os.OpenFile(device, os.O_RDWR, os.ModeDevice)
 	if err != nil {
 		return nil, err //"Unable to open SPI device: %s\n"
 	}
-	//glog.V(3).Infof("spi: sucessfully opened file /dev/spidev0.%v", channel)
 
-	if err = spiSetMode(f, model); err != nil {
+	if err = spiSetMode(f, cfg.Mode); err != nil {
 		f.Close()
 		return nil, err
 	}
-	if _, err = spiSetSpeed(f, speed); err != nil {
+	if cfg.MaxSpeedHz, err = spiSetSpeed(f, cfg.MaxSpeedHz); err != nil {
 		f.Close()
 		return nil, err
 	}
-	if _, err = spiSetBPW(f, bpw); err != nil {
+	if cfg.BitsPerWord, err = spiSetBPW(f, cfg.BitsPerWord); err != nil {
 		f.Close()
 		return nil, err
 	}
-
 	return
 }
 
-func spiSetMode(f *os.File, mode uint8) error {
-	fmt.Printf("spi: setting spi mode to %v", mode)
-
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), SpiIOCWRMode, uintptr(unsafe.Pointer(&mode)))
-	if errno != 0 {
-		err := syscall.Errno(errno)
-		return err
-	}
-	fmt.Printf("spi: mode set to %v", mode)
-	return nil
-}
-
 func spiSetSpeed(f *os.File, speed uint32) (speedHz uint32, err error) {
 	if speed <= 0 {
 		speed = defaultSPISpeed
@@ -139,147 +79,82 @@ func spiSetBPW(f *os.File, bpw uint8) (bitsPerWord uint8, err error) {
 	return
 }
 
-func spiIOCMessageN(n uint32) uint32 {
-	return (spiIOCMessage0 + (n * spiIOCIncrementor))
-	//i := uint32(C.SPI_IOC_MESSAGE(C.__u32(n)))
-	//return uint32(i)
-}
-
-/*
- * spiTx:
- *	Write and Read a block of data over the SPI bus.
- *	Note the data ia being read into the transmit buffer, so will
- *	overwrite it!
- *	This is also a full-duplex operation.
- *********************************************************************************
- */
-//https://github.com/kidoman/embd/blob/master/host/generic/spibus.go
-func spiTx(f *os.File, dataBuffer []uint8, speed uint32, bpw uint8, delay uint16) error {
-	/*
-		defaultDelayms      = 0
-		defaultSPIBPW       = 8
-		defaultSPISpeed     = 1000000
-
-	*/
-
-	if f == nil {
-		return errors.New("bad")
-	}
-
-	len := len(dataBuffer)
-
-	// struct  spi_ioc_transfer
-
-	var dataCarrier C.struct_spi_ioc_transfer //= C.struct_spi_ioc_transfer{id, 21}
-
-	dataCarrier.len = C.__u32(len)
-	dataCarrier.tx_buf = C.__u64(uintptr(unsafe.Pointer(&dataBuffer[0])))
-	dataCarrier.rx_buf = C.__u64(uintptr(unsafe.Pointer(&dataBuffer[0])))
-	dataCarrier.speed_hz = C.__u32(speed)
-	dataCarrier.bits_per_word = C.__u8(bpw)
-	dataCarrier.delay_usecs = C.__u16(delay)
-	//spi.tx_buf        = (unsigned long)data ;
-	//spi.rx_buf        = (unsigned long)data ;
-	//spi.len           = len ;
-	//spi.delay_usecs   = spiDelay ;
-	//spi.speed_hz      = spiSpeeds [channel] ;
-	//spi.bits_per_word = spiBPW ;
-
-	//glog.V(3).Infof("spi: sending dataBuffer %v with carrier %v", dataBuffer, dataCarrier)
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(spiIOCMessageN(1)), uintptr(unsafe.Pointer(&dataCarrier)))
-	if errno != 0 {
-		err := syscall.Errno(errno)
-		//glog.V(3).Infof("spi: failed to read due to %v", err.Error())
-		return err
-	}
-	//glog.V(3).Infof("spi: read into dataBuffer %v", dataBuffer)
-	return nil
-
-	// Mentioned in spidev.h but not used in the original kernel documentation
-	//	test program )-:
-
-	//memset (&spi, 0, sizeof (spi)) ;
-	//
-	//spi.tx_buf        = (unsigned long)data ;
-	//spi.rx_buf        = (unsigned long)data ;
-	//spi.len           = len ;
-	//spi.delay_usecs   = spiDelay ;
-	//spi.speed_hz      = spiSpeeds [channel] ;
-	//spi.bits_per_word = spiBPW ;
-	/*
-	   每个 spi_ioc_transfer都可以包含读和写的请求，其中读和写的长度必须相等。所以成员len不是
-	   tx_buf和rx_buf缓冲的长度之和，而是它们各自的长度。SPI控制器驱动会先将tx_buf写到SPI总线上，
-	   然后再读取len长度的内容到rx_buf。如果只想进行一个方向的传输，把另一个方向的缓冲置为0就可以了。
-	   speed_hz和bits_per_word这两个成员可以为每次通信配置不同的通信速率（必须小于spi_device
-	   的max_speed_hz）和字长，如果它们为0的话就会使用spi_device中的配置。
-	   delay_usecs可以指定两个spi_ioc_transfer之间的延时，单位是微妙。一般不用定义。
-	   cs_change指定这个cs_change结束之后是否需要改变片选线。一般针对同一设备的连续的几个
-	   spi_ioc_transfer，只有最后一个需要将这个成员置位。这样省去了来回改变片选线的时间，有助于提高通信速率。
-	   struct spi_ioc_transfer {
-	   __u64 tx_buf; // 写数据缓冲
-	   __u64 rx_buf; // 读数据缓冲
-	   __u32 len; // 缓冲的长度
-	   __u32 speed_hz; // 通信的时钟频率
-	   __u16 delay_usecs; // 两个spi_ioc_transfer之间的延时
-	   __u8 bits_per_word; // 字长（比特数）
-	   __u8 cs_change; // 是否改变片选
-	   __u32 pad;
-	   };
-	*/
-	/*
-	   type spiIOCTransfer struct {
-	   	txBuf uint64
-	   	rxBuf uint64
-	   	length      uint32
-	   	speedHz     uint32
-	   	delayus     uint16
-	   	bitsPerWord uint8
-	   	csChange    uint8
-	   	pad         uint32
-	   }
-	*/
-
-}
-
-// Device represents an active connection to an I2C device.
+// Device represents an active connection to a SPI device.
 type Device struct {
 	f *os.File
-
-	// struct  spi_ioc_transfer
-	spiTransferData C.struct_spi_ioc_transfer //= C.struct_spi_ioc_transfer{id, 21}
+	// conn is the transport when the device was made by NewDevice, nil
+	// for spidev
+	conn Conn
+	// name is the device node, e.g. "/dev/spidev0.0"
+	name string
+	bus  int
+	cs   int
 
 	channel byte
-	mode    byte
-	speed   uint32
-	bpw     uint8
-	delayms uint16
+	cfg     Config
+	// bufsiz is the spidev buffer size, 0 for no limit
+	bufsiz int
 
 	mu sync.Mutex
 }
 
-func (d *Device) Open(channel byte, model uint8, speed uint32, bpw uint8, delay uint16) error {
-	f, err := spiOpen(channel, model, speed, bpw)
+func (d *Device) open(name string, cfg Config) error {
+	if err := cfg.check(); err != nil {
+		return err
+	}
+	f, err := spiOpenFile(name, &cfg)
 	if err != nil {
 		return err
 	}
 	d.f = f
-	d.channel = channel
-	d.mode = model
-	d.speed = speed
-	d.delayms = delay
+	d.name = name
+	d.cfg = cfg
+	d.bufsiz = spidevBufsiz()
+	d.logf("spi: opened %s: %v, %d Hz, %d bits per word", name, cfg.Mode, cfg.MaxSpeedHz, cfg.BitsPerWord)
 	return nil
+}
+
+/*
+ * wiringPiSPISetup:
+ *	Open the SPI device, and set it up, etc. in the default MODE 0
+ *********************************************************************************
+ */
 
+// Open opens /dev/spidev0.<channel>, channel is 0 or 1, delay is in
+// microseconds. It is kept for compatibility, the Open function reaches
+// any bus and chip select and takes a Config.
+func (d *Device) Open(channel byte, model uint8, speed uint32, bpw uint8, delay uint16) error {
+	model &= 3   // Mode is 0, 1, 2 or 3
+	channel &= 1 // Channel is 0 or 1
+	var device string
+	if channel == 0 {
+		device = spiDev0
+	} else {
+		device = spiDev1
+	}
+
+	d.cs = int(channel)
+	d.channel = channel
+	return d.open(device, Config{
+		Mode:        Mode(model),
+		MaxSpeedHz:  speed,
+		BitsPerWord: bpw,
+		Delay:       time.Duration(delay) * time.Microsecond,
+	})
 }
 
 // Tx : TransferAndReceiveData, fistly sending databuffer, then read into data buffer
+// This is a full-duplex operation, the data read overwrites dataBuffer.
 func (d *Device) Tx(dataBuffer []uint8) (err error) {
-
-	err = spiTx(d.f, dataBuffer, d.speed, d.bpw, d.delayms)
-	return
+	return d.Transfer(Segment{Tx: dataBuffer, Rx: dataBuffer})
 }
 
+// Write sends data in one message, of any size.
 func (d *Device) Write(data []byte) (n int, err error) {
-	return d.f.Write(data)
+	if err = d.Transfer(Segment{Tx: data}); err != nil {
+		return 0, err
+	}
+	return len(data), nil
 }
 
 func (d *Device) ReceiveData(len int) ([]uint8, error) {
@@ -313,5 +188,8 @@ func (d *Device) Close() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	if d.conn != nil {
+		return d.conn.Close()
+	}
 	return d.f.Close()
 }
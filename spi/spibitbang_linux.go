@@ -0,0 +1,286 @@
+package spi
+
+//A software master on GPIO lines, for devices wired to pins without a
+//hardware SPI controller. It supports the four SPI modes, LSB first,
+//active high chip select and any word size up to 32 bits. Words are
+//packed in memory as spidev does: up to 8 bits in a byte, up to 16 bits
+//in 2 bytes and up to 32 bits in 4 bytes, little endian.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/gpio"
+)
+
+// BitBangConfig holds the settings of a bit-banged master.
+type BitBangConfig struct {
+	// Mode is the SPI mode, with the flags LSBFirst, CSHigh, NoCS or Loop.
+	Mode Mode
+	// SpeedHz is the clock rate used for segments without a speed, 0
+	// means 100kHz.
+	SpeedHz uint32
+}
+
+const (
+	defaultBitBangSpeed = 100000
+	bitBangModes        = Mode3 | LSBFirst | CSHigh | NoCS | Loop
+)
+
+type bitBang struct {
+	mu sync.Mutex
+
+	sclk, mosi, miso, cs gpio.Line
+	mode                 Mode
+	speed                uint32
+	selected             bool
+}
+
+// NewBitBang returns a Conn driving the lines in software, *gpio.Pin lines
+// for instance. mosi or miso can be nil for a device that is only written
+// or only read, and cs can be nil with the NoCS mode flag. Wrap it with
+// NewDevice to get the Device API.
+func NewBitBang(sclk, mosi, miso, cs gpio.Line, cfg BitBangConfig) (Conn, error) {
+	if sclk == nil {
+		return nil, errors.New("spi: bit-banged master needs a clock pin")
+	}
+	if cfg.Mode&^bitBangModes != 0 {
+		return nil, fmt.Errorf("spi: mode %v not supported by the bit-banged master", cfg.Mode)
+	}
+	if cs == nil && cfg.Mode&NoCS == 0 {
+		return nil, errors.New("spi: bit-banged master needs a chip select pin or NoCS")
+	}
+	if cfg.SpeedHz == 0 {
+		cfg.SpeedHz = defaultBitBangSpeed
+	}
+	b := &bitBang{sclk: sclk, mosi: mosi, miso: miso, cs: cs, mode: cfg.Mode, speed: cfg.SpeedHz}
+
+	// the levels are set before the lines drive, a clock edge or a select
+	// the device could take for the start of a transfer
+	if err := b.set(sclk, b.mode&CPOL != 0); err != nil {
+		return nil, err
+	}
+	sclk.Output()
+	if mosi != nil {
+		if err := mosi.Low(); err != nil {
+			return nil, err
+		}
+		mosi.Output()
+	}
+	if miso != nil {
+		miso.Input()
+	}
+	if cs != nil {
+		if err := b.selectCS(false); err != nil {
+			return nil, err
+		}
+		cs.Output()
+	}
+	return b, nil
+}
+
+// settings tells NewDevice the mode and clock rate.
+func (b *bitBang) settings() (Mode, uint32) {
+	return b.mode, b.speed
+}
+
+func (b *bitBang) set(p gpio.Line, high bool) error {
+	if high {
+		return p.High()
+	}
+	return p.Low()
+}
+
+func (b *bitBang) selectCS(on bool) error {
+	b.selected = on
+	if b.mode&NoCS != 0 || b.cs == nil {
+		return nil
+	}
+	return b.set(b.cs, on == (b.mode&CSHigh != 0))
+}
+
+func delay(d time.Duration) {
+	// time.Sleep is far too coarse for microsecond delays
+	for start := time.Now(); time.Since(start) < d; {
+	}
+}
+
+// wordBytes returns the bytes a word of bpw bits takes in memory.
+func wordBytes(bpw uint8) int {
+	switch {
+	case bpw <= 8:
+		return 1
+	case bpw <= 16:
+		return 2
+	}
+	return 4
+}
+
+func getWord(p []byte, n int) (w uint32) {
+	for i := n - 1; i >= 0; i-- {
+		w = w<<8 | uint32(p[i])
+	}
+	return
+}
+
+func putWord(p []byte, n int, w uint32) {
+	for i := 0; i < n; i++ {
+		p[i] = byte(w >> (8 * uint(i)))
+	}
+}
+
+// Transfer implements Conn.
+func (b *bitBang) Transfer(segments ...Segment) (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	defer func() {
+		if err != nil && b.selected {
+			b.selectCS(false)
+		}
+	}()
+	for i := range segments {
+		s := &segments[i]
+		n, err := s.len()
+		if err != nil {
+			return err
+		}
+		bpw := s.BitsPerWord
+		if bpw == 0 {
+			bpw = defaultSPIBPW
+		}
+		if bpw > 32 {
+			return fmt.Errorf("spi: invalid bits per word %d", bpw)
+		}
+		wb := wordBytes(bpw)
+		if n%wb != 0 {
+			return fmt.Errorf("spi: %d bytes is not a whole number of %d-bit words", n, bpw)
+		}
+		speed := s.SpeedHz
+		if speed == 0 {
+			speed = b.speed
+		}
+		half := time.Second / (2 * time.Duration(speed))
+
+		if !b.selected {
+			if err = b.selectCS(true); err != nil {
+				return err
+			}
+			delay(half)
+		}
+		for off := 0; off < n; off += wb {
+			var w uint32
+			if s.Tx != nil {
+				w = getWord(s.Tx[off:], wb)
+			}
+			r, err := b.word(w, bpw, half)
+			if err != nil {
+				return err
+			}
+			if s.Rx != nil {
+				putWord(s.Rx[off:], wb, r)
+			}
+			if s.WordDelay > 0 && off+wb < n {
+				delay(s.WordDelay)
+			}
+		}
+		delay(s.Delay)
+
+		// cs_change releases the chip select between segments, and
+		// keeps it after the last one
+		if s.CSChange != (i == len(segments)-1) {
+			if err = b.selectCS(false); err != nil {
+				return err
+			}
+			delay(half)
+		}
+	}
+	return nil
+}
+
+// word shifts out the bpw low bits of w and returns the bits shifted in.
+func (b *bitBang) word(w uint32, bpw uint8, half time.Duration) (r uint32, err error) {
+	idle := b.mode&CPOL != 0
+	for i := uint(0); i < uint(bpw); i++ {
+		bit := uint(bpw) - 1 - i
+		if b.mode&LSBFirst != 0 {
+			bit = i
+		}
+		out := w>>bit&1 == 1
+		var in bool
+
+		if b.mode&CPHA == 0 {
+			// data is sampled on the leading edge, changed on the trailing one
+			if err = b.setMOSI(out); err != nil {
+				return
+			}
+			delay(half)
+			if err = b.set(b.sclk, !idle); err != nil {
+				return
+			}
+			if in, err = b.readMISO(out); err != nil {
+				return
+			}
+			delay(half)
+			if err = b.set(b.sclk, idle); err != nil {
+				return
+			}
+		} else {
+			// data is changed on the leading edge, sampled on the trailing one
+			if err = b.set(b.sclk, !idle); err != nil {
+				return
+			}
+			if err = b.setMOSI(out); err != nil {
+				return
+			}
+			delay(half)
+			if err = b.set(b.sclk, idle); err != nil {
+				return
+			}
+			if in, err = b.readMISO(out); err != nil {
+				return
+			}
+			delay(half)
+		}
+		if in {
+			r |= 1 << bit
+		}
+	}
+	return
+}
+
+func (b *bitBang) setMOSI(v bool) error {
+	if b.mosi == nil {
+		return nil
+	}
+	return b.set(b.mosi, v)
+}
+
+func (b *bitBang) readMISO(out bool) (bool, error) {
+	if b.mode&Loop != 0 {
+		return out, nil
+	}
+	if b.miso == nil {
+		return false, nil
+	}
+	v, err := b.miso.Read()
+	return v != 0, err
+}
+
+// Close implements Conn, it releases the chip select and the lines.
+func (b *bitBang) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var err error
+	if b.cs != nil {
+		err = b.selectCS(false)
+	}
+	if b.mosi != nil {
+		b.mosi.Input()
+	}
+	b.sclk.Input()
+	return err
+}
@@ -0,0 +1,277 @@
+package spi
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// simBus is a set of SPI lines with a simulated slave on them.
+type simBus struct {
+	mode             Mode
+	sclk, mosi, cs   bool
+	miso             bool
+	in, out          []bool // bits received and to send, in wire order
+	selects          int    // times the slave was selected
+	sampled, shifted int
+	edges            []time.Time // of the clock
+}
+
+// newSimBus returns lines with the chip select released and the clock
+// idle, the slave sends out.
+func newSimBus(mode Mode, out []bool) *simBus {
+	return &simBus{mode: mode, cs: mode&CSHigh == 0, sclk: mode&CPOL != 0, out: out}
+}
+
+func (s *simBus) active() bool { return s.cs == (s.mode&CSHigh != 0) }
+
+// shift puts the next bit to send on MISO.
+func (s *simBus) shift() {
+	if s.shifted < len(s.out) {
+		s.miso = s.out[s.shifted]
+	} else {
+		s.miso = false
+	}
+	s.shifted++
+}
+
+func (s *simBus) setCS(v bool) {
+	was := s.active()
+	s.cs = v
+	if !was && s.active() {
+		s.selects++
+		if s.mode&CPHA == 0 {
+			s.shift()
+		}
+	}
+}
+
+func (s *simBus) setSCLK(v bool) {
+	if v == s.sclk {
+		return
+	}
+	s.sclk = v
+	s.edges = append(s.edges, time.Now())
+	if !s.active() {
+		return
+	}
+	leading := v != (s.mode&CPOL != 0)
+	if leading == (s.mode&CPHA == 0) {
+		s.in = append(s.in, s.mosi)
+		s.sampled++
+	} else {
+		s.shift()
+	}
+}
+
+type simLine int
+
+const (
+	simSCLK simLine = iota
+	simMOSI
+	simMISO
+	simCS
+)
+
+type simPin struct {
+	s    *simBus
+	line simLine
+}
+
+func (p simPin) Input()  {}
+func (p simPin) Output() {}
+func (p simPin) set(v bool) error {
+	switch p.line {
+	case simSCLK:
+		p.s.setSCLK(v)
+	case simMOSI:
+		p.s.mosi = v
+	case simCS:
+		p.s.setCS(v)
+	default:
+		return fmt.Errorf("line %d is an input", p.line)
+	}
+	return nil
+}
+func (p simPin) High() error { return p.set(true) }
+func (p simPin) Low() error  { return p.set(false) }
+func (p simPin) Read() (uint, error) {
+	if p.line == simMISO && p.s.miso {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// wireBits returns the bits of the words packed in p as they go on the wire.
+func wireBits(p []byte, bpw uint8, lsb bool) []bool {
+	var bits []bool
+	wb := wordBytes(bpw)
+	for off := 0; off < len(p); off += wb {
+		w := getWord(p[off:], wb)
+		for i := uint(0); i < uint(bpw); i++ {
+			bit := uint(bpw) - 1 - i
+			if lsb {
+				bit = i
+			}
+			bits = append(bits, w>>bit&1 == 1)
+		}
+	}
+	return bits
+}
+
+func TestBitBang(t *testing.T) {
+	tests := []struct {
+		name string
+		mode Mode
+		bpw  uint8
+		tx   []byte
+		rx   []byte // sent by the slave
+	}{
+		{"mode0", Mode0, 8, []byte{0x9f, 0x01}, []byte{0xef, 0x40}},
+		{"mode1", Mode1, 8, []byte{0x9f, 0x01}, []byte{0xef, 0x40}},
+		{"mode2", Mode2, 8, []byte{0x9f, 0x01}, []byte{0xef, 0x40}},
+		{"mode3", Mode3, 8, []byte{0x9f, 0x01}, []byte{0xef, 0x40}},
+		{"lsb first", Mode0 | LSBFirst, 8, []byte{0x9f, 0x01}, []byte{0xef, 0x40}},
+		{"cs high", Mode3 | CSHigh, 8, []byte{0x55}, []byte{0xaa}},
+		{"9 bits", Mode0, 9, []byte{0x2a, 0x01, 0x55, 0x00}, []byte{0xff, 0x01, 0x00, 0x01}},
+		{"12 bits lsb first", Mode3 | LSBFirst, 12, []byte{0x34, 0x0a}, []byte{0x21, 0x0c}},
+		{"24 bits", Mode1, 24, []byte{1, 2, 3, 0}, []byte{4, 5, 6, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSimBus(tt.mode, wireBits(tt.rx, tt.bpw, tt.mode&LSBFirst != 0))
+			c, err := NewBitBang(simPin{s, simSCLK}, simPin{s, simMOSI}, simPin{s, simMISO}, simPin{s, simCS},
+				BitBangConfig{Mode: tt.mode, SpeedHz: 10000000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := NewDevice(c, "bitbang", Config{BitsPerWord: tt.bpw})
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := append([]byte(nil), tt.tx...)
+			if err = d.Tx(buf); err != nil {
+				t.Fatal(err)
+			}
+			if want := wireBits(tt.tx, tt.bpw, tt.mode&LSBFirst != 0); fmt.Sprint(s.in) != fmt.Sprint(want) {
+				t.Errorf("slave received %v, want %v", s.in, want)
+			}
+			if !bytes.Equal(buf, tt.rx) {
+				t.Errorf("master received % x, want % x", buf, tt.rx)
+			}
+			if s.active() || s.selects != 1 {
+				t.Errorf("chip select active %v after %d selects", s.active(), s.selects)
+			}
+			if s.sclk != (tt.mode&CPOL != 0) {
+				t.Errorf("clock not idle after the transfer")
+			}
+		})
+	}
+}
+
+func TestBitBang_chipSelect(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      CSPolicy
+		wantSelects int
+		wantActive  bool
+	}{
+		{"per message", CSPerMessage, 1, false},
+		{"per segment", CSPerSegment, 3, false},
+		{"hold", CSHold, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSimBus(Mode0, nil)
+			c, err := NewBitBang(simPin{s, simSCLK}, simPin{s, simMOSI}, simPin{s, simMISO}, simPin{s, simCS},
+				BitBangConfig{SpeedHz: 10000000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, _ := NewDevice(c, "bitbang", Config{CSChange: tt.policy})
+			if err = d.Transfer(Segment{Tx: []byte{0x03}}, Segment{Tx: []byte{0, 0x10}}, Segment{Rx: make([]byte, 4)}); err != nil {
+				t.Fatal(err)
+			}
+			if s.selects != tt.wantSelects || s.active() != tt.wantActive {
+				t.Errorf("%d selects, active %v; want %d, %v", s.selects, s.active(), tt.wantSelects, tt.wantActive)
+			}
+			if s.sampled != 7*8 {
+				t.Errorf("slave sampled %d bits, want %d", s.sampled, 7*8)
+			}
+		})
+	}
+}
+
+func TestBitBang_speed(t *testing.T) {
+	tests := []struct {
+		name     string
+		conn     uint32 // BitBangConfig.SpeedHz
+		segment  uint32 // Segment.SpeedHz
+		wantConn uint32
+		wantHalf time.Duration
+	}{
+		{"default", 0, 0, 100000, 5 * time.Microsecond},
+		{"conn", 10000, 0, 10000, 50 * time.Microsecond},
+		{"segment", 10000, 5000, 10000, 100 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSimBus(Mode0, nil)
+			c, err := NewBitBang(simPin{s, simSCLK}, simPin{s, simMOSI}, simPin{s, simMISO}, simPin{s, simCS},
+				BitBangConfig{SpeedHz: tt.conn})
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := NewDevice(c, "bitbang", Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg, _ := d.Config(); cfg.MaxSpeedHz != tt.wantConn {
+				t.Errorf("Config().MaxSpeedHz = %d, want %d", cfg.MaxSpeedHz, tt.wantConn)
+			}
+			if err = d.Transfer(Segment{Tx: []byte{0x5a}, SpeedHz: tt.segment}); err != nil {
+				t.Fatal(err)
+			}
+			if len(s.edges) != 16 {
+				t.Fatalf("%d clock edges, want 16", len(s.edges))
+			}
+			for i := 1; i < len(s.edges); i++ {
+				if half := s.edges[i].Sub(s.edges[i-1]); half < tt.wantHalf {
+					t.Fatalf("clock edge %d after %v, want at least %v", i, half, tt.wantHalf)
+				}
+			}
+		})
+	}
+
+	s := newSimBus(Mode1, nil)
+	c, _ := NewBitBang(simPin{s, simSCLK}, simPin{s, simMOSI}, simPin{s, simMISO}, simPin{s, simCS}, BitBangConfig{Mode: Mode1})
+	if _, err := NewDevice(c, "bitbang", Config{MaxSpeedHz: 1000000}); err == nil {
+		t.Error("NewDevice() accepted a clock rate for a Conn")
+	}
+	if _, err := NewDevice(c, "bitbang", Config{Mode: Mode2}); err == nil {
+		t.Error("NewDevice() accepted a mode for a Conn")
+	}
+	d, _ := NewDevice(c, "bitbang", Config{})
+	if cfg, _ := d.Config(); cfg.Mode != Mode1 {
+		t.Errorf("Config().Mode = %v, want %v", cfg.Mode, Mode1)
+	}
+}
+
+func TestBitBang_loop(t *testing.T) {
+	s := newSimBus(Mode0, nil)
+	c, err := NewBitBang(simPin{s, simSCLK}, simPin{s, simMOSI}, nil, simPin{s, simCS}, BitBangConfig{Mode: Mode0 | Loop, SpeedHz: 10000000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := NewDevice(c, "bitbang", Config{})
+	if b, err := d.TransferAndReceiveByte(0xa5); err != nil || b != 0xa5 {
+		t.Errorf("TransferAndReceiveByte() = %#x, %v, want 0xa5", b, err)
+	}
+	if _, err = NewBitBang(simPin{s, simSCLK}, nil, nil, nil, BitBangConfig{}); err == nil {
+		t.Error("NewBitBang() without chip select nor NoCS succeeded")
+	}
+	if _, err = NewBitBang(simPin{s, simSCLK}, nil, nil, nil, BitBangConfig{Mode: NoCS | TxQuad}); err == nil {
+		t.Error("NewBitBang() accepted TxQuad")
+	}
+}
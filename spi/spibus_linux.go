@@ -0,0 +1,122 @@
+package spi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Where the kernel puts spidev devices, variables for the tests.
+var (
+	devDir         = "/dev"
+	sysfsSPIDev    = "/sys/class/spidev"
+	sysfsSPIMaster = "/sys/class/spi_master"
+	// sysfsSPIDevBufsiz holds the largest message spidev takes.
+	sysfsSPIDevBufsiz = "/sys/module/spidev/parameters/bufsiz"
+)
+
+func devName(bus, cs int) string {
+	return filepath.Join(devDir, fmt.Sprintf("spidev%d.%d", bus, cs))
+}
+
+// Open opens /dev/spidev<bus>.<cs> and sets it up with cfg.
+// All devices must be closed once they are no longer in use.
+func Open(bus, cs int, cfg Config) (*Device, error) {
+	if bus < 0 || cs < 0 {
+		return nil, fmt.Errorf("spi: invalid bus %d chip select %d", bus, cs)
+	}
+	d := &Device{bus: bus, cs: cs, channel: byte(cs)}
+	if err := d.open(devName(bus, cs), cfg); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// BusInfo describes a spidev device.
+type BusInfo struct {
+	Bus int
+	CS  int
+	// Dev is the device node, e.g. "/dev/spidev0.1".
+	Dev string
+	// Controller is the name of the controller device, e.g.
+	// "fe204000.spi", and Compatible the first entry of its device tree
+	// compatible, e.g. "brcm,bcm2835-spi". Both are empty when unknown.
+	Controller string
+	Compatible string
+	// MaxSpeedHz is spi-max-frequency from the device tree, 0 when unknown.
+	MaxSpeedHz uint32
+}
+
+// Buses lists the spidev devices found in /dev and /sys/class/spidev,
+// sorted by bus and chip select.
+func Buses() ([]BusInfo, error) {
+	found := map[[2]int]bool{}
+	var infos []BusInfo
+	add := func(name string) {
+		var bus, cs int
+		if _, err := fmt.Sscanf(name, "spidev%d.%d", &bus, &cs); err != nil {
+			return
+		}
+		if found[[2]int{bus, cs}] {
+			return
+		}
+		found[[2]int{bus, cs}] = true
+		info := BusInfo{Bus: bus, CS: cs, Dev: devName(bus, cs)}
+		info.Controller, info.Compatible = spiController(bus)
+		info.MaxSpeedHz = spiMaxFrequency(filepath.Join(sysfsSPIDev, name, "device", "of_node"))
+		infos = append(infos, info)
+	}
+
+	devs, err := filepath.Glob(filepath.Join(devDir, "spidev*.*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range devs {
+		add(filepath.Base(d))
+	}
+	entries, err := ioutil.ReadDir(sysfsSPIDev)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		add(e.Name())
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Bus != infos[j].Bus {
+			return infos[i].Bus < infos[j].Bus
+		}
+		return infos[i].CS < infos[j].CS
+	})
+	return infos, nil
+}
+
+// spiController returns the name and device tree compatible of the
+// controller of bus.
+func spiController(bus int) (name string, compatible string) {
+	dev := filepath.Join(sysfsSPIMaster, fmt.Sprintf("spi%d", bus), "device")
+	target, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return "", ""
+	}
+	name = filepath.Base(target)
+	if b, err := ioutil.ReadFile(filepath.Join(dev, "of_node", "compatible")); err == nil {
+		// a list of NUL terminated strings
+		compatible = strings.SplitN(string(b), "\x00", 2)[0]
+	}
+	return
+}
+
+// spiMaxFrequency reads spi-max-frequency, a big endian cell, from the
+// device tree node ofNode.
+func spiMaxFrequency(ofNode string) uint32 {
+	b, err := ioutil.ReadFile(filepath.Join(ofNode, "spi-max-frequency"))
+	if err != nil || len(b) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
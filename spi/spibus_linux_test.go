@@ -0,0 +1,82 @@
+package spi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuses(t *testing.T) {
+	root, err := ioutil.TempDir("", "spibus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer func(d, s, m string) { devDir, sysfsSPIDev, sysfsSPIMaster = d, s, m }(devDir, sysfsSPIDev, sysfsSPIMaster)
+	devDir = filepath.Join(root, "dev")
+	sysfsSPIDev = filepath.Join(root, "class/spidev")
+	sysfsSPIMaster = filepath.Join(root, "class/spi_master")
+
+	mkdir := func(p string) {
+		if err := os.MkdirAll(filepath.Join(root, p), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(p string, b []byte) {
+		mkdir(filepath.Dir(p))
+		if err := ioutil.WriteFile(filepath.Join(root, p), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(target, p string) {
+		mkdir(filepath.Dir(p))
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// spi0 with a device tree, spi3 without
+	write("devices/fe204000.spi/of_node/compatible", []byte("brcm,bcm2835-spi\x00"))
+	write("devices/fe204000.spi/spi0.1/of_node/spi-max-frequency", []byte{0x00, 0x7a, 0x12, 0x00})
+	link("devices/fe204000.spi", "class/spi_master/spi0/device")
+	link("devices/fe204000.spi/spi0.1", "class/spidev/spidev0.1/device")
+	mkdir("devices/fe204600.spi")
+	link("devices/fe204600.spi", "class/spi_master/spi3/device")
+	write("dev/spidev3.0", nil)
+	write("dev/spidev0.1", nil)
+	write("dev/spidev0.0", nil)
+
+	got, err := Buses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BusInfo{
+		{Bus: 0, CS: 0, Dev: devDir + "/spidev0.0", Controller: "fe204000.spi", Compatible: "brcm,bcm2835-spi"},
+		{Bus: 0, CS: 1, Dev: devDir + "/spidev0.1", Controller: "fe204000.spi", Compatible: "brcm,bcm2835-spi", MaxSpeedHz: 8000000},
+		{Bus: 3, CS: 0, Dev: devDir + "/spidev3.0", Controller: "fe204600.spi"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Buses() = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestOpen_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		bus, cs int
+		cfg     Config
+	}{
+		{name: "bus", bus: -1},
+		{name: "cs", cs: -1},
+		{name: "mode", cfg: Config{Mode: 1 << 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d, err := Open(tt.bus, tt.cs, tt.cfg); err == nil {
+				d.Close()
+				t.Errorf("Open(%d, %d, %+v) succeeded", tt.bus, tt.cs, tt.cfg)
+			}
+		})
+	}
+}
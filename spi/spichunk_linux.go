@@ -0,0 +1,98 @@
+package spi
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultSPIBufsiz is the spidev bufsiz module parameter default.
+	defaultSPIBufsiz = 4096
+	// spiBufAlign is what spidev rounds each transfer up to when it
+	// adds them against bufsiz (ARCH_KMALLOC_MINALIGN, 128 at most on
+	// arm and arm64).
+	spiBufAlign = 128
+)
+
+// spidevBufsiz returns the largest message spidev accepts, in bytes sent
+// and in bytes received.
+func spidevBufsiz() int {
+	b, err := ioutil.ReadFile(sysfsSPIDevBufsiz)
+	if err != nil {
+		return defaultSPIBufsiz
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || n <= 0 {
+		return defaultSPIBufsiz
+	}
+	return n
+}
+
+func window(b []byte, i, j int) []byte {
+	if b == nil {
+		return nil
+	}
+	return b[i:j:j]
+}
+
+// split cuts a message, with the device settings applied, into messages
+// spidev accepts: at most maxSegments segments and bufsiz bytes each way,
+// 0 for no size limit. Segments too large for bufsiz are cut at a word
+// boundary. The chip select stays asserted from one message to the next:
+// their last segment has CSChange inverted, since cs_change on the last
+// transfer of a message keeps the device selected.
+func split(segs []Segment, bufsiz int) [][]Segment {
+	limit := bufsiz
+	if limit > spiBufAlign {
+		limit -= limit % spiBufAlign
+	}
+	var (
+		msgs   [][]Segment
+		msg    []Segment
+		tx, rx int
+	)
+	add := func(s Segment) {
+		n, _ := s.len()
+		size := (n + spiBufAlign - 1) &^ (spiBufAlign - 1)
+		full := len(msg) == maxSegments || bufsiz > 0 && (s.Tx != nil && tx+size > bufsiz || s.Rx != nil && rx+size > bufsiz)
+		if full && len(msg) > 0 {
+			msgs = append(msgs, msg)
+			msg, tx, rx = nil, 0, 0
+		}
+		if s.Tx != nil {
+			tx += size
+		}
+		if s.Rx != nil {
+			rx += size
+		}
+		msg = append(msg, s)
+	}
+	for _, s := range segs {
+		n, _ := s.len()
+		piece := n
+		if bufsiz > 0 && n > limit {
+			wb := wordBytes(s.BitsPerWord)
+			if piece = limit - limit%wb; piece == 0 {
+				piece = wb
+			}
+		}
+		for off := 0; ; off += piece {
+			p := s
+			if off+piece >= n {
+				p.Tx, p.Rx = window(s.Tx, off, n), window(s.Rx, off, n)
+				add(p)
+				break
+			}
+			p.Tx, p.Rx = window(s.Tx, off, off+piece), window(s.Rx, off, off+piece)
+			p.Delay, p.CSChange = 0, false
+			add(p)
+		}
+	}
+	msgs = append(msgs, msg)
+
+	for _, m := range msgs[:len(msgs)-1] {
+		m[len(m)-1].CSChange = !m[len(m)-1].CSChange
+	}
+	return msgs
+}
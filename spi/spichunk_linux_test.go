@@ -0,0 +1,149 @@
+package spi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_split(t *testing.T) {
+	type piece struct {
+		n  int
+		cs bool
+	}
+	buf := make([]byte, 10000)
+	tests := []struct {
+		name string
+		segs []Segment
+		want [][]piece
+	}{
+		{"small", []Segment{{Tx: buf[:10]}}, [][]piece{{{10, false}}}},
+		{"large", []Segment{{Tx: buf, Rx: buf}}, [][]piece{{{4096, true}}, {{4096, true}}, {{1808, false}}}},
+		{"packed", []Segment{{Tx: buf[:100]}, {Rx: buf[:4000]}, {Tx: buf[:100]}}, [][]piece{{{100, false}, {4000, false}, {100, false}}}},
+		{"tx full", []Segment{{Tx: buf[:4000]}, {Tx: buf[:100]}}, [][]piece{{{4000, true}}, {{100, false}}}},
+		{"cs change at the cut", []Segment{{Tx: buf[:4000], CSChange: true}, {Tx: buf[:100]}}, [][]piece{{{4000, false}}, {{100, false}}}},
+		{"hold", []Segment{{Tx: buf[:5000], CSChange: true}}, [][]piece{{{4096, true}}, {{904, true}}}},
+		{"empty", []Segment{{}}, [][]piece{{{0, false}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]piece
+			for _, m := range split(tt.segs, 4096) {
+				var ps []piece
+				for _, s := range m {
+					n, _ := s.len()
+					ps = append(ps, piece{n, s.CSChange})
+				}
+				got = append(got, ps)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("split() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// the pieces of a cut segment follow each other, the delay comes last
+	msgs := split([]Segment{{Tx: buf, Rx: buf, BitsPerWord: 16, Delay: time.Millisecond}}, 1000)
+	off := 0
+	for i, m := range msgs {
+		s := m[0]
+		if len(s.Tx)%2 != 0 || &s.Tx[0] != &buf[off] || &s.Rx[0] != &buf[off] {
+			t.Fatalf("piece %d at %d is %d bytes", i, off, len(s.Tx))
+		}
+		if last := i == len(msgs)-1; (s.Delay != 0) != last {
+			t.Errorf("piece %d delay = %v", i, s.Delay)
+		}
+		off += len(s.Tx)
+	}
+	if off != len(buf) {
+		t.Errorf("pieces cover %d bytes, want %d", off, len(buf))
+	}
+
+	segs := make([]Segment, 600)
+	msgs = split(segs, 0)
+	if len(msgs) != 2 || len(msgs[0]) != maxSegments || len(msgs[1]) != 600-maxSegments {
+		t.Errorf("600 segments split in %d messages", len(msgs))
+	}
+}
+
+func Test_spidevBufsiz(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spidev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(p string) { sysfsSPIDevBufsiz = p }(sysfsSPIDevBufsiz)
+	sysfsSPIDevBufsiz = filepath.Join(dir, "bufsiz")
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"missing", "", defaultSPIBufsiz},
+		{"set", "65536\n", 65536},
+		{"invalid", "lots\n", defaultSPIBufsiz},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(sysfsSPIDevBufsiz)
+			if tt.content != "" {
+				if err := ioutil.WriteFile(sysfsSPIDevBufsiz, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := spidevBufsiz(); got != tt.want {
+				t.Errorf("spidevBufsiz() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// recConn records the messages it is given.
+type recConn struct {
+	msgs [][]Segment
+}
+
+func (c *recConn) Transfer(segments ...Segment) error {
+	c.msgs = append(c.msgs, segments)
+	return nil
+}
+
+func (c *recConn) Close() error { return nil }
+
+func TestStream(t *testing.T) {
+	c := &recConn{}
+	d, err := NewDevice(c, "rec", Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := d.Stream()
+	if n, err := s.Write([]byte{1, 2}); n != 2 || err != nil {
+		t.Errorf("Write() = %d, %v", n, err)
+	}
+	if n, err := s.Read(make([]byte, 3)); n != 3 || err != nil {
+		t.Errorf("Read() = %d, %v", n, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte{1}); err == nil {
+		t.Error("Write() after Close() succeeded")
+	}
+
+	want := []bool{true, true, false}
+	if len(c.msgs) != len(want) {
+		t.Fatalf("%d messages, want %d", len(c.msgs), len(want))
+	}
+	for i, m := range c.msgs {
+		if len(m) != 1 || m[0].CSChange != want[i] {
+			t.Errorf("message %d = %+v", i, m)
+		}
+	}
+	if n, _ := c.msgs[2][0].len(); n != 0 {
+		t.Errorf("Close() sent %d bytes", n)
+	}
+}
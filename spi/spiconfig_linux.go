@@ -0,0 +1,80 @@
+package spi
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config is the setup of a device. It is checked when the device is
+// opened and applies to every transfer, unless a Segment overrides it.
+type Config struct {
+	// Mode is the SPI mode (Mode0 to Mode3) with its flags.
+	Mode Mode
+	// MaxSpeedHz is the clock rate, 0 for 1MHz.
+	MaxSpeedHz uint32
+	// BitsPerWord is the word size, 0 for 8.
+	BitsPerWord uint8
+	// Delay is waited after each segment (at most 65535us).
+	Delay time.Duration
+	// CSChange tells when the chip select is released.
+	CSChange CSPolicy
+	// Logger receives debug messages, nil for none.
+	Logger Logger
+}
+
+// CSPolicy tells when the chip select is released during a Transfer.
+type CSPolicy int
+
+const (
+	// CSPerMessage keeps the chip select asserted for the whole message.
+	CSPerMessage CSPolicy = iota
+	// CSPerSegment releases it between the segments of a message.
+	CSPerSegment
+	// CSHold also keeps it asserted after the message, until the next
+	// one starts.
+	CSHold
+)
+
+// Logger is where a device logs, a *log.Logger is one.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// check validates c and fills in the defaults.
+func (c *Config) check() error {
+	if c.Mode&^modeMask != 0 {
+		return fmt.Errorf("spi: invalid mode %v", c.Mode)
+	}
+	if c.MaxSpeedHz == 0 {
+		c.MaxSpeedHz = defaultSPISpeed
+	}
+	if c.BitsPerWord == 0 {
+		c.BitsPerWord = defaultSPIBPW
+	} else if c.BitsPerWord > 32 {
+		return fmt.Errorf("spi: invalid bits per word %d", c.BitsPerWord)
+	}
+	if _, err := usecs(c.Delay, 1<<16-1, "delay"); err != nil {
+		return err
+	}
+	if c.CSChange < CSPerMessage || c.CSChange > CSHold {
+		return fmt.Errorf("spi: invalid chip select policy %d", c.CSChange)
+	}
+	return nil
+}
+
+// csChange tells whether the chip select changes after segment i of n.
+func (c *Config) csChange(i, n int) bool {
+	switch c.CSChange {
+	case CSPerSegment:
+		return i < n-1
+	case CSHold:
+		return i == n-1
+	}
+	return false
+}
+
+func (d *Device) logf(format string, v ...interface{}) {
+	if d.cfg.Logger != nil {
+		d.cfg.Logger.Printf(format, v...)
+	}
+}
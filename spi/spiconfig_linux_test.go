@@ -0,0 +1,84 @@
+package spi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_check(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    Config
+		wantErr bool
+	}{
+		{name: "defaults", want: Config{MaxSpeedHz: defaultSPISpeed, BitsPerWord: defaultSPIBPW}},
+		{name: "kept", cfg: Config{Mode: Mode3 | LSBFirst, MaxSpeedHz: 8000000, BitsPerWord: 9, Delay: 10 * time.Microsecond, CSChange: CSHold},
+			want: Config{Mode: Mode3 | LSBFirst, MaxSpeedHz: 8000000, BitsPerWord: 9, Delay: 10 * time.Microsecond, CSChange: CSHold}},
+		{name: "mode", cfg: Config{Mode: 1 << 20}, wantErr: true},
+		{name: "bits per word", cfg: Config{BitsPerWord: 33}, wantErr: true},
+		{name: "delay", cfg: Config{Delay: time.Second}, wantErr: true},
+		{name: "cs policy", cfg: Config{CSChange: CSHold + 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			err := cfg.check()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg != tt.want {
+				t.Errorf("check() = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestDevice_message(t *testing.T) {
+	segs := []Segment{
+		{Tx: []byte{0x9f}},
+		{Rx: make([]byte, 3), SpeedHz: 500000, Delay: 5 * time.Microsecond},
+		{Tx: []byte{1, 2}, BitsPerWord: 9},
+	}
+	tests := []struct {
+		name   string
+		policy CSPolicy
+		cs     []uint8
+	}{
+		{"per message", CSPerMessage, []uint8{0, 0, 0}},
+		{"per segment", CSPerSegment, []uint8{1, 1, 0}},
+		{"hold", CSHold, []uint8{0, 0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Device{cfg: Config{MaxSpeedHz: 1000000, BitsPerWord: 8, Delay: time.Microsecond, CSChange: tt.policy}}
+			msgs, err := d.message(segs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) != 1 {
+				t.Fatalf("message split in %d", len(msgs))
+			}
+			xfers := msgs[0]
+			for i, x := range xfers {
+				if x.csChange != tt.cs[i] {
+					t.Errorf("segment %d cs_change = %d, want %d", i, x.csChange, tt.cs[i])
+				}
+			}
+			if x := xfers[0]; x.len != 1 || x.rxBuf != 0 || x.speedHz != 1000000 || x.bitsPerWord != 8 || x.delayUsecs != 1 {
+				t.Errorf("segment 0 = %+v", x)
+			}
+			if x := xfers[1]; x.len != 3 || x.txBuf != 0 || x.speedHz != 500000 || x.delayUsecs != 5 {
+				t.Errorf("segment 1 = %+v", x)
+			}
+			if x := xfers[2]; x.len != 2 || x.bitsPerWord != 9 {
+				t.Errorf("segment 2 = %+v", x)
+			}
+		})
+	}
+
+	d := &Device{}
+	if _, err := d.message([]Segment{{Tx: []byte{1}, Rx: []byte{1, 2}}}); err == nil {
+		t.Error("message() accepted tx and rx of different lengths")
+	}
+}
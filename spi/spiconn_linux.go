@@ -0,0 +1,31 @@
+package spi
+
+import "errors"
+
+// Conn is a SPI transport. A Device opened with Open drives spidev;
+// NewBitBang returns a software one.
+type Conn interface {
+	// Transfer sends the segments as a single message. The settings of
+	// the segments are complete, see Device.Transfer.
+	Transfer(segments ...Segment) error
+	Close() error
+}
+
+// NewDevice returns a Device issuing its transfers on c, name is the name
+// reported in logs. cfg gives the segment defaults, but the mode and the
+// clock rate are the ones c was set up with: cfg.Mode and cfg.MaxSpeedHz
+// must be left to zero, a Segment can still set its SpeedHz. Closing the
+// device closes c.
+func NewDevice(c Conn, name string, cfg Config) (*Device, error) {
+	if cfg.Mode != 0 || cfg.MaxSpeedHz != 0 {
+		return nil, errors.New("spi: the mode and clock rate of a Conn are set on the Conn")
+	}
+	if err := cfg.check(); err != nil {
+		return nil, err
+	}
+	cfg.MaxSpeedHz = 0
+	if s, ok := c.(interface{ settings() (Mode, uint32) }); ok {
+		cfg.Mode, cfg.MaxSpeedHz = s.settings()
+	}
+	return &Device{conn: c, name: name, cfg: cfg}, nil
+}
@@ -0,0 +1,68 @@
+package spi
+
+// The spidev ABI is computed here rather than taken from the C headers, so
+// the package builds without cgo and cross-compiles (e.g. GOARCH=arm
+// CGO_ENABLED=0). The numbers follow the asm-generic _IOC encoding used by
+// arm, arm64 and x86.
+
+const (
+	iocNRBits   = 8
+	iocTypeBits = 8
+	iocSizeBits = 14
+
+	iocNRShift   = 0
+	iocTypeShift = iocNRShift + iocNRBits
+	iocSizeShift = iocTypeShift + iocTypeBits
+	iocDirShift  = iocSizeShift + iocSizeBits
+
+	iocWrite = 1
+	iocRead  = 2
+)
+
+// ioc is _IOC(dir, typ, nr, size).
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<iocDirShift | typ<<iocTypeShift | nr<<iocNRShift | size<<iocSizeShift
+}
+
+const spiIOCMagic = 'k'
+
+// SPI_IOC_* from linux/spi/spidev.h
+const (
+	spiIOCRDMode        = iocRead<<iocDirShift | spiIOCMagic<<iocTypeShift | 1 | 1<<iocSizeShift
+	spiIOCWRMode        = iocWrite<<iocDirShift | spiIOCMagic<<iocTypeShift | 1 | 1<<iocSizeShift
+	spiIOCRDLSBFirst    = iocRead<<iocDirShift | spiIOCMagic<<iocTypeShift | 2 | 1<<iocSizeShift
+	spiIOCWRLSBFirst    = iocWrite<<iocDirShift | spiIOCMagic<<iocTypeShift | 2 | 1<<iocSizeShift
+	spiIOCRDBitsPerWord = iocRead<<iocDirShift | spiIOCMagic<<iocTypeShift | 3 | 1<<iocSizeShift
+	spiIOCWRBitsPerWord = iocWrite<<iocDirShift | spiIOCMagic<<iocTypeShift | 3 | 1<<iocSizeShift
+	spiIOCRDMaxSpeedHz  = iocRead<<iocDirShift | spiIOCMagic<<iocTypeShift | 4 | 4<<iocSizeShift
+	spiIOCWRMaxSpeedHz  = iocWrite<<iocDirShift | spiIOCMagic<<iocTypeShift | 4 | 4<<iocSizeShift
+	spiIOCRDMode32      = iocRead<<iocDirShift | spiIOCMagic<<iocTypeShift | 5 | 4<<iocSizeShift
+	spiIOCWRMode32      = iocWrite<<iocDirShift | spiIOCMagic<<iocTypeShift | 5 | 4<<iocSizeShift
+)
+
+// spiIOCTransfer is struct spi_ioc_transfer. It has the same layout, 32
+// bytes, on 32 and 64-bit.
+type spiIOCTransfer struct {
+	txBuf          uint64
+	rxBuf          uint64
+	len            uint32
+	speedHz        uint32
+	delayUsecs     uint16
+	bitsPerWord    uint8
+	csChange       uint8
+	txNbits        uint8
+	rxNbits        uint8
+	wordDelayUsecs uint8
+	pad            uint8
+}
+
+const sizeofSPIIOCTransfer = 32
+
+// spiIOCMessageN is SPI_IOC_MESSAGE(n), n must be at most maxSegments.
+func spiIOCMessageN(n uint32) uint32 {
+	return uint32(ioc(iocWrite, spiIOCMagic, 0, uintptr(n)*sizeofSPIIOCTransfer))
+}
+
+// maxSegments is the most transfers SPI_IOC_MESSAGE(n) can carry: the
+// message size must fit the size field of the ioctl number.
+const maxSegments = (1<<iocSizeBits - 1) / sizeofSPIIOCTransfer
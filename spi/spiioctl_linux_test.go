@@ -0,0 +1,67 @@
+package spi
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func Test_ioctlNumbers(t *testing.T) {
+	// values from linux/spi/spidev.h, the same on 32 and 64-bit
+	tests := []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{"SPI_IOC_RD_MODE", spiIOCRDMode, 0x80016b01},
+		{"SPI_IOC_WR_MODE", spiIOCWRMode, 0x40016b01},
+		{"SPI_IOC_RD_LSB_FIRST", spiIOCRDLSBFirst, 0x80016b02},
+		{"SPI_IOC_WR_LSB_FIRST", spiIOCWRLSBFirst, 0x40016b02},
+		{"SPI_IOC_RD_BITS_PER_WORD", spiIOCRDBitsPerWord, 0x80016b03},
+		{"SPI_IOC_WR_BITS_PER_WORD", spiIOCWRBitsPerWord, 0x40016b03},
+		{"SPI_IOC_RD_MAX_SPEED_HZ", spiIOCRDMaxSpeedHz, 0x80046b04},
+		{"SPI_IOC_WR_MAX_SPEED_HZ", spiIOCWRMaxSpeedHz, 0x40046b04},
+		{"SPI_IOC_RD_MODE32", spiIOCRDMode32, 0x80046b05},
+		{"SPI_IOC_WR_MODE32", spiIOCWRMode32, 0x40046b05},
+		{"SPI_IOC_MESSAGE(1)", uintptr(spiIOCMessageN(1)), 0x40206b00},
+		{"SPI_IOC_MESSAGE(2)", uintptr(spiIOCMessageN(2)), 0x40406b00},
+		{"SPI_IOC_MESSAGE(511)", uintptr(spiIOCMessageN(maxSegments)), 0x7fe06b00},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %#x, want %#x", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_spiIOCTransfer(t *testing.T) {
+	var x spiIOCTransfer
+	if s := unsafe.Sizeof(x); s != sizeofSPIIOCTransfer {
+		t.Errorf("sizeof(spi_ioc_transfer) = %d, want %d", s, sizeofSPIIOCTransfer)
+	}
+	tests := []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{"tx_buf", unsafe.Offsetof(x.txBuf), 0},
+		{"rx_buf", unsafe.Offsetof(x.rxBuf), 8},
+		{"len", unsafe.Offsetof(x.len), 16},
+		{"speed_hz", unsafe.Offsetof(x.speedHz), 20},
+		{"delay_usecs", unsafe.Offsetof(x.delayUsecs), 24},
+		{"bits_per_word", unsafe.Offsetof(x.bitsPerWord), 26},
+		{"cs_change", unsafe.Offsetof(x.csChange), 27},
+		{"tx_nbits", unsafe.Offsetof(x.txNbits), 28},
+		{"rx_nbits", unsafe.Offsetof(x.rxNbits), 29},
+		{"word_delay_usecs", unsafe.Offsetof(x.wordDelayUsecs), 30},
+		{"pad", unsafe.Offsetof(x.pad), 31},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("offsetof(%s) = %d, want %d", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
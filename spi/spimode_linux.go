@@ -0,0 +1,116 @@
+package spi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Mode is the SPI mode with its flags, the mode field of spi_device.
+type Mode uint32
+
+// Mode flags from linux/spi/spi.h.
+const (
+	CPHA         Mode = 1 << iota // clock phase
+	CPOL                          // clock polarity
+	CSHigh                        // chip select active high
+	LSBFirst                      // least significant bit first on the wire
+	ThreeWire                     // MOSI and MISO shared
+	Loop                          // loopback, MOSI is read back on MISO
+	NoCS                          // one device on the bus, no chip select
+	Ready                         // slave pulls low to pause
+	TxDual                        // transmit with 2 wires
+	TxQuad                        // transmit with 4 wires
+	RxDual                        // receive with 2 wires
+	RxQuad                        // receive with 4 wires
+	CSWord                        // toggle chip select after each word
+	TxOctal                       // transmit with 8 wires
+	RxOctal                       // receive with 8 wires
+	ThreeWireHiZ                  // high impedance turnaround
+
+	Mode0 Mode = 0
+	Mode1      = CPHA
+	Mode2      = CPOL
+	Mode3      = CPOL | CPHA
+
+	modeMask = 1<<16 - 1
+)
+
+var modeNames = []string{"CPHA", "CPOL", "CSHigh", "LSBFirst", "ThreeWire", "Loop", "NoCS", "Ready",
+	"TxDual", "TxQuad", "RxDual", "RxQuad", "CSWord", "TxOctal", "RxOctal", "ThreeWireHiZ"}
+
+// String returns the SPI mode followed by the flags, e.g. "Mode3|LSBFirst".
+func (m Mode) String() string {
+	s := []string{fmt.Sprintf("Mode%d", m&Mode3)}
+	for i, name := range modeNames[2:] {
+		if m&(1<<uint(i+2)) != 0 {
+			s = append(s, name)
+		}
+	}
+	if m&^modeMask != 0 {
+		s = append(s, fmt.Sprintf("%#x", uint32(m&^modeMask)))
+	}
+	return strings.Join(s, "|")
+}
+
+func spiIoctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg)); errno != 0 {
+		return syscall.Errno(errno)
+	}
+	return nil
+}
+
+// spiSetMode sets the mode with SPI_IOC_WR_MODE when it fits 8 bits, so
+// older kernels keep working, and with SPI_IOC_WR_MODE32 otherwise.
+func spiSetMode(f *os.File, mode Mode) error {
+	if mode&^modeMask != 0 {
+		return fmt.Errorf("spi: invalid mode %v", mode)
+	}
+	if mode <= 0xff {
+		m := uint8(mode)
+		return spiIoctl(f, spiIOCWRMode, unsafe.Pointer(&m))
+	}
+	m := uint32(mode)
+	return spiIoctl(f, spiIOCWRMode32, unsafe.Pointer(&m))
+}
+
+func spiGetMode(f *os.File) (Mode, error) {
+	var m32 uint32
+	err := spiIoctl(f, spiIOCRDMode32, unsafe.Pointer(&m32))
+	if err == nil {
+		return Mode(m32), nil
+	}
+	var m8 uint8
+	if err = spiIoctl(f, spiIOCRDMode, unsafe.Pointer(&m8)); err != nil {
+		return 0, err
+	}
+	return Mode(m8), nil
+}
+
+// Config reads back the setup the kernel accepted for the device; the
+// settings the kernel does not keep are the ones the device was opened with.
+// The mode and clock rate of a device made by NewDevice are those of its
+// Conn, zero when the Conn does not tell them.
+func (d *Device) Config() (cfg Config, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cfg = d.cfg
+	if d.conn != nil {
+		return
+	}
+	if d.f == nil {
+		return cfg, errors.New("spi: device not open")
+	}
+	if cfg.Mode, err = spiGetMode(d.f); err != nil {
+		return
+	}
+	if err = spiIoctl(d.f, spiIOCRDMaxSpeedHz, unsafe.Pointer(&cfg.MaxSpeedHz)); err != nil {
+		return
+	}
+	err = spiIoctl(d.f, spiIOCRDBitsPerWord, unsafe.Pointer(&cfg.BitsPerWord))
+	return
+}
@@ -0,0 +1,22 @@
+package spi
+
+import "testing"
+
+func TestMode_String(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		want string
+	}{
+		{Mode0, "Mode0"},
+		{Mode3 | LSBFirst, "Mode3|LSBFirst"},
+		{Mode1 | Loop | TxQuad | RxQuad, "Mode1|Loop|TxQuad|RxQuad"},
+		{Mode2 | 1<<20, "Mode2|0x100000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.mode.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package spi
+
+import (
+	"errors"
+	"sync"
+)
+
+// Stream is a transfer spread over several calls, e.g. a display frame or
+// a flash page written from an io.Reader: the device stays selected from
+// the first Read or Write until Close. Other transfers made on the device
+// meanwhile are part of the same selection.
+type Stream struct {
+	d *Device
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+}
+
+// Stream returns a Stream on d. Nothing is sent until the first Read or
+// Write.
+func (d *Device) Stream() *Stream {
+	return &Stream{d: d}
+}
+
+var errStreamClosed = errors.New("spi: stream closed")
+
+// Write sends p, shifting in and discarding as many bytes.
+func (s *Stream) Write(p []byte) (int, error) {
+	return s.transfer(Segment{Tx: p})
+}
+
+// Read fills p with the bytes shifted in while zeros are sent.
+func (s *Stream) Read(p []byte) (int, error) {
+	return s.transfer(Segment{Rx: p})
+}
+
+func (s *Stream) transfer(seg Segment) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, errStreamClosed
+	}
+	if len(seg.Tx) == 0 && len(seg.Rx) == 0 {
+		return 0, nil
+	}
+	// cs_change on the last segment holds the chip select
+	seg.CSChange = true
+	if err := s.d.Transfer(seg); err != nil {
+		return 0, err
+	}
+	s.started = true
+	return len(seg.Tx) + len(seg.Rx), nil
+}
+
+// Close deselects the device with an empty transfer, unless its Config
+// has the CSHold policy.
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errStreamClosed
+	}
+	s.closed = true
+	if !s.started {
+		return nil
+	}
+	return s.d.Transfer(Segment{})
+}
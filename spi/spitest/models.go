@@ -0,0 +1,224 @@
+package spitest
+
+// ShiftRegister is a chain of 74HC595-style shift registers with the latch
+// clock wired to the chip select. Bytes shift in on MOSI, the byte pushed
+// out of the chain comes back on MISO (the last QH' wired to MISO), and the
+// outputs are latched when the chip select is released.
+type ShiftRegister struct {
+	// Outputs are the latched outputs, Outputs[0] is the register next to
+	// the master: it holds the last byte sent.
+	Outputs []byte
+
+	shift []byte
+}
+
+// NewShiftRegister returns a chain of n registers, all cleared.
+func NewShiftRegister(n int) *ShiftRegister {
+	return &ShiftRegister{Outputs: make([]byte, n), shift: make([]byte, n)}
+}
+
+func (m *ShiftRegister) Select() {}
+
+func (m *ShiftRegister) Exchange(tx byte) byte {
+	n := len(m.shift)
+	out := m.shift[n-1]
+	copy(m.shift[1:], m.shift[:n-1])
+	m.shift[0] = tx
+	return out
+}
+
+func (m *ShiftRegister) Deselect() {
+	copy(m.Outputs, m.shift)
+}
+
+// SPI NOR flash commands understood by Flash.
+const (
+	FlashWriteEnable  = 0x06
+	FlashWriteDisable = 0x04
+	FlashReadStatus   = 0x05
+	FlashRead         = 0x03
+	FlashFastRead     = 0x0b
+	FlashPageProgram  = 0x02
+	FlashSectorErase  = 0x20 // 4KB
+	FlashBlockErase   = 0xd8 // 64KB
+	FlashChipErase    = 0xc7
+	FlashReadID       = 0x9f
+)
+
+// Status register bits of Flash.
+const (
+	FlashWIP = 1 << 0 // write in progress
+	FlashWEL = 1 << 1 // write enable latch
+)
+
+const flashPageSize = 256
+
+// Flash is a 25-series SPI NOR flash with 3-byte addresses. Program and
+// erase need the write enable latch, which they clear; programming only
+// clears bits and wraps inside the 256-byte page. After a program or an
+// erase the chip reports a write in progress for the next BusyPolls
+// status reads and ignores other commands meanwhile.
+type Flash struct {
+	Mem       []byte
+	ID        uint32 // JEDEC id: manufacturer, type, capacity
+	BusyPolls int
+
+	status byte
+	busy   int
+
+	cmd  byte
+	n    int // bytes of the command exchanged so far
+	addr int
+	page []int  // offsets programmed by this command
+	data []byte // and the bytes for them
+}
+
+// NewFlash returns an erased flash of size bytes.
+func NewFlash(size int, id uint32) *Flash {
+	m := &Flash{Mem: make([]byte, size), ID: id}
+	for i := range m.Mem {
+		m.Mem[i] = 0xff
+	}
+	return m
+}
+
+// Status returns the status register.
+func (m *Flash) Status() byte {
+	if m.busy > 0 {
+		return m.status | FlashWIP
+	}
+	return m.status
+}
+
+func (m *Flash) Select() {
+	m.n = 0
+	m.addr = 0
+	m.page = m.page[:0]
+	m.data = m.data[:0]
+}
+
+func (m *Flash) Exchange(tx byte) byte {
+	n := m.n
+	m.n++
+	if n == 0 {
+		m.cmd = tx
+		if m.busy > 0 && tx != FlashReadStatus {
+			// ignored during the write cycle
+			m.cmd = 0
+		}
+		return 0xff
+	}
+	switch m.cmd {
+	case FlashReadStatus:
+		st := m.Status()
+		if m.busy > 0 {
+			m.busy--
+		}
+		return st
+	case FlashReadID:
+		if n <= 3 {
+			return byte(m.ID >> (8 * uint(3-n)))
+		}
+	case FlashRead, FlashFastRead, FlashPageProgram, FlashSectorErase, FlashBlockErase:
+		if n <= 3 {
+			m.addr = (m.addr<<8 | int(tx)) % len(m.Mem)
+			return 0xff
+		}
+		switch m.cmd {
+		case FlashFastRead:
+			if n == 4 {
+				return 0xff // dummy byte
+			}
+			fallthrough
+		case FlashRead:
+			c := m.Mem[m.addr]
+			m.addr = (m.addr + 1) % len(m.Mem)
+			return c
+		case FlashPageProgram:
+			m.page = append(m.page, m.addr)
+			m.data = append(m.data, tx)
+			page := m.addr - m.addr%flashPageSize
+			m.addr = page + (m.addr+1-page)%flashPageSize
+		}
+	}
+	return 0xff
+}
+
+func (m *Flash) Deselect() {
+	switch {
+	case m.n == 1 && m.cmd == FlashWriteEnable:
+		m.status |= FlashWEL
+	case m.n == 1 && m.cmd == FlashWriteDisable:
+		m.status &^= FlashWEL
+	case m.status&FlashWEL == 0:
+	case m.cmd == FlashPageProgram && m.n > 4:
+		for i, off := range m.page {
+			m.Mem[off] &= m.data[i]
+		}
+		m.written()
+	case m.cmd == FlashSectorErase && m.n == 4:
+		m.erase(4096)
+	case m.cmd == FlashBlockErase && m.n == 4:
+		m.erase(65536)
+	case m.cmd == FlashChipErase && m.n == 1:
+		m.addr = 0
+		m.erase(len(m.Mem))
+	}
+}
+
+func (m *Flash) erase(size int) {
+	start := m.addr - m.addr%size
+	for i := start; i < start+size && i < len(m.Mem); i++ {
+		m.Mem[i] = 0xff
+	}
+	m.written()
+}
+
+func (m *Flash) written() {
+	m.status &^= FlashWEL
+	m.busy = m.BusyPolls
+}
+
+// ADC is an MCP3008, an 8 channel 10-bit ADC, read with the usual 3 byte
+// exchange: 0x01, single-ended flag and channel in the high nibble, 0x00;
+// the result is in the low 2 bits of the second byte received and in the
+// third one. The samples it returns are queued per channel with Set.
+type ADC struct {
+	samples [8][]uint16
+	last    [8]uint16
+	n       int
+	v       uint16
+}
+
+// Set queues samples for channel ch. Each conversion takes the next one,
+// the last one is returned again once the queue is empty.
+func (m *ADC) Set(ch int, samples ...uint16) {
+	m.samples[ch] = append(m.samples[ch], samples...)
+}
+
+func (m *ADC) sample(ch int) uint16 {
+	if q := m.samples[ch]; len(q) > 0 {
+		m.last[ch] = q[0] & 0x3ff
+		m.samples[ch] = q[1:]
+	}
+	return m.last[ch]
+}
+
+func (m *ADC) Select() {
+	m.n = 0
+}
+
+func (m *ADC) Exchange(tx byte) byte {
+	n := m.n
+	m.n++
+	switch n {
+	case 1:
+		m.v = m.sample(int(tx>>4) & 7)
+		return byte(m.v >> 8)
+	case 2:
+		return byte(m.v)
+	}
+	return 0
+}
+
+func (m *ADC) Deselect() {}
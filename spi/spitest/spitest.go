@@ -0,0 +1,91 @@
+// Package spitest provides an in-memory SPI connection to test drivers
+// without hardware. A device model written in Go sits behind the chip
+// select of a Conn; the Conn is a spi.Conn, so spi.NewDevice gives drivers
+// the usual Device API:
+//
+//	flash := spitest.NewFlash(1<<20, 0xef4014)
+//	d, err := spi.NewDevice(spitest.NewConn(flash), "test", spi.Config{})
+//
+// Models exchange bytes: words wider than 8 bits go through as the bytes
+// spidev packs them in.
+package spitest
+
+import (
+	"sync"
+
+	"github.com/flyingyizi/go-wiringPi/spi"
+)
+
+// Model is a slave behind a Conn. It sees a message the way a chip does:
+// selected, one byte in for one byte out, then deselected.
+type Model interface {
+	Select()
+	Exchange(tx byte) (rx byte)
+	Deselect()
+}
+
+// Conn is an in-memory spi.Conn with one model behind its chip select.
+type Conn struct {
+	mu       sync.Mutex
+	m        Model
+	selected bool
+}
+
+// NewConn returns a Conn talking to m.
+func NewConn(m Model) *Conn {
+	return &Conn{m: m}
+}
+
+// Transfer implements spi.Conn, with the chip select handled as spidev
+// does: released after the message, or between segments with CSChange,
+// and held after the message when the last segment has CSChange.
+func (c *Conn) Transfer(segments ...spi.Segment) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, s := range segments {
+		n := len(s.Tx)
+		if s.Tx == nil {
+			n = len(s.Rx)
+		}
+		if !c.selected {
+			c.selected = true
+			c.m.Select()
+		}
+		for j := 0; j < n; j++ {
+			var tx byte
+			if s.Tx != nil {
+				tx = s.Tx[j]
+			}
+			rx := c.m.Exchange(tx)
+			if s.Rx != nil {
+				s.Rx[j] = rx
+			}
+		}
+		if s.CSChange != (i == len(segments)-1) {
+			c.selected = false
+			c.m.Deselect()
+		}
+	}
+	return nil
+}
+
+// Selected tells whether the chip select is held after a message.
+func (c *Conn) Selected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.selected
+}
+
+// Close implements spi.Conn, it releases the chip select.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.selected {
+		c.selected = false
+		c.m.Deselect()
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package spitest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/flyingyizi/go-wiringPi/spi"
+)
+
+func newDevice(t *testing.T, c spi.Conn) *spi.Device {
+	d, err := spi.NewDevice(c, "test", spi.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestShiftRegister(t *testing.T) {
+	sr := NewShiftRegister(2)
+	d := newDevice(t, NewConn(sr))
+
+	if _, err := d.Write([]byte{0x12, 0x34}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sr.Outputs, []byte{0x34, 0x12}) {
+		t.Errorf("Outputs = % x, want 34 12", sr.Outputs)
+	}
+	// the byte pushed out of the chain comes back
+	if b, err := d.TransferAndReceiveByte(0x56); err != nil || b != 0x12 {
+		t.Errorf("TransferAndReceiveByte() = %#x, %v, want 0x12", b, err)
+	}
+	if !bytes.Equal(sr.Outputs, []byte{0x56, 0x34}) {
+		t.Errorf("Outputs = % x, want 56 34", sr.Outputs)
+	}
+}
+
+func TestFlash(t *testing.T) {
+	flash := NewFlash(64*1024, 0xef4014)
+	flash.BusyPolls = 2
+	d := newDevice(t, NewConn(flash))
+
+	id := make([]byte, 3)
+	if err := d.Transfer(spi.Segment{Tx: []byte{FlashReadID}}, spi.Segment{Rx: id}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(id, []byte{0xef, 0x40, 0x14}) {
+		t.Errorf("JEDEC id = % x", id)
+	}
+
+	program := func(addr int, data []byte) {
+		d.Write([]byte{FlashWriteEnable})
+		d.Transfer(spi.Segment{Tx: []byte{FlashPageProgram, byte(addr >> 16), byte(addr >> 8), byte(addr)}}, spi.Segment{Tx: data})
+	}
+	status := func() byte {
+		buf := []byte{FlashReadStatus, 0}
+		d.Tx(buf)
+		return buf[1]
+	}
+	read := func(addr, n int) []byte {
+		buf := make([]byte, n)
+		d.Transfer(spi.Segment{Tx: []byte{FlashRead, byte(addr >> 16), byte(addr >> 8), byte(addr)}}, spi.Segment{Rx: buf})
+		return buf
+	}
+
+	// without the write enable latch nothing is programmed
+	d.Transfer(spi.Segment{Tx: []byte{FlashPageProgram, 0, 0, 0, 0x00}})
+	if flash.Mem[0] != 0xff {
+		t.Error("programmed without write enable")
+	}
+
+	program(0x1fe, []byte{1, 2, 3})
+	// other commands are ignored while busy
+	if got := read(0x1fe, 1); got[0] != 0xff {
+		t.Errorf("read while busy = % x, want ff", got)
+	}
+	polls := 0
+	for status()&FlashWIP != 0 {
+		polls++
+	}
+	if polls != 2 {
+		t.Errorf("busy for %d polls, want 2", polls)
+	}
+	if st := flash.Status(); st&FlashWEL != 0 {
+		t.Errorf("status %#x: write enable latch still set", st)
+	}
+	// the page wraps: 3 lands at 0x100
+	if got := read(0x1fe, 2); !bytes.Equal(got, []byte{1, 2}) {
+		t.Errorf("read 0x1fe = % x", got)
+	}
+	if got := read(0x100, 1); got[0] != 3 {
+		t.Errorf("read 0x100 = % x, want 03", got)
+	}
+	// programming only clears bits
+	program(0x1fe, []byte{0xfe})
+	for status()&FlashWIP != 0 {
+	}
+	if flash.Mem[0x1fe] != 0 {
+		t.Errorf("Mem[0x1fe] = %#x, want 0", flash.Mem[0x1fe])
+	}
+
+	d.Write([]byte{FlashWriteEnable})
+	d.Write([]byte{FlashSectorErase, 0, 0x01, 0x23})
+	for status()&FlashWIP != 0 {
+	}
+	if got := read(0x100, 1); got[0] != 0xff {
+		t.Errorf("read 0x100 after erase = % x", got)
+	}
+}
+
+func TestADC(t *testing.T) {
+	adc := &ADC{}
+	adc.Set(3, 0x123, 0x3ff)
+	d := newDevice(t, NewConn(adc))
+
+	tests := []struct {
+		ch   int
+		want uint16
+	}{
+		{3, 0x123},
+		{3, 0x3ff},
+		{3, 0x3ff},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		buf := []byte{0x01, 0x80 | byte(tt.ch)<<4, 0}
+		if err := d.Tx(buf); err != nil {
+			t.Fatal(err)
+		}
+		if got := uint16(buf[1]&3)<<8 | uint16(buf[2]); got != tt.want {
+			t.Errorf("channel %d = %#x, want %#x", tt.ch, got, tt.want)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	c := NewConn(NewShiftRegister(1))
+	tr := NewTracer(c)
+	d, err := spi.NewDevice(tr, "test", spi.Config{CSChange: spi.CSHold})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Write([]byte{0xaa})
+	d.ReceiveByte()
+
+	msgs := tr.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("%d messages, want 2", len(msgs))
+	}
+	s := msgs[1].Segments[0]
+	if !bytes.Equal(s.Tx, []byte{0}) || !bytes.Equal(s.Rx, []byte{0xaa}) || s.SpeedHz != 0 || !s.CSChange {
+		t.Errorf("message 1 = %+v", s)
+	}
+	if !c.Selected() {
+		t.Error("chip select released with CSHold")
+	}
+	d.Close()
+	if c.Selected() {
+		t.Error("chip select held after Close")
+	}
+	tr.Reset()
+	if len(tr.Messages()) != 0 {
+		t.Error("Reset() kept messages")
+	}
+}
@@ -0,0 +1,76 @@
+package spitest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flyingyizi/go-wiringPi/spi"
+)
+
+// Message is one Transfer seen by a Tracer.
+type Message struct {
+	Time     time.Time
+	Duration time.Duration
+	// Segments are copies of the segments, Rx holds what was received.
+	Segments []spi.Segment
+	Err      error
+}
+
+// Tracer is a spi.Conn recording every message passed to another Conn.
+type Tracer struct {
+	c spi.Conn
+
+	mu   sync.Mutex
+	msgs []Message
+}
+
+// NewTracer returns a Tracer passing messages to c.
+func NewTracer(c spi.Conn) *Tracer {
+	return &Tracer{c: c}
+}
+
+// Transfer implements spi.Conn.
+func (t *Tracer) Transfer(segments ...spi.Segment) error {
+	// Tx is copied first: it can share its buffer with Rx
+	msg := Message{Segments: make([]spi.Segment, len(segments))}
+	for i, s := range segments {
+		if s.Tx != nil {
+			s.Tx = append([]byte(nil), s.Tx...)
+		}
+		msg.Segments[i] = s
+	}
+	msg.Time = time.Now()
+	err := t.c.Transfer(segments...)
+	msg.Duration, msg.Err = time.Since(msg.Time), err
+	for i, s := range segments {
+		if s.Rx != nil {
+			msg.Segments[i].Rx = append([]byte(nil), s.Rx...)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.msgs = append(t.msgs, msg)
+	return err
+}
+
+// Close implements spi.Conn.
+func (t *Tracer) Close() error {
+	return t.c.Close()
+}
+
+// Messages returns the messages seen so far.
+func (t *Tracer) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Message(nil), t.msgs...)
+}
+
+// Reset forgets the messages seen so far.
+func (t *Tracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.msgs = nil
+}
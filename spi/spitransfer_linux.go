@@ -0,0 +1,191 @@
+package spi
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Segment is one transfer of a SPI message. The chip select stays
+// asserted from one segment to the next unless CSChange is set.
+type Segment struct {
+	// Tx is sent, nil shifts out zeros.
+	Tx []byte
+	// Rx receives as many bytes as are sent, nil discards them.
+	// When both are set they must have the same length.
+	Rx []byte
+
+	// SpeedHz and BitsPerWord override the device settings when non zero.
+	SpeedHz     uint32
+	BitsPerWord uint8
+	// TxNbits and RxNbits are the number of wires (1, 2, 4 or 8) used
+	// to send and receive, 0 for 1. The device mode must allow them,
+	// e.g. TxQuad for 4.
+	TxNbits uint8
+	RxNbits uint8
+
+	// Delay is waited after the last bit, before CSChange takes effect
+	// (at most 65535us).
+	Delay time.Duration
+	// WordDelay is waited between words, if the controller supports it
+	// (at most 255us).
+	WordDelay time.Duration
+	// CSChange deselects the device after this segment; on the last
+	// segment it leaves it selected until the next message instead.
+	// It adds to the CSChange policy of the device Config.
+	CSChange bool
+}
+
+func (s *Segment) len() (int, error) {
+	switch {
+	case s.Tx != nil && s.Rx != nil && len(s.Tx) != len(s.Rx):
+		return 0, fmt.Errorf("spi: segment tx is %d bytes and rx %d", len(s.Tx), len(s.Rx))
+	case s.Tx != nil:
+		return len(s.Tx), nil
+	}
+	return len(s.Rx), nil
+}
+
+func bufPtr(b []byte) uint64 {
+	if len(b) == 0 {
+		return 0
+	}
+	return uint64(uintptr(unsafe.Pointer(&b[0])))
+}
+
+func usecs(d time.Duration, max int64, what string) (int64, error) {
+	us := int64((d + time.Microsecond - 1) / time.Microsecond)
+	if us < 0 || us > max {
+		return 0, fmt.Errorf("spi: %s %v out of range", what, d)
+	}
+	return us, nil
+}
+
+// Transfer sends the segments as a single message, in one
+// SPI_IOC_MESSAGE ioctl for spidev. Segment settings left to zero come
+// from the device Config. A message larger than the spidev buffer (the
+// bufsiz module parameter, 4096 bytes by default) is sent in several
+// ioctls with the chip select kept asserted in between; another device
+// of the same controller can still get the bus in between.
+func (d *Device) Transfer(segments ...Segment) error {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.conn != nil {
+		segs, err := d.apply(segments)
+		if err != nil {
+			return err
+		}
+		if err = d.conn.Transfer(segs...); err != nil {
+			d.logf("spi: %s: transfer of %d segments: %v", d.name, len(segs), err)
+		}
+		return err
+	}
+	if d.f == nil {
+		return errors.New("spi: device not open")
+	}
+	msgs, err := d.message(segments)
+	if err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(segments)
+	if len(msgs) > 1 {
+		d.logf("spi: %s: message split in %d for a %d byte buffer", d.name, len(msgs), d.bufsiz)
+	}
+	for i, xfers := range msgs {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.f.Fd(), uintptr(spiIOCMessageN(uint32(len(xfers)))), uintptr(unsafe.Pointer(&xfers[0])))
+		if errno != 0 {
+			if i > 0 {
+				// the previous part left the device selected
+				d.release()
+			}
+			d.logf("spi: %s: transfer of %d segments: %v", d.name, len(xfers), syscall.Errno(errno))
+			return syscall.Errno(errno)
+		}
+	}
+	return nil
+}
+
+// release deselects the device with an empty transfer.
+func (d *Device) release() {
+	var x spiIOCTransfer
+	syscall.Syscall(syscall.SYS_IOCTL, d.f.Fd(), uintptr(spiIOCMessageN(1)), uintptr(unsafe.Pointer(&x)))
+}
+
+// apply returns a copy of segments with the settings left to zero taken
+// from the device Config and its chip select policy applied. The speed of
+// a Conn is left to the Conn.
+func (d *Device) apply(segments []Segment) ([]Segment, error) {
+	segs := make([]Segment, len(segments))
+	for i, s := range segments {
+		if _, err := s.len(); err != nil {
+			return nil, err
+		}
+		if s.SpeedHz == 0 && d.conn == nil {
+			s.SpeedHz = d.cfg.MaxSpeedHz
+		}
+		if s.BitsPerWord == 0 {
+			s.BitsPerWord = d.cfg.BitsPerWord
+		}
+		if s.Delay == 0 {
+			s.Delay = d.cfg.Delay
+		}
+		if _, err := usecs(s.Delay, 1<<16-1, "delay"); err != nil {
+			return nil, err
+		}
+		if _, err := usecs(s.WordDelay, 1<<8-1, "word delay"); err != nil {
+			return nil, err
+		}
+		s.CSChange = s.CSChange || d.cfg.csChange(i, len(segments))
+		segs[i] = s
+	}
+	return segs, nil
+}
+
+// message returns the spi_ioc_transfer arrays for segments, one per
+// ioctl.
+func (d *Device) message(segments []Segment) ([][]spiIOCTransfer, error) {
+	segs, err := d.apply(segments)
+	if err != nil {
+		return nil, err
+	}
+	msgs := split(segs, d.bufsiz)
+	xfers := make([][]spiIOCTransfer, len(msgs))
+	for i, m := range msgs {
+		xfers[i] = ioctlTransfers(m)
+	}
+	return xfers, nil
+}
+
+// ioctlTransfers returns the spi_ioc_transfer array for segments that
+// went through apply.
+func ioctlTransfers(segs []Segment) []spiIOCTransfer {
+	xfers := make([]spiIOCTransfer, len(segs))
+	for i := range segs {
+		s := &segs[i]
+		n, _ := s.len()
+		delay, _ := usecs(s.Delay, 1<<16-1, "delay")
+		wordDelay, _ := usecs(s.WordDelay, 1<<8-1, "word delay")
+		x := &xfers[i]
+		x.txBuf = bufPtr(s.Tx)
+		x.rxBuf = bufPtr(s.Rx)
+		x.len = uint32(n)
+		x.speedHz = s.SpeedHz
+		x.bitsPerWord = s.BitsPerWord
+		x.delayUsecs = uint16(delay)
+		x.wordDelayUsecs = uint8(wordDelay)
+		x.txNbits = s.TxNbits
+		x.rxNbits = s.RxNbits
+		if s.CSChange {
+			x.csChange = 1
+		}
+	}
+	return xfers
+}
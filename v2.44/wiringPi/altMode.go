@@ -0,0 +1,38 @@
+package wiringPi
+
+import "fmt"
+
+// AltMode is the function of a pin, as set in the GPIO function select
+// (GPFSEL) registers: input, output or one of the 6 alternate functions.
+type AltMode int
+
+const (
+	// AltInput : the pin is an input
+	AltInput AltMode = 0
+	// AltOutput : the pin is an output
+	AltOutput AltMode = 1
+	// Alt0 to Alt5 : the alternate functions, e.g. Alt0 for I2C on
+	// BCM_GPIO 2 and 3, Alt5 for PWM on BCM_GPIO 18
+	Alt0 AltMode = 4
+	Alt1 AltMode = 5
+	Alt2 AltMode = 6
+	Alt3 AltMode = 7
+	Alt4 AltMode = 3
+	Alt5 AltMode = 2
+)
+
+func (a AltMode) String() string {
+	switch a {
+	case AltInput:
+		return "input"
+	case AltOutput:
+		return "output"
+	case Alt0, Alt1, Alt2, Alt3:
+		return fmt.Sprintf("ALT%d", a-Alt0)
+	case Alt4:
+		return "ALT4"
+	case Alt5:
+		return "ALT5"
+	}
+	return fmt.Sprintf("AltMode(%d)", int(a))
+}
@@ -0,0 +1,23 @@
+package wiringPi
+
+import "testing"
+
+func TestAltMode_String(t *testing.T) {
+	tests := []struct {
+		alt  AltMode
+		want string
+	}{
+		{AltInput, "input"},
+		{AltOutput, "output"},
+		{Alt0, "ALT0"},
+		{Alt3, "ALT3"},
+		{Alt4, "ALT4"},
+		{Alt5, "ALT5"},
+		{AltMode(9), "AltMode(9)"},
+	}
+	for _, tt := range tests {
+		if got := tt.alt.String(); got != tt.want {
+			t.Errorf("AltMode(%d).String() = %q, want %q", int(tt.alt), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// +build linux
+
+package wiringPi
+
+/*
+#include "wiringPi/wiringPi/wiringPi.h"
+extern void goInterrupt(int);
+
+// wiringPi calls back without telling the pin, one trampoline per pin
+// does. They live out of the file exporting goInterrupt, whose preamble
+// cgo copies in _cgo_export.c.
+#define TRAMPOLINE(n) static void isr##n(void) { goInterrupt(n); }
+TRAMPOLINE(0) TRAMPOLINE(1) TRAMPOLINE(2) TRAMPOLINE(3) TRAMPOLINE(4) TRAMPOLINE(5) TRAMPOLINE(6) TRAMPOLINE(7)
+TRAMPOLINE(8) TRAMPOLINE(9) TRAMPOLINE(10) TRAMPOLINE(11) TRAMPOLINE(12) TRAMPOLINE(13) TRAMPOLINE(14) TRAMPOLINE(15)
+TRAMPOLINE(16) TRAMPOLINE(17) TRAMPOLINE(18) TRAMPOLINE(19) TRAMPOLINE(20) TRAMPOLINE(21) TRAMPOLINE(22) TRAMPOLINE(23)
+TRAMPOLINE(24) TRAMPOLINE(25) TRAMPOLINE(26) TRAMPOLINE(27) TRAMPOLINE(28) TRAMPOLINE(29) TRAMPOLINE(30) TRAMPOLINE(31)
+TRAMPOLINE(32) TRAMPOLINE(33) TRAMPOLINE(34) TRAMPOLINE(35) TRAMPOLINE(36) TRAMPOLINE(37) TRAMPOLINE(38) TRAMPOLINE(39)
+TRAMPOLINE(40) TRAMPOLINE(41) TRAMPOLINE(42) TRAMPOLINE(43) TRAMPOLINE(44) TRAMPOLINE(45) TRAMPOLINE(46) TRAMPOLINE(47)
+TRAMPOLINE(48) TRAMPOLINE(49) TRAMPOLINE(50) TRAMPOLINE(51) TRAMPOLINE(52) TRAMPOLINE(53) TRAMPOLINE(54) TRAMPOLINE(55)
+TRAMPOLINE(56) TRAMPOLINE(57) TRAMPOLINE(58) TRAMPOLINE(59) TRAMPOLINE(60) TRAMPOLINE(61) TRAMPOLINE(62) TRAMPOLINE(63)
+
+static void (*isrs[64])(void) = {
+	isr0, isr1, isr2, isr3, isr4, isr5, isr6, isr7,
+	isr8, isr9, isr10, isr11, isr12, isr13, isr14, isr15,
+	isr16, isr17, isr18, isr19, isr20, isr21, isr22, isr23,
+	isr24, isr25, isr26, isr27, isr28, isr29, isr30, isr31,
+	isr32, isr33, isr34, isr35, isr36, isr37, isr38, isr39,
+	isr40, isr41, isr42, isr43, isr44, isr45, isr46, isr47,
+	isr48, isr49, isr50, isr51, isr52, isr53, isr54, isr55,
+	isr56, isr57, isr58, isr59, isr60, isr61, isr62, isr63,
+};
+
+int mywiringPiISR(int pin) {
+	return wiringPiISR(pin, INT_EDGE_SETUP, isrs[pin]);
+}
+*/
+import "C"
@@ -4,8 +4,14 @@ package wiringPi
 
 /*
 #include "wiringPi/wiringPi/wiringPi.h"
+
+extern int wiringPiReturnCodes ;
 */
 import "C"
+import (
+	"fmt"
+	"sync"
+)
 
 //Mode means wiringPi modes
 type Mode int
@@ -91,18 +97,18 @@ func PinMode(pin int, mode pinMode) {
 	C.pinMode(C.int(pin), C.int(mode))
 }
 
-//pullUpDnControl This sets the pull-up or pull-down resistor
+//PullUpDnControl This sets the pull-up or pull-down resistor
 //mode on the given pin, which should be set as an input.
 //Unlike the Arduino, the BCM2835 has both pull-up an
 //down internal resistors. The parameter pud should be;
-//PUD_OFF, (no pull up/down), PUD_DOWN (pull to ground) or
-//PUD_UP (pull to 3.3v) The internal pull up/down resistors
+//PullOff, (no pull up/down), PullDown (pull to ground) or
+//PullUp (pull to 3.3v) The internal pull up/down resistors
 //have a value of approximately 50KΩ on the Raspberry Pi.
 //This function has no effect on the Raspberry Pi’s GPIO pins
 //when in Sys mode. If you need to activate a pull-up/pull-down,
 //then you can do it with the gpio program in a script before
 //you start your program.
-func pullUpDnControl(pin int, pud PullDest) {
+func PullUpDnControl(pin int, pud PullDest) {
 
 	C.pullUpDnControl(C.int(pin), C.int(pud))
 }
@@ -116,13 +122,13 @@ func DigitalWrite(pin int, value int) {
 	C.digitalWrite(C.int(pin), C.int(value))
 }
 
-//pwmWrite Writes the value to the PWM register for the given pin.
+//PwmWrite Writes the value to the PWM register for the given pin.
 //The Raspberry Pi has one on-board PWM pin, pin 1
 //(BMC_GPIO 18, Phys 12) and the range is 0-1024. Other PWM
 //devices may have other PWM ranges.
 //This function is not able to control the Pi’s on-board
 //PWM when in Sys mode.
-func pwmWrite(pin int, value int) {
+func PwmWrite(pin int, value int) {
 
 	C.pwmWrite(C.int(pin), C.int(value))
 }
@@ -152,13 +158,192 @@ func AnalogWrite(pin int, value int) {
 	C.analogWrite(C.int(pin), C.int(value))
 }
 
+var (
+	setupMu sync.Mutex
+	mode    = UninitialisedPinMode // numbering mode wiringPi is in
+
+	// wiringPiSetup and wiringPiSetupSys only run once, later calls
+	// return 0 whatever happened the first time.
+	memDone, sysDone bool
+	memErr, sysErr   error
+)
+
+// returnCodes makes wiringPi return its failures instead of exiting the
+// process, as the WIRINGPI_CODES environment variable does. The failures
+// wiringPi deems fatal still exit, they are checked in Go beforehand.
+func returnCodes() {
+	C.wiringPiReturnCodes = 1
+}
+
+// isSetup tells whether a setup function succeeded.
+func isSetup() bool {
+	return CurrentMode() != UninitialisedPinMode
+}
+
+// setupMem runs wiringPiSetup, once, and tells whether this call ran it.
+// setupMu must be held.
+func setupMem() (bool, error) {
+	if memDone {
+		return false, memErr
+	}
+	if err := checkBoard(); err != nil {
+		return false, err
+	}
+	returnCodes()
+	ret, errno := C.wiringPiSetup()
+	memDone, memErr = true, check("wiringPiSetup", int(ret), errno)
+	return true, memErr
+}
+
 //Setup Must be called once at the start of your program execution.
 //* Default setup: Initialises the system into wiringPi Pin mode and uses the
 //*	memory mapped hardware directly.
 //* Changed now to revert to "gpio" mode if we're running on a Compute Module.
-func Setup() int {
-	ret := C.wiringPiSetup()
-	return int(ret)
+//It returns ErrNotPi when not running on a Raspberry Pi. Only the first
+//call does anything, later ones return its error and leave the mode as it is.
+func Setup() error {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+
+	first, err := setupMem()
+	if err != nil || !first {
+		return err
+	}
+	var model, rev, mem, maker, overVolted C.int
+	C.piBoardId(&model, &rev, &mem, &maker, &overVolted)
+	if model == C.PI_MODEL_CM || model == C.PI_MODEL_CM3 {
+		mode = GPIOPinMode
+	} else {
+		mode = PinsPinMode
+	}
+	return nil
+}
+
+//SetupGpio is Setup, but pins are then numbered with the Broadcom GPIO
+//numbers (BCM_GPIO) rather than the wiringPi ones.
+func SetupGpio() error {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+
+	if _, err := setupMem(); err != nil {
+		return err
+	}
+	C.wiringPiSetupGpio()
+	mode = GPIOPinMode
+	return nil
+}
+
+//SetupPhys is Setup, but pins are then numbered with the physical pin
+//numbers of the P1 connector.
+func SetupPhys() error {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+
+	if _, err := setupMem(); err != nil {
+		return err
+	}
+	C.wiringPiSetupPhys()
+	mode = PhysPinMode
+	return nil
+}
+
+//SetupSys initialises wiringPi to use the /sys/class/gpio interface,
+//slightly slower but usable as a non-root user, with the Broadcom GPIO
+//numbers. The pins must be exported beforehand (e.g. with the gpio
+//program): the ones exported later are not seen. Only the first call
+//does anything.
+func SetupSys() error {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+
+	if sysDone {
+		return sysErr
+	}
+	if err := checkBoard(); err != nil {
+		return err
+	}
+	returnCodes()
+	ret, errno := C.wiringPiSetupSys()
+	sysDone, sysErr = true, check("wiringPiSetupSys", int(ret), errno)
+	if sysErr == nil {
+		mode = GPIOSysPinMode
+	}
+	return sysErr
+}
+
+//SetupGpioDevice would set wiringPi up on the GPIO character device with
+//the Broadcom GPIO numbers, as wiringPiSetupGpioDevice does from wiringPi 3.
+//wiringPi 2.44 has no such function: it always returns ErrUnsupported.
+//SetupGpio already falls back on /dev/gpiomem when /dev/mem can't be
+//opened, and SetupSys needs no privilege at all.
+func SetupGpioDevice() error {
+	return ErrUnsupported
+}
+
+//CurrentMode returns the mode the last successful setup function put
+//wiringPi in, UninitialisedPinMode before.
+func CurrentMode() Mode {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+
+	return mode
+}
+
+//Numbering returns how the pins passed to wiringPi are numbered.
+func Numbering() PinNumbering {
+	return CurrentMode().Numbering()
+}
+
+// pinRange checks pin can index the 64 entry tables of wiringPi.
+func pinRange(fn string, pin int) error {
+	if pin < 0 || pin > 63 {
+		return &Error{Func: fn, Err: fmt.Errorf("pin must be 0-63 (%d)", pin)}
+	}
+	return nil
+}
+
+//WpiPinToGpio returns the BCM_GPIO pin number of the supplied wiringPi
+//pin, taking the board revision into account. One of the setup functions
+//must have been called first.
+func WpiPinToGpio(wpiPin int) (int, error) {
+	if err := pinRange("wpiPinToGpio", wpiPin); err != nil {
+		return -1, err
+	}
+	if !isSetup() {
+		return -1, ErrNotSetup
+	}
+	gpio := int(C.wpiPinToGpio(C.int(wpiPin)))
+	if gpio < 0 {
+		return -1, &Error{Func: "wpiPinToGpio", Err: fmt.Errorf("pin %d has no GPIO", wpiPin)}
+	}
+	return gpio, nil
+}
+
+//PhysPinToGpio returns the BCM_GPIO pin number of the supplied physical
+//pin on the P1 connector. One of the setup functions must have been
+//called first.
+func PhysPinToGpio(physPin int) (int, error) {
+	if err := pinRange("physPinToGpio", physPin); err != nil {
+		return -1, err
+	}
+	if !isSetup() {
+		return -1, ErrNotSetup
+	}
+	gpio := int(C.physPinToGpio(C.int(physPin)))
+	if gpio < 0 {
+		return -1, &Error{Func: "physPinToGpio", Err: fmt.Errorf("pin %d has no GPIO", physPin)}
+	}
+	return gpio, nil
+}
+
+//PiBoardRev returns the GPIO layout of the board, 1 for the first
+//model A and B (revision 1 and 1.1), 2 for all the others. Some of the
+//BCM_GPIO pins changed number and function between them.
+func PiBoardRev() (int, error) {
+	if err := checkBoard(); err != nil {
+		return 0, err
+	}
+	return int(C.piBoardRev()), nil
 }
 
 // SetPinMode : Sets the mode of a pin to be input, output or PWM output
@@ -1,4 +1,5 @@
 //+build !linux
+
 package wiringPi
 
 //Mode means wiringPi modes
@@ -10,7 +11,7 @@ const (
 	GPIOSysPinMode       Mode = 2 // Initialisation (again), however this time we are using the /sys/class/gpio  interface to the GPIO systems - slightly slower, but always usable as a non-root user, assuming the devices are already exported and setup correctly.
 	PhysPinMode          Mode = 3     //PhysPinMode Initialises the system into Physical Pin mode and uses the 	memory mapped hardware directly
 	PiFacePinMode        Mode = 4
-	UninitialisedPinMode Mode = 5
+	UninitialisedPinMode Mode = -1
 )
 
 type pinMode uint
@@ -50,7 +51,7 @@ const (
 	//PwmModeMS . The mark:space mode is traditional
 	PwmModeMS PWM = 0
 	// PwmModeBal . mode balanced
-	PwmModeBal PWM = 0
+	PwmModeBal PWM = 1
 )
 
 //InterruptLevel means Interrupt levels
@@ -84,18 +85,18 @@ func PinMode(pin int, mode pinMode) {
 //	C.pinMode(C.int(pin), C.int(mode))
 }
 
-//pullUpDnControl This sets the pull-up or pull-down resistor
+//PullUpDnControl This sets the pull-up or pull-down resistor
 //mode on the given pin, which should be set as an input.
 //Unlike the Arduino, the BCM2835 has both pull-up an
 //down internal resistors. The parameter pud should be;
-//PUD_OFF, (no pull up/down), PUD_DOWN (pull to ground) or
-//PUD_UP (pull to 3.3v) The internal pull up/down resistors
+//PullOff, (no pull up/down), PullDown (pull to ground) or
+//PullUp (pull to 3.3v) The internal pull up/down resistors
 //have a value of approximately 50KΩ on the Raspberry Pi.
 //This function has no effect on the Raspberry Pi’s GPIO pins
 //when in Sys mode. If you need to activate a pull-up/pull-down,
 //then you can do it with the gpio program in a script before
 //you start your program.
-func pullUpDnControl(pin int, pud PullDest) {
+func PullUpDnControl(pin int, pud PullDest) {
 
 	//C.pullUpDnControl(C.int(pin), C.int(pud))
 }
@@ -109,13 +110,13 @@ func DigitalWrite(pin int, value int) {
 	//C.digitalWrite(C.int(pin), C.int(value))
 }
 
-//pwmWrite Writes the value to the PWM register for the given pin.
+//PwmWrite Writes the value to the PWM register for the given pin.
 //The Raspberry Pi has one on-board PWM pin, pin 1
 //(BMC_GPIO 18, Phys 12) and the range is 0-1024. Other PWM
 //devices may have other PWM ranges.
 //This function is not able to control the Pi’s on-board
 //PWM when in Sys mode.
-func pwmWrite(pin int, value int) {
+func PwmWrite(pin int, value int) {
 
 	//C.pwmWrite(C.int(pin), C.int(value))
 }
@@ -149,9 +150,10 @@ func AnalogWrite(pin int, value int) {
 //* Default setup: Initialises the system into wiringPi Pin mode and uses the
 //*	memory mapped hardware directly.
 //* Changed now to revert to "gpio" mode if we're running on a Compute Module.
-func Setup() int {
-//	ret := C.wiringPiSetup()
-	return 0
+//It returns ErrNotPi when not running on a Raspberry Pi.
+func Setup() error {
+	//	ret := C.wiringPiSetup()
+	return ErrNotPi
 }
 
 // SetPinMode : Sets the mode of a pin to be input, output or PWM output
@@ -159,3 +161,53 @@ func SetPinMode(pin int, mode pinMode) {
 //	C.pinMode(C.int(pin), C.int(mode))
 }
 
+//SetupGpio is Setup, but pins are then numbered with the Broadcom GPIO
+//numbers (BCM_GPIO) rather than the wiringPi ones.
+func SetupGpio() error {
+	return ErrNotPi
+}
+
+//SetupPhys is Setup, but pins are then numbered with the physical pin
+//numbers of the P1 connector.
+func SetupPhys() error {
+	return ErrNotPi
+}
+
+//SetupSys initialises wiringPi to use the /sys/class/gpio interface.
+func SetupSys() error {
+	return ErrNotPi
+}
+
+//SetupGpioDevice would set wiringPi up on the GPIO character device with
+//the Broadcom GPIO numbers, wiringPi 2.44 has no such function: it always
+//returns ErrUnsupported.
+func SetupGpioDevice() error {
+	return ErrUnsupported
+}
+
+//CurrentMode returns the mode the last successful setup function put
+//wiringPi in, UninitialisedPinMode before.
+func CurrentMode() Mode {
+	return UninitialisedPinMode
+}
+
+//Numbering returns how the pins passed to wiringPi are numbered.
+func Numbering() PinNumbering {
+	return CurrentMode().Numbering()
+}
+
+//WpiPinToGpio returns the BCM_GPIO pin number of the supplied wiringPi pin.
+func WpiPinToGpio(wpiPin int) (int, error) {
+	return -1, ErrNotPi
+}
+
+//PhysPinToGpio returns the BCM_GPIO pin number of the supplied physical
+//pin on the P1 connector.
+func PhysPinToGpio(physPin int) (int, error) {
+	return -1, ErrNotPi
+}
+
+//PiBoardRev returns the GPIO layout of the board, 1 or 2.
+func PiBoardRev() (int, error) {
+	return 0, ErrNotPi
+}
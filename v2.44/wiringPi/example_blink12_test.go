@@ -58,7 +58,10 @@ func Example_blink12() {
 	fmt.Println("==============================")
 	fmt.Println("Connect LEDs up to the first 8 GPIO pins, then pins 11, 10, 13, 12 in")
 	fmt.Println("    that order, then sit back and watch the show!")
-	wiringPi.Setup()
+	if err := wiringPi.Setup(); err != nil {
+		fmt.Println(err)
+		return
+	}
 	for i := 0; i < 14; i++ {
 		wiringPi.SetPinMode(i, wiringPi.OutputPinMode)
 	}
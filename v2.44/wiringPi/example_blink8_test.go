@@ -14,7 +14,10 @@ func Example_blink8() {
 	fmt.Println("==============================")
 	fmt.Println("Connect LEDs to the first 8 GPIO pins and watch ...")
 
-	wiringPi.Setup()
+	if err := wiringPi.Setup(); err != nil {
+		fmt.Println(err)
+		return
+	}
 	for i := 0; i < 8; i++ {
 		wiringPi.SetPinMode(i, wiringPi.OutputPinMode)
 	}
@@ -8,7 +8,10 @@ const LED int = 0
 
 func Example_blink() {
 	fmt.Println("Raspberry Pi blink")
-	wiringPi.Setup()
+	if err := wiringPi.Setup(); err != nil {
+		fmt.Println(err)
+		return
+	}
 	wiringPi.SetPinMode(LED, wiringPi.OutputPinMode)
 
 	for i := 0; i < 10; i++ {
@@ -1,27 +1,48 @@
+//+build linux
+
 package wiringPi_test
 
 import "fmt"
+import "github.com/flyingyizi/go-wiringPi/serial/serialtest"
 import "github.com/flyingyizi/go-wiringPi/v2.44/wiringPi"
 
-// LED Pin - wiringPi pin 0 is BCM_GPIO 17.
-
+// An echo device on a pseudo-terminal stands in for the device on the
+// UART, open "/dev/ttyAMA0" on a Pi instead. The serial functions do not
+// need one of the setup functions.
 func Example_serial() {
-	fd := wiringPi.SerialOpen("/dev/ttyAMA0", 115200)
-	if fd < 0 {
-		fmt.Println("Unable to open serial device: ")
+	d, err := serialtest.Attach(serialtest.Echo{}, nil)
+	if err != nil {
+		fmt.Println(err)
 		return
 	}
+	defer d.Close()
 
-	wiringPi.Setup()
-
-	for i := 0; i < 10; i++ {
-		wiringPi.DigitalWrite(LED, wiringPi.HIGH)
-		wiringPi.Delay(500) //ms
-		wiringPi.DigitalWrite(LED, wiringPi.LOW)
-		wiringPi.Delay(500) //ms
+	fd, err := wiringPi.SerialOpen(d.Name, 115200)
+	if err != nil {
+		fmt.Println("Unable to open serial device:", err)
+		return
 	}
+	defer wiringPi.SerialClose(fd)
 
-	//// Output: Raspberry Pi blink
+	for count := 0; count < 4; count++ {
+		fmt.Printf("Out: %3d:", count)
+		if err := wiringPi.SerialPutchar(fd, uint8(count)); err != nil {
+			fmt.Println(err)
+			return
+		}
+		// waits for the echo, up to 10 seconds
+		c, err := wiringPi.SerialGetchar(fd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf(" -> %3d\n", c)
+	}
+	// Output:
+	// Out:   0: ->   0
+	// Out:   1: ->   1
+	// Out:   2: ->   2
+	// Out:   3: ->   3
 }
 
 /*
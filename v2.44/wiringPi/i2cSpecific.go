@@ -31,40 +31,48 @@ is all that’s needed.
 //and you can use the i2cdetect program to find this
 //out. wiringPiI2CSetup() will work out which revision
 //Raspberry Pi you have and open the appropriate device in /dev.
-//The return value is the standard Linux filehandle,
-//or -1 if any error – in which case, you can consult errno as usual.
+//The return value is the standard Linux filehandle.
 //E.g. the popular MCP23017 GPIO expander is usually device Id 0x20, so this is the number you would pass into wiringPiI2CSetup().
-//For all the following functions, if the return value is
-//negative then an error has happened and you should consult errno.
-func I2CSetup(devID int) int {
-	ret := int(C.wiringPiI2CSetup(C.int(devID)))
-	return ret
+//It returns ErrNotPi when not running on a Raspberry Pi.
+func I2CSetup(devID int) (int, error) {
+	if err := checkBoard(); err != nil {
+		return -1, err
+	}
+	returnCodes()
+	ret, errno := C.wiringPiI2CSetup(C.int(devID))
+	if err := check("wiringPiI2CSetup", int(ret), errno); err != nil {
+		return -1, err
+	}
+	return int(ret), nil
 }
 
 //I2CRead Simple device read. Some devices present data when
 //you read them without having to do any register transactions.
-func I2CRead(fd int) int {
-	ret := int(C.wiringPiI2CRead(C.int(fd)))
-	return ret
+func I2CRead(fd int) (int, error) {
+	ret, errno := C.wiringPiI2CRead(C.int(fd))
+	if err := check("wiringPiI2CRead", int(ret), errno); err != nil {
+		return -1, err
+	}
+	return int(ret), nil
 }
 
 //I2CWrite Simple device write. Some devices accept data this way
 //without needing to access any internal registers.
-func I2CWrite(fd int, data int) int {
-	ret := int(C.wiringPiI2CWrite(C.int(fd), C.int(data)))
-	return ret
+func I2CWrite(fd int, data int) error {
+	ret, errno := C.wiringPiI2CWrite(C.int(fd), C.int(data))
+	return check("wiringPiI2CWrite", int(ret), errno)
 }
 
 //I2CWriteReg8 write an 8  data value into the device
 //register indicated.
-func I2CWriteReg8(fd int, reg int, data int) int {
-	ret := int(C.wiringPiI2CWriteReg8(C.int(fd), C.int(reg), C.int(data)))
-	return ret
+func I2CWriteReg8(fd int, reg int, data int) error {
+	ret, errno := C.wiringPiI2CWriteReg8(C.int(fd), C.int(reg), C.int(data))
+	return check("wiringPiI2CWriteReg8", int(ret), errno)
 }
 
 //I2CWriteReg16 write a 16-bit data value into the device
 //register indicated.
-func I2CWriteReg16(fd int, reg int, data int) int {
-	ret := int(C.wiringPiI2CWriteReg16(C.int(fd), C.int(reg), C.int(data)))
-	return ret
+func I2CWriteReg16(fd int, reg int, data int) error {
+	ret, errno := C.wiringPiI2CWriteReg16(C.int(fd), C.int(reg), C.int(data))
+	return check("wiringPiI2CWriteReg16", int(ret), errno)
 }
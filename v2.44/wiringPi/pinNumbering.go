@@ -0,0 +1,41 @@
+package wiringPi
+
+// PinNumbering tells how the pins passed to wiringPi are numbered.
+type PinNumbering int
+
+const (
+	// NoNumbering : wiringPi is not set up
+	NoNumbering PinNumbering = iota
+	// WiringPiNumbering : the wiringPi pin numbers
+	WiringPiNumbering
+	// BCMNumbering : the Broadcom GPIO numbers (BCM_GPIO)
+	BCMNumbering
+	// PhysNumbering : the physical pin numbers of the P1 connector
+	PhysNumbering
+)
+
+func (n PinNumbering) String() string {
+	switch n {
+	case WiringPiNumbering:
+		return "wiringPi"
+	case BCMNumbering:
+		return "BCM"
+	case PhysNumbering:
+		return "physical"
+	}
+	return "none"
+}
+
+// Numbering returns how pins are numbered in mode m, Sys mode uses the
+// Broadcom GPIO numbers.
+func (m Mode) Numbering() PinNumbering {
+	switch m {
+	case PinsPinMode:
+		return WiringPiNumbering
+	case GPIOPinMode, GPIOSysPinMode:
+		return BCMNumbering
+	case PhysPinMode:
+		return PhysNumbering
+	}
+	return NoNumbering
+}
@@ -0,0 +1,79 @@
+package wiringPi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMode_Numbering(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		want PinNumbering
+	}{
+		{PinsPinMode, WiringPiNumbering},
+		{GPIOPinMode, BCMNumbering},
+		{GPIOSysPinMode, BCMNumbering},
+		{PhysPinMode, PhysNumbering},
+		{PiFacePinMode, NoNumbering},
+		{UninitialisedPinMode, NoNumbering},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.Numbering(); got != tt.want {
+			t.Errorf("Mode(%d).Numbering() = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestSetup_notPi(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cpuinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(s string) { cpuinfo = s }(cpuinfo)
+	cpuinfo = filepath.Join(dir, "cpuinfo")
+	if err := ioutil.WriteFile(cpuinfo, []byte("processor\t: 0\nvendor_id\t: GenuineIntel\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func() error
+	}{
+		{"Setup", Setup},
+		{"SetupGpio", SetupGpio},
+		{"SetupPhys", SetupPhys},
+		{"SetupSys", SetupSys},
+	}
+	for _, tt := range tests {
+		if err := tt.setup(); err != ErrNotPi {
+			t.Errorf("%s() = %v, want ErrNotPi", tt.name, err)
+		}
+	}
+	if err := SetupGpioDevice(); err != ErrUnsupported {
+		t.Errorf("SetupGpioDevice() = %v, want ErrUnsupported", err)
+	}
+	if n := Numbering(); n != NoNumbering {
+		t.Errorf("Numbering() = %v, want %v", n, NoNumbering)
+	}
+	if _, err := WpiPinToGpio(0); err == nil {
+		t.Error("WpiPinToGpio() before setup succeeded")
+	}
+	if err := PinModeAlt(18, Alt5); err == nil {
+		t.Error("PinModeAlt() before setup succeeded")
+	}
+	if _, err := GetAlt(18); err == nil {
+		t.Error("GetAlt() before setup succeeded")
+	}
+	if err := GpioClockSet(4, 1000); err == nil {
+		t.Error("GpioClockSet() before setup succeeded")
+	}
+	if _, err := GetAlt(64); err == nil || err == ErrNotSetup {
+		t.Errorf("GetAlt(64) = %v, want a pin range error", err)
+	}
+	if _, err := PiBoardRev(); err != ErrNotPi {
+		t.Errorf("PiBoardRev() = %v, want ErrNotPi", err)
+	}
+}
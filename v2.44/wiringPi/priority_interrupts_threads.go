@@ -4,15 +4,19 @@ package wiringPi
 
 /*
 #include "wiringPi/wiringPi/wiringPi.h"
-extern void goInterruptCB();
-static void interruptCB() {
-  goInterruptCB();
-}
-static void mywiringPiISR(int pin,  int edgeType) {
-	wiringPiISR(pin, edgeType, &interruptCB);
-}
+extern void goInterrupt(int);
+extern int mywiringPiISR(int pin);
 */
 import "C"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
 
 /*
 WiringPi provides some helper functions to allow you
@@ -35,15 +39,11 @@ Program or Thread Priority
 //priority 2 and it will have the same effect as setting
 //one to 10 and the other to 90 (as long as no other
 //programs are running with elevated priorities)
-//The return value is 0 for success and -1 for error.
-//If an error is returned, the program should then
-//consult the errno global variable, as per the usual conventions.
 //Note: Only programs running as root can change their
-//priority. If called from a non-root program then nothing happens.
-func PiHiPri(priority int) int {
-
-	ret := int(C.piHiPri(C.int(priority)))
-	return ret
+//priority. If called from a non-root program then it fails with EPERM.
+func PiHiPri(priority int) error {
+	ret, errno := C.piHiPri(C.int(priority))
+	return check("piHiPri", int(ret), errno)
 }
 
 /*
@@ -63,9 +63,7 @@ use wiringPiISR() function below.
 //happen on that pin and your program will be stalled.
 //The timeOut parameter is given in milliseconds, or
 // can be -1 which means to wait forever.
-// The return value is -1 if an error occurred (and errno
-// will be set appropriately), 0 if it timed out, or 1 on a
-// successful interrupt event.
+// It returns ErrTimeout if it timed out.
 // Before you call waitForInterrupt, you must first initialise
 // the GPIO pin and at present the only way to do this is
 // to use the gpio program, either in a script, or using
@@ -74,22 +72,72 @@ use wiringPiISR() function below.
 // GPIO pin 0, so to setup the hardware, we need to run:
 // gpio edge 0 falling
 // before running the program.
-func WaitForInterrupt(pin int, timeOut int) int {
-	ret := int(C.waitForInterrupt(C.int(pin), C.int(timeOut)))
-	return ret
+func WaitForInterrupt(pin int, timeOut int) error {
+	if err := pinRange("waitForInterrupt", pin); err != nil {
+		return err
+	}
+	ret, errno := C.waitForInterrupt(C.int(pin), C.int(timeOut))
+	switch ret {
+	case -2:
+		return &Error{Func: "waitForInterrupt", Err: fmt.Errorf("pin %d is not exported", pin)}
+	case 0:
+		return ErrTimeout
+	}
+	return check("waitForInterrupt", int(ret), errno)
+}
+
+// Event is an interrupt delivered on a channel by ISRChan.
+type Event struct {
+	Pin  int
+	Edge InterruptLevel // IntEdgeRising or IntEdgeFalling
+	Time time.Time
+}
+
+type isrHandler struct {
+	edge InterruptLevel
+	f    func()
+	c    chan<- Event
 }
 
-var fInterruptHolder func()
+var (
+	isrMu      sync.Mutex // serializes the registrations
+	isrStarted [64]bool   // wiringPiISR was called, its thread runs for good
+
+	handlersMu sync.Mutex
+	handlers   [64]*isrHandler
+)
+
+//export goInterrupt
+func goInterrupt(pin C.int) {
+	handlersMu.Lock()
+	h := handlers[pin]
+	handlersMu.Unlock()
 
-//export goInterruptCB
-func goInterruptCB() {
-	fInterruptHolder()
+	switch {
+	case h == nil:
+		// unregistered, the wiringPi thread can't be stopped
+	case h.f != nil:
+		h.f()
+	default:
+		e := Event{Pin: int(pin), Edge: h.edge, Time: time.Now()}
+		if e.Edge != IntEdgeRising && e.Edge != IntEdgeFalling {
+			// the level right after it tells the edge, as far as it lasts
+			e.Edge = IntEdgeFalling
+			if C.digitalRead(pin) != 0 {
+				e.Edge = IntEdgeRising
+			}
+		}
+		select {
+		case h.c <- e:
+		default:
+		}
+	}
 }
 
 // ISR function registers a function to received interrupts on
-// the specified pin. The edgeType parameter is either
-// INT_EDGE_FALLING, INT_EDGE_RISING, INT_EDGE_BOTH or
-// INT_EDGE_SETUP. If it is INT_EDGE_SETUP then no initialisation
+// the specified pin. The edge parameter is either
+// IntEdgeFalling, IntEdgeRising, IntEdgeBoth or
+// IntEdgeSetup. If it is IntEdgeSetup then no initialisation
 // of the pin will happen – it’s assumed that you have already
 // setup the pin elsewhere (e.g. with the gpio program), but if
 // you specify one of the other types, then the pin will be exported
@@ -108,14 +156,128 @@ func goInterruptCB() {
 //  they will be ignored)
 // This function is run at a high priority (if the program is
 // run using sudo, or as root) and executes concurrently with
-// the main program. It has full access to all the global
-// variables, open file handles and so on.
-// See the isr.c example program for more details on how to
-// use this feature.
-//todo
-func ISR(pin int, edgeType int, cbfun func()) {
-	fInterruptHolder = cbfun
-	C.mywiringPiISR(C.int(pin), C.int(edgeType))
+// the main program, on a thread of its own for each pin.
+// Registering a pin again replaces its function, UnregisterISR
+// removes it.
+// The failures wiringPi deems fatal, a pin out of 0-63, no setup or a
+// pin that can't be exported, are returned before calling it.
+func ISR(pin int, edge InterruptLevel, f func()) error {
+	if f == nil {
+		return &Error{Func: "wiringPiISR", Err: errors.New("nil function")}
+	}
+	return registerISR(pin, &isrHandler{edge: edge, f: f})
+}
+
+// ISRChan is ISR sending the interrupts of the pin on c instead of
+// calling a function. It does not block sending to c: the caller must
+// make sure c has room enough, the interrupts are dropped otherwise.
+// The edge of the events is the one registered, or for IntEdgeBoth and
+// IntEdgeSetup the one the level of the pin tells when it is read.
+func ISRChan(pin int, edge InterruptLevel, c chan<- Event) error {
+	if c == nil {
+		return &Error{Func: "wiringPiISR", Err: errors.New("nil channel")}
+	}
+	return registerISR(pin, &isrHandler{edge: edge, c: c})
+}
+
+// UnregisterISR stops the delivery of the interrupts of the pin
+// registered with ISR or ISRChan. The pin stays set up for its edge.
+func UnregisterISR(pin int) {
+	if pin < 0 || pin > 63 {
+		return
+	}
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+
+	handlers[pin] = nil
+}
+
+func registerISR(pin int, h *isrHandler) error {
+	if err := pinRange("wiringPiISR", pin); err != nil {
+		return err
+	}
+	if !isSetup() {
+		return ErrNotSetup
+	}
+	isrMu.Lock()
+	defer isrMu.Unlock()
+
+	gpio, err := bcmGpio(pin)
+	if err != nil {
+		return err
+	}
+	if h.edge != IntEdgeSetup {
+		if err := gpioEdge(gpio, h.edge); err != nil {
+			return err
+		}
+	}
+	if isrStarted[pin] {
+		handlersMu.Lock()
+		handlers[pin] = h
+		handlersMu.Unlock()
+		return nil
+	}
+
+	// wiringPi exits when it can't open the pin
+	name := "/sys/class/gpio/gpio" + strconv.Itoa(gpio) + "/value"
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return &Error{Func: "wiringPiISR", Err: err}
+	}
+	f.Close()
+
+	handlersMu.Lock()
+	handlers[pin] = h
+	handlersMu.Unlock()
+	ret, errno := C.mywiringPiISR(C.int(pin))
+	if err := check("wiringPiISR", int(ret), errno); err != nil {
+		UnregisterISR(pin)
+		return err
+	}
+	isrStarted[pin] = true
+	return nil
+}
+
+// bcmGpio returns the BCM_GPIO number of pin in the current mode.
+func bcmGpio(pin int) (int, error) {
+	switch CurrentMode() {
+	case PinsPinMode:
+		return WpiPinToGpio(pin)
+	case PhysPinMode:
+		return PhysPinToGpio(pin)
+	}
+	return pin, nil
+}
+
+// gpioEdge exports the pin and sets its edge with the gpio program, as
+// wiringPiISR does.
+func gpioEdge(gpio int, edge InterruptLevel) error {
+	var mode string
+	switch edge {
+	case IntEdgeFalling:
+		mode = "falling"
+	case IntEdgeRising:
+		mode = "rising"
+	case IntEdgeBoth:
+		mode = "both"
+	default:
+		return &Error{Func: "wiringPiISR", Err: fmt.Errorf("invalid edge %d", edge)}
+	}
+	prog := ""
+	for _, p := range []string{"/usr/local/bin/gpio", "/usr/bin/gpio"} {
+		if _, err := exec.LookPath(p); err == nil {
+			prog = p
+			break
+		}
+	}
+	if prog == "" {
+		return &Error{Func: "wiringPiISR", Err: errors.New("can't find gpio program")}
+	}
+	out, err := exec.Command(prog, "edge", strconv.Itoa(gpio), mode).CombinedOutput()
+	if err != nil {
+		return &Error{Func: "wiringPiISR", Err: fmt.Errorf("gpio edge %d %s: %v: %s", gpio, mode, err, out)}
+	}
+	return nil
 }
 
 /*
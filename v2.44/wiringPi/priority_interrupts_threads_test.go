@@ -0,0 +1,61 @@
+//+build linux
+
+package wiringPi
+
+import "testing"
+
+func TestISR_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		err  func() error
+		want error // nil for any error
+	}{
+		{"nil function", func() error { return ISR(0, IntEdgeRising, nil) }, nil},
+		{"nil channel", func() error { return ISRChan(0, IntEdgeRising, nil) }, nil},
+		{"pin", func() error { return ISR(64, IntEdgeRising, func() {}) }, nil},
+		{"not setup", func() error { return ISR(0, IntEdgeRising, func() {}) }, ErrNotSetup},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err()
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if tt.want != nil && err != tt.want {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_goInterrupt(t *testing.T) {
+	defer UnregisterISR(3)
+	defer UnregisterISR(5)
+
+	var n3 int
+	c := make(chan Event, 1)
+	handlers[3] = &isrHandler{edge: IntEdgeRising, f: func() { n3++ }}
+	handlers[5] = &isrHandler{edge: IntEdgeFalling, c: c}
+
+	goInterrupt(3)
+	goInterrupt(5)
+	goInterrupt(5) // dropped, c is full
+	if n3 != 1 {
+		t.Errorf("pin 3 function called %d times, want 1", n3)
+	}
+	e := <-c
+	if e.Pin != 5 || e.Edge != IntEdgeFalling || e.Time.IsZero() {
+		t.Errorf("event = %+v, want pin 5 falling", e)
+	}
+	select {
+	case e := <-c:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+
+	UnregisterISR(3)
+	goInterrupt(3)
+	if n3 != 1 {
+		t.Errorf("pin 3 function called after UnregisterISR")
+	}
+}
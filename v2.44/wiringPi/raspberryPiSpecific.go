@@ -6,58 +6,153 @@ package wiringPi
 #include "wiringPi/wiringPi/wiringPi.h"
 */
 import "C"
+import "fmt"
 
 //These functions are not part of the core wiringPi set,
 //but act specifically on the Raspberry Pi hardware itself.
 //Some external hardware driver modules may provide some of
 //this functionality though.
 
-//digitalWriteByte writes the 8-bit byte supplied to the first
-//8 GPIO pins. It’s the fastest way to set all 8 bits
-//at once to a particular value, although it still
-//takes two write operations to the Pi’s GPIO hardware.
-func digitalWriteByte(value int) {
-
+//DigitalWriteByte writes the 8-bit byte supplied to the first
+//8 GPIO pins, wiringPi pins 0 to 7 (bit 0 to pin 0). It’s the
+//fastest way to set all 8 bits at once to a particular value,
+//although it still takes two write operations to the Pi’s GPIO
+//hardware. It returns ErrNotSetup before one of the setup functions.
+func DigitalWriteByte(value byte) error {
+	if !isSetup() {
+		return ErrNotSetup
+	}
 	C.digitalWriteByte(C.int(value))
+	return nil
 }
 
-/*
+//DigitalReadByte reads the first 8 GPIO pins, wiringPi pins 0 to 7.
+//Note that wiringPi 2.44 returns pin 0 in bit 7 and pin 7 in bit 0,
+//the reverse of DigitalWriteByte. It returns ErrNotSetup before one of
+//the setup functions.
+func DigitalReadByte() (byte, error) {
+	if !isSetup() {
+		return 0, ErrNotSetup
+	}
+	return byte(C.digitalReadByte()), nil
+}
 
-    pwmSetMode (int mode) ;
-The PWM generator can run in 2 modes – “balanced”
-and “mark:space”. The mark:space mode is traditional,
-however the default mode in the Pi is “balanced”.
-You can switch modes by supplying the parameter: PWM_MODE_BAL or PWM_MODE_MS.
-
-    pwmSetRange (unsigned int range) ;
-This sets the range register in the PWM generator.
-The default is 1024.
-
-    pwmSetClock (int divisor) ;
-This sets the divisor for the PWM clock.
-Note: The PWM control functions can not be used when
-in Sys mode. To understand more about the PWM system,
-you’ll need to read the Broadcom ARM peripherals manual.
-
-    piBoardRev (void) ;
-This returns the board revision of the Raspberry Pi.
-It will be either 1 or 2. Some of the BCM_GPIO pins
-changed number and function when moving from board
-revision 1 to 2, so if you are using BCM_GPIO pin numbers,
-then you need to be aware of the differences.
-
-    wpiPinToGpio (int wPiPin) ;
-
-This returns the BCM_GPIO pin number of the supplied
-wiringPi pin. It takes the board revision into account.
-
-    physPinToGpio (int physPin) ;
-This returns the BCM_GPIO pin number of the supplied physical
-pin on the P1 connector.
-
-    setPadDrive (int group, int value) ;
-This sets the “strength” of the pad drivers for
-a particular group of pins. There are 3 groups of
-pins and the drive strength is from 0 to 7. Do not use
-this unless you know what you are doing.
-*/
+//DigitalWriteByte2 writes the 8-bit byte supplied to BCM_GPIO pins 20
+//to 27 (bit 0 to pin 20). It is marginally faster than DigitalWriteByte
+//as these are consecutive, but they overlap with its pins.
+func DigitalWriteByte2(value byte) error {
+	if !isSetup() {
+		return ErrNotSetup
+	}
+	C.digitalWriteByte2(C.int(value))
+	return nil
+}
+
+//DigitalReadByte2 reads BCM_GPIO pins 20 to 27, pin 20 in bit 0 except
+//in Sys mode where, as with DigitalReadByte, it is in bit 7.
+func DigitalReadByte2() (byte, error) {
+	if !isSetup() {
+		return 0, ErrNotSetup
+	}
+	return byte(C.digitalReadByte2()), nil
+}
+
+//PwmSetMode : The PWM generator can run in 2 modes – “balanced”
+//and “mark:space”. The mark:space mode is traditional,
+//however the default mode in the Pi is “balanced”.
+//You can switch modes by supplying the parameter: PwmModeBal or PwmModeMS.
+//
+//The PWM control functions can not be used when in Sys mode,
+//they do nothing then, as before one of the setup functions.
+func PwmSetMode(mode PWM) {
+	C.pwmSetMode(C.int(mode))
+}
+
+//PwmSetRange sets the range register in the PWM generator.
+//The default is 1024.
+func PwmSetRange(r uint) {
+	C.pwmSetRange(C.uint(r))
+}
+
+//PwmSetClock sets the divisor for the PWM clock, from 19.2MHz.
+//Only the 12 low bits of divisor are used. To understand more
+//about the PWM system, you’ll need to read the Broadcom ARM
+//peripherals manual.
+func PwmSetClock(divisor int) {
+	C.pwmSetClock(C.int(divisor))
+}
+
+//PwmToneWrite outputs the given frequency on the Pi’s PWM pin,
+//0 turns it off. It changes the range of the PWM generator.
+func PwmToneWrite(pin int, freq int) {
+	C.pwmToneWrite(C.int(pin), C.int(freq))
+}
+
+//GpioClockSet sets the frequency in Hz on a GPIO clock pin,
+//e.g. wiringPi pin 7 (BCM_GPIO 4), set with GPIOClockPinMode. The pin
+//must be one of BCM_GPIO 4, 5, 6, 20, 21, 32, 34, 42, 43 or 44.
+//It does nothing in Sys mode, and returns ErrNotSetup before one of the
+//setup functions.
+func GpioClockSet(pin int, freq int) error {
+	if freq <= 0 {
+		return &Error{Func: "gpioClockSet", Err: fmt.Errorf("frequency must be positive (%d)", freq)}
+	}
+	gpio, err := pinGpio("gpioClockSet", pin)
+	if err != nil {
+		return err
+	}
+	if !clockGpio[gpio] {
+		return &Error{Func: "gpioClockSet", Err: fmt.Errorf("pin %d (BCM_GPIO %d) is not a clock pin", pin, gpio)}
+	}
+	C.gpioClockSet(C.int(pin), C.int(freq))
+	return nil
+}
+
+// clockGpio are the BCM_GPIO pins with a general purpose clock.
+var clockGpio = map[int]bool{4: true, 5: true, 6: true, 20: true, 21: true, 32: true, 34: true, 42: true, 43: true, 44: true}
+
+//GetAlt returns the function of the pin, from its function select
+//(ALT) bits. It returns AltInput in Sys mode, and ErrNotSetup before one
+//of the setup functions.
+func GetAlt(pin int) (AltMode, error) {
+	if _, err := pinGpio("getAlt", pin); err != nil {
+		return AltInput, err
+	}
+	return AltMode(C.getAlt(C.int(pin))), nil
+}
+
+//PinModeAlt sets the function of the pin, any of the ALT modes; unlike
+//SetPinMode it does not set up the clock or the PWM behind it. It does
+//nothing in Sys mode, and returns ErrNotSetup before one of the setup
+//functions.
+func PinModeAlt(pin int, alt AltMode) error {
+	if alt < 0 || alt > 7 {
+		return &Error{Func: "pinModeAlt", Err: fmt.Errorf("invalid mode %v", alt)}
+	}
+	if _, err := pinGpio("pinModeAlt", pin); err != nil {
+		return err
+	}
+	C.pinModeAlt(C.int(pin), C.int(alt))
+	return nil
+}
+
+// pinGpio checks that pin is a pin of the current mode with a GPIO, and
+// returns its BCM_GPIO number; wiringPi indexes its tables with it
+// unchecked.
+func pinGpio(fn string, pin int) (int, error) {
+	if err := pinRange(fn, pin); err != nil {
+		return -1, err
+	}
+	if !isSetup() {
+		return -1, ErrNotSetup
+	}
+	return bcmGpio(pin)
+}
+
+//SetPadDrive sets the “strength” of the pad drivers for
+//a particular group of pins. There are 3 groups of
+//pins and the drive strength is from 0 to 7. Do not use
+//this unless you know what you are doing.
+func SetPadDrive(group int, value int) {
+	C.setPadDrive(C.int(group), C.int(value))
+}
@@ -0,0 +1,66 @@
+//+build !linux
+
+package wiringPi
+
+//DigitalWriteByte writes the 8-bit byte supplied to the first
+//8 GPIO pins, wiringPi pins 0 to 7.
+func DigitalWriteByte(value byte) error {
+	return ErrNotPi
+}
+
+//DigitalReadByte reads the first 8 GPIO pins, wiringPi pins 0 to 7.
+func DigitalReadByte() (byte, error) {
+	return 0, ErrNotPi
+}
+
+//DigitalWriteByte2 writes the 8-bit byte supplied to BCM_GPIO pins 20
+//to 27.
+func DigitalWriteByte2(value byte) error {
+	return ErrNotPi
+}
+
+//DigitalReadByte2 reads BCM_GPIO pins 20 to 27.
+func DigitalReadByte2() (byte, error) {
+	return 0, ErrNotPi
+}
+
+//PwmSetMode selects the “balanced” or “mark:space” PWM mode.
+func PwmSetMode(mode PWM) {
+	//	C.pwmSetMode(C.int(mode))
+}
+
+//PwmSetRange sets the range register in the PWM generator.
+func PwmSetRange(r uint) {
+	//	C.pwmSetRange(C.uint(r))
+}
+
+//PwmSetClock sets the divisor for the PWM clock.
+func PwmSetClock(divisor int) {
+	//	C.pwmSetClock(C.int(divisor))
+}
+
+//PwmToneWrite outputs the given frequency on the Pi’s PWM pin.
+func PwmToneWrite(pin int, freq int) {
+	//	C.pwmToneWrite(C.int(pin), C.int(freq))
+}
+
+//GpioClockSet sets the frequency in Hz on a GPIO clock pin.
+func GpioClockSet(pin int, freq int) error {
+	return ErrNotPi
+}
+
+//GetAlt returns the function of the pin.
+func GetAlt(pin int) (AltMode, error) {
+	return AltInput, ErrNotPi
+}
+
+//PinModeAlt sets the function of the pin, any of the ALT modes.
+func PinModeAlt(pin int, alt AltMode) error {
+	return ErrNotPi
+}
+
+//SetPadDrive sets the “strength” of the pad drivers for
+//a particular group of pins.
+func SetPadDrive(group int, value int) {
+	//	C.setPadDrive(C.int(group), C.int(value))
+}
@@ -10,9 +10,6 @@ package wiringPi
 static int myserialOpen(char* device, int baud) {
 	return serialOpen((const char*)device, (const int)baud);
 }
-static void myserialPrintf(int fd, char* msg) {
-   serialPrintf(fd, msg);
-}
 
 */
 import "C"
@@ -32,57 +29,70 @@ It can use the on-board serial port, or any USB serial device
 //the baud rate. It sets the port into “raw” mode (character
 //at a time and no translations), and sets the read
 //timeout to 10 seconds. The return value is the file
-//descriptor or -1 for any error, in which case errno
-//will be set as appropriate.
-//todo
-func SerialOpen(device string, baud int) int {
+//descriptor.
+func SerialOpen(device string, baud int) (int, error) {
 	v := C.CString(device)
-	ret := int(C.myserialOpen((v), C.int(baud)))
-	C.free(unsafe.Pointer(v))
-	return ret
+	defer C.free(unsafe.Pointer(v))
+	ret, errno := C.myserialOpen((v), C.int(baud))
+	if ret == -2 {
+		return -1, &Error{Func: "serialOpen", Err: fmt.Errorf("unsupported baud rate %d", baud)}
+	}
+	if err := check("serialOpen", int(ret), errno); err != nil {
+		return -1, err
+	}
+	return int(ret), nil
 }
 
 //SerialClose Closes the device identified by the file descriptor given.
-func SerialClose(fd int) {
-	C.serialClose(C.int(fd))
+func SerialClose(fd int) error {
+	_, errno := C.serialClose(C.int(fd))
+	return checkErrno("serialClose", errno)
 }
 
 //SerialPutchar Sends the single byte to the serial device
 //identified by the given file descriptor.
-func SerialPutchar(fd int, c uint8) {
-	C.serialPutchar(C.int(fd), C.uchar(c))
+func SerialPutchar(fd int, c uint8) error {
+	_, errno := C.serialPutchar(C.int(fd), C.uchar(c))
+	return checkErrno("serialPutchar", errno)
 }
 
 //SerialPuts Sends the nul-terminated string to the serial device identified
 //by the given file descriptor.
-func SerialPuts(fd int, s string) {
-
+func SerialPuts(fd int, s string) error {
 	v := C.CString(s)
-	C.serialPuts(C.int(fd), v)
-	C.free(unsafe.Pointer(v))
+	defer C.free(unsafe.Pointer(v))
+	_, errno := C.serialPuts(C.int(fd), v)
+	return checkErrno("serialPuts", errno)
 }
 
 //SerialPrintf Emulates the system printf function to the serial device.
-func SerialPrintf(fd int, format string, a ...interface{}) {
-	message := fmt.Sprintf(format, a)
-	msg := C.CString(message)
-	C.myserialPrintf(C.int(fd), msg)
-	C.free(unsafe.Pointer(msg))
+func SerialPrintf(fd int, format string, a ...interface{}) error {
+	// formatted in Go, serialPrintf would take the message as a format
+	return SerialPuts(fd, fmt.Sprintf(format, a...))
 }
 
-//SerialDataAvail Returns the number of characters available for reading, or -1 for
-//any error condition, in which case errno will be set appropriately.
-func SerialDataAvail(fd int) int {
-	ret := int(C.serialDataAvail(C.int(fd)))
-	return ret
+//SerialDataAvail Returns the number of characters available for reading.
+func SerialDataAvail(fd int) (int, error) {
+	ret, errno := C.serialDataAvail(C.int(fd))
+	if err := check("serialDataAvail", int(ret), errno); err != nil {
+		return 0, err
+	}
+	return int(ret), nil
 }
 
 //SerialGetchar Returns the next character available on the serial device.
 //This call will block for up to 10 seconds if no data is
-//available (when it will return -1)/*
-func SerialGetchar(fd int) int {
-	ret := int(C.serialGetchar(C.int(fd)))
-	return ret
+//available, it then returns ErrTimeout.
+func SerialGetchar(fd int) (byte, error) {
+	ret, errno := C.serialGetchar(C.int(fd))
+	if ret < 0 && errno == nil {
+		// read(2) returned 0
+		return 0, ErrTimeout
+	}
+	if err := check("serialGetchar", int(ret), errno); err != nil {
+		return 0, err
+	}
+	return byte(ret), nil
 }
 
 //SerialFlush discards all data received, or waiting to be send down the given device.
@@ -92,8 +102,9 @@ func SerialGetchar(fd int) int {
 //binary data where the serialPutchar() or serialPuts() function may not
 //be the most appropriate function to use, in which case, you can use write()
 //to send the data.
-func SerialFlush(fd int) {
-	C.serialFlush(C.int(fd))
+func SerialFlush(fd int) error {
+	_, errno := C.serialFlush(C.int(fd))
+	return checkErrno("serialFlush", errno)
 }
 
 /*
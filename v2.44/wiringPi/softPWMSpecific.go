@@ -9,6 +9,10 @@ package wiringPi
 
 */
 import "C"
+import (
+	"fmt"
+	"syscall"
+)
 
 /*
 Software PWM Library
@@ -52,11 +56,18 @@ Notes
 //and the pin numbering will be that of the wiringPiSetup() function
 //you used. Use 100 for the pwmRange, then the value can be anything
 //from 0 (off) to 100 (fully on) for the given pin.
-//The return value is 0 for success. Anything else and you should check
-//the global errno variable to see what went wrong.
-func SoftPwmCreate(pin int, initialValue int, pwmRange int) int {
-	ret := C.softPwmCreate(C.int(pin), C.int(initialValue), C.int(pwmRange))
-	return int(ret)
+func SoftPwmCreate(pin int, initialValue int, pwmRange int) error {
+	ret, errno := C.softPwmCreate(C.int(pin), C.int(initialValue), C.int(pwmRange))
+	switch {
+	case ret < 0 && errno == nil:
+		return &Error{Func: "softPwmCreate", Err: fmt.Errorf("pin %d out of range or already in use, or range %d invalid", pin, pwmRange)}
+	case ret < 0:
+		return fail("softPwmCreate", errno)
+	case ret > 0:
+		// the error of pthread_create
+		return fail("softPwmCreate", syscall.Errno(ret))
+	}
+	return nil
 }
 
 //SoftPwmWrite updates the PWM value on the given pin. The value is checked
@@ -40,21 +40,23 @@ Functions available:
 //SPISetup is the way to initialise a channel (The Pi has 2 channels; 0 and 1).
 //The speed parameter is an integer in the range 500,000 through
 //32,000,000 and represents the SPI clock speed in Hz.
-//The returned value is the Linux file-descriptor for the device,
-//or -1 on error. If an error has happened, you may use the
-//standard errno global variable to see why.
-func SPISetup(channel int, speed int) int {
-	ret := int(C.wiringPiSPISetup(C.int(channel), C.int(speed)))
-	return ret
+//The returned value is the Linux file-descriptor for the device.
+func SPISetup(channel int, speed int) (int, error) {
+	returnCodes()
+	ret, errno := C.wiringPiSPISetup(C.int(channel), C.int(speed))
+	if err := check("wiringPiSPISetup", int(ret), errno); err != nil {
+		return -1, err
+	}
+	return int(ret), nil
 }
 
 //SPIDataRW performs a simultaneous write/read transaction over the selected
 //SPI bus. Data that was in your buffer is overwritten by data
 //returned from the SPI bus.
-func SPIDataRW(channel int, data string, len int) int {
-	msg := C.CString(data)
-	d := (*C.uchar)(unsafe.Pointer(msg))
-	ret := C.wiringPiSPIDataRW(C.int(channel), d, C.int(len))
-	C.free(unsafe.Pointer(msg))
-	return int(ret)
+func SPIDataRW(channel int, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	ret, errno := C.wiringPiSPIDataRW(C.int(channel), (*C.uchar)(unsafe.Pointer(&data[0])), C.int(len(data)))
+	return check("wiringPiSPIDataRW", int(ret), errno)
 }
@@ -0,0 +1,102 @@
+package wiringPi
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Error records a failed wiringPi call.
+type Error struct {
+	Func string // C function, e.g. "wiringPiI2CSetup"
+	Err  error  // errno as a syscall.Errno, or what went wrong
+}
+
+func (e *Error) Error() string {
+	return "wiringPi: " + e.Func + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error { return e.Err }
+
+var (
+	// ErrNotPi is returned by the setup functions when /proc/cpuinfo does
+	// not describe a board wiringPi 2.44 knows, on which wiringPi would
+	// exit the process.
+	ErrNotPi = errors.New("wiringPi: /proc/cpuinfo does not describe a Raspberry Pi known to wiringPi 2.44")
+	// ErrNotSetup is returned by the calls needing one of the setup
+	// functions to be called first.
+	ErrNotSetup = errors.New("wiringPi: not set up")
+	// ErrUnsupported is returned by the functions wiringPi 2.44 lacks.
+	ErrUnsupported = errors.New("wiringPi: not supported by wiringPi 2.44")
+	// ErrTimeout is returned when the time given to a call ran out.
+	ErrTimeout = errors.New("wiringPi: timeout")
+
+	errFailed = errors.New("failed")
+)
+
+// check turns the result of a C call failing with a negative value, and
+// the errno captured with it, into an error.
+func check(fn string, ret int, errno error) error {
+	if ret >= 0 {
+		return nil
+	}
+	return fail(fn, errno)
+}
+
+// checkErrno returns the error of a C call that has no result, which
+// failed when it set errno.
+func checkErrno(fn string, errno error) error {
+	if errno == nil || errno == syscall.Errno(0) {
+		return nil
+	}
+	return &Error{Func: fn, Err: errno}
+}
+
+// fail returns the error of the C call fn, errno may be nil.
+func fail(fn string, errno error) error {
+	if errno == nil || errno == syscall.Errno(0) {
+		errno = errFailed
+	}
+	return &Error{Func: fn, Err: errno}
+}
+
+var cpuinfo = "/proc/cpuinfo"
+
+// checkBoard fails where piGpioLayout would exit the process: it needs a
+// "Hardware" line naming a BCM2708, BCM2709 or BCM2835, and a
+// "Revision" line with a hex revision of 4 digits or more.
+func checkBoard() error {
+	f, err := os.Open(cpuinfo)
+	if err != nil {
+		return ErrNotPi
+	}
+	defer f.Close()
+
+	var hardware, revision string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if hardware == "" && strings.HasPrefix(line, "Hardware") {
+			hardware = line
+		}
+		if revision == "" && strings.HasPrefix(line, "Revision") {
+			revision = line
+		}
+	}
+	if !strings.Contains(hardware, "BCM2708") && !strings.Contains(hardware, "BCM2709") &&
+		!strings.Contains(hardware, "BCM2835") {
+		return ErrNotPi
+	}
+	i := strings.IndexByte(revision, ':')
+	if i < 0 {
+		return ErrNotPi
+	}
+	rev := strings.TrimSpace(revision[i+1:])
+	if len(rev) < 4 || !strings.ContainsAny(rev[:1], "0123456789abcdefABCDEF") {
+		return ErrNotPi
+	}
+	return nil
+}
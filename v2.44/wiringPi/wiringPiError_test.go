@@ -0,0 +1,84 @@
+package wiringPi
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func Test_checkBoard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cpuinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(s string) { cpuinfo = s }(cpuinfo)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"pi3", "processor\t: 0\nHardware\t: BCM2835\nRevision\t: a02082\nSerial\t\t: 00000000\n", false},
+		{"pi1", "Hardware\t: BCM2708\nRevision\t: 000e\n", false},
+		{"pi2", "Hardware\t: BCM2709\nRevision\t: a21041\n", false},
+		{"x86", "processor\t: 0\nvendor_id\t: GenuineIntel\n", true},
+		{"other soc", "Hardware\t: sun8i\nRevision\t: 0000\n", true},
+		{"no revision", "Hardware\t: BCM2835\n", true},
+		{"no colon", "Hardware\t: BCM2835\nRevision a02082\n", true},
+		{"short revision", "Hardware\t: BCM2835\nRevision\t: 0e\n", true},
+		{"missing file", "", true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpuinfo = filepath.Join(dir, string(rune('a'+i)))
+			if tt.content != "" {
+				if err := ioutil.WriteFile(cpuinfo, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := checkBoard()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkBoard() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && err != ErrNotPi {
+				t.Errorf("checkBoard() error = %v, want ErrNotPi", err)
+			}
+		})
+	}
+}
+
+func Test_check(t *testing.T) {
+	tests := []struct {
+		name  string
+		ret   int
+		errno error
+		want  error // nil, or what the error wraps
+	}{
+		{"ok", 3, syscall.EIO, nil},
+		{"errno", -1, syscall.ENOENT, syscall.ENOENT},
+		{"no errno", -1, nil, errFailed},
+		{"zero errno", -1, syscall.Errno(0), errFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := check("wiringPiI2CSetup", tt.ret, tt.errno)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("check() = %v, want nil", err)
+				}
+				return
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Func != "wiringPiI2CSetup" {
+				t.Fatalf("check() = %v, want an *Error for wiringPiI2CSetup", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("check() = %v, want it to wrap %v", err, tt.want)
+			}
+		})
+	}
+}